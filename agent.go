@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// runMission is the agent loop: plan, run the requested tools, and repeat until the model answers. It is shared by
+// the interactive prompt and every frontend that accepts missions from elsewhere, so all of them get the same
+// recovery from overflows, empty replies, and loops. Everything is appended to messages; the answer is returned,
+// or ctx's error when interrupted, errEmptyReply, errRepeatedCalls, or errTurnBudget when the mission was given
+// up, and any other error when the provider failed.
+func runMission(ctx context.Context, mission string, messages *[]ChatMessage, tools string) (string, error) {
+	var repeats repeatTracker
+	nudgedEmpty, overflows, overBudget := false, 0, false
+	for turns := 1; ; turns++ {
+		// Each planning round is one turn span, parenting its LLM request and every tool it triggers.
+		turnCtx, turn := startSpan(ctx, "agent.turn", "mission", mission, "messages", len(*messages))
+		trimHistory(*messages, tools, *contextTokens, &overBudget)
+		awaitWarmUp()
+		status := startStatus("🤔 Planning")
+		event(slog.LevelInfo, "", "planning", "messages", len(*messages))
+		msg, thoughts, err := sendChatRequest(turnCtx, *model, *messages, []byte(tools))
+		if err != nil && ctx.Err() != nil {
+			status.stop()
+			turn.finish(err)
+			flushSpans()
+			return "", ctx.Err()
+		}
+		if err != nil && isContextOverflow(err) && overflows < 2 && recoverOverflow(*messages, tools) {
+			status.stop()
+			turn.finish(err)
+			overflows++
+			continue
+		}
+		if err != nil {
+			status.stop()
+			if !errors.Is(err, errCostDeclined) {
+				event(slog.LevelError, fmt.Sprintf("\033[31mError: %v\n", err), "planning failed", "err", err)
+			}
+			turn.finish(err)
+			flushSpans()
+			return "", err
+		}
+		overflows = 0
+		answering := len(msg.ToolCalls) == 0 && strings.TrimSpace(msg.Content) != ""
+		if sample, answersOnly := turnSampling(turns == 1, answering); sample {
+			best := bestCandidate(turnCtx, mission, *messages, tools, candidate{msg, thoughts}, answersOnly)
+			msg, thoughts = best.msg, best.thoughts
+		}
+		if *showThoughts && thoughts != "" {
+			event(slog.LevelInfo, fmt.Sprintf("\033[90m💭 %s\033[0m\n", strings.ReplaceAll(thoughts, "\n", "\n   ")), "thoughts", "text", thoughts)
+		}
+
+		// An empty reply is not kept in the history, where it would only teach the model that silence is an answer.
+		if strings.TrimSpace(msg.Content) == "" && len(msg.ToolCalls) == 0 {
+			status.stop()
+			turn.finish(errEmptyReply)
+			flushSpans()
+			if !nudgedEmpty {
+				nudgedEmpty = true
+				event(slog.LevelWarn, "\033[33mModel replied with nothing, asking again\033[0m\n", "empty reply", "retry", true)
+				*messages = append(*messages, ChatMessage{Role: "user", Content: emptyReplyNudge})
+				continue
+			}
+			event(slog.LevelError, "\033[31mError: the model replied with neither an answer nor a tool call, even after a nudge\033[0m\n", "empty reply", "retry", false)
+			return "", errEmptyReply
+		}
+		nudgedEmpty = false
+		msg.Thoughts = thoughts
+		*messages = append(*messages, *msg)
+
+		var images imageTray
+		for i, res := range runToolCalls(withImageTray(turnCtx, &images), msg.ToolCalls, status) {
+			// Tool results are appended to the message history using 'tool' role and associated ToolCallID,
+			// enabling the model to incorporate execution feedback into further reasoning.
+			*messages = append(*messages, ChatMessage{
+				Role:       "tool",
+				Content:    res,
+				ToolCallID: msg.ToolCalls[i].ID,
+			})
+		}
+		if attached := images.take(); len(attached) > 0 {
+			*messages = append(*messages, ChatMessage{Role: "user", Content: attachedImagesNote, Images: attached})
+		}
+
+		status.stop()
+		if ctx.Err() != nil {
+			turn.finish(ctx.Err())
+			flushSpans()
+			return "", ctx.Err()
+		}
+		n, name := repeats.check(msg.ToolCalls)
+		if n >= repeatNudgeAt && n < repeatAbortAt {
+			event(slog.LevelWarn, fmt.Sprintf("\033[33m🔁 %s repeated %d turns in a row, nudging the model\033[0m\n", name, n), "repeated tool call", "tool", name, "repeats", n)
+			*messages = append(*messages, ChatMessage{Role: "user", Content: fmt.Sprintf(repeatNudge, name, n)})
+		}
+		turn.set("tool_calls", len(msg.ToolCalls))
+		turn.finish(nil)
+		flushSpans()
+		reportSessionTotal()
+		if n >= repeatAbortAt {
+			event(slog.LevelError, fmt.Sprintf("\033[31mAbandoning mission: the same %s call repeated %d turns in a row\033[0m\n", name, n),
+				"mission abandoned", "reason", "repeated tool call", "tool", name, "repeats", n)
+			return "", errRepeatedCalls
+		}
+
+		if msg.Content != "" {
+			return strings.TrimSpace(msg.Content), nil
+		}
+		if *maxTurns > 0 && turns >= *maxTurns {
+			event(slog.LevelError, fmt.Sprintf("\033[31mAbandoning mission: its budget of %d turns is spent\033[0m\n", *maxTurns),
+				"mission abandoned", "reason", "turn budget", "turns", turns)
+			return "", errTurnBudget
+		}
+		steer(messages)
+	}
+}
+
+// maxTurns bounds the planning turns of one mission, for unattended runs that should fail rather than wander.
+var maxTurns = flag.Int("max-turns", 0, "Give up a mission after this many planning turns (0 for no limit)")
+
+// errRepeatedCalls ends a mission stuck calling the same tools with the same arguments.
+var errRepeatedCalls = errors.New("repeated tool calls")
+
+// errTurnBudget ends a mission that used up --max-turns without answering.
+var errTurnBudget = errors.New("turn budget spent")
+
+// awaitWarmUp blocks until the background warm-up request has answered; main replaces it with warmUp's waiter.
+var awaitWarmUp = func() {}
+
+type missionEventsKey struct{}
+
+// withMissionEvents has every tool call made under ctx reported to notify as one line, so a frontend that
+// isn't watching the terminal, like a chat thread, can show what the agent is doing.
+func withMissionEvents(ctx context.Context, notify func(line string)) context.Context {
+	return context.WithValue(ctx, missionEventsKey{}, notify)
+}
+
+// notifyToolCall reports a call to the mission's frontend, when it asked with withMissionEvents.
+func notifyToolCall(ctx context.Context, name, args string) {
+	notify, ok := ctx.Value(missionEventsKey{}).(func(string))
+	if !ok {
+		return
+	}
+	var parts []string
+	for _, arg := range toolArgs(args) {
+		parts = append(parts, arg[0]+": "+arg[1])
+	}
+	notify(fmt.Sprintf("🔧 %s %s", name, strings.Join(parts, ", ")))
+}
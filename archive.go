@@ -0,0 +1,196 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// An archive given as a --root, such as a build's artifacts or a support bundle, is read into memory once and
+// browsed like a directory, without unpacking it anywhere. Archives are read-only.
+
+// maxArchiveBytes bounds what an archive may unpack to, since all of it is held in memory.
+const maxArchiveBytes = 512 << 20
+
+var errReadOnly = errors.New("this root is read-only")
+
+// archiveFS is the tree of files in a zip or tar archive.
+type archiveFS struct {
+	files    map[string][]byte
+	infos    map[string]remoteInfo
+	children map[string][]string        // sorted, for ReadDir
+	members  map[string]map[string]bool // the same while the archive is read
+}
+
+// isArchive reports whether a --root names an archive rather than a directory.
+func isArchive(name string) bool {
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".zip", ".jar", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func openArchive(file string) (*archiveFS, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	a := &archiveFS{files: map[string][]byte{}, infos: map[string]remoteInfo{".": {name: ".", mode: fs.ModeDir | 0o555}}, children: map[string][]string{}, members: map[string]map[string]bool{}}
+	lower := strings.ToLower(file)
+	if strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".jar") {
+		err = a.readZip(raw)
+	} else {
+		var r io.Reader = bytes.NewReader(raw)
+		if !strings.HasSuffix(lower, ".tar") {
+			if r, err = gzip.NewReader(r); err != nil {
+				return nil, fmt.Errorf("%s: %v", file, err)
+			}
+		}
+		err = a.readTar(r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	for dir, members := range a.members {
+		a.children[dir] = sortedKeys(members)
+	}
+	a.members = nil
+	return a, nil
+}
+
+func (a *archiveFS) readZip(raw []byte) error {
+	zr, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return err
+	}
+	total := int64(0)
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			a.add(f.Name, nil, f.FileInfo())
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		content, err := io.ReadAll(io.LimitReader(rc, maxArchiveBytes-total+1))
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if total += int64(len(content)); total > maxArchiveBytes {
+			return fmt.Errorf("unpacks to more than %d MB", maxArchiveBytes>>20)
+		}
+		a.add(f.Name, content, f.FileInfo())
+	}
+	return nil
+}
+
+func (a *archiveFS) readTar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	total := int64(0)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			a.add(hdr.Name, nil, hdr.FileInfo())
+		case tar.TypeReg:
+			content, err := io.ReadAll(io.LimitReader(tr, maxArchiveBytes-total+1))
+			if err != nil {
+				return err
+			}
+			if total += int64(len(content)); total > maxArchiveBytes {
+				return fmt.Errorf("unpacks to more than %d MB", maxArchiveBytes>>20)
+			}
+			a.add(hdr.Name, content, hdr.FileInfo())
+		}
+	}
+}
+
+// add records an entry and every directory above it, which archives often leave out. Names that would climb out
+// of the archive are skipped.
+func (a *archiveFS) add(name string, content []byte, info fs.FileInfo) {
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	if !fs.ValidPath(name) || name == "." {
+		return
+	}
+	if !info.IsDir() {
+		a.files[name] = content
+	}
+	a.infos[name] = remoteInfo{name: path.Base(name), size: int64(len(content)), mode: info.Mode(), modTime: info.ModTime()}
+	for child := name; child != "."; {
+		parent := path.Dir(child)
+		if _, seen := a.infos[parent]; !seen {
+			a.infos[parent] = remoteInfo{name: path.Base(parent), mode: fs.ModeDir | 0o555, modTime: info.ModTime()}
+		}
+		if a.members[parent] == nil {
+			a.members[parent] = map[string]bool{}
+		}
+		if a.members[parent][path.Base(child)] {
+			break // and so are the directories above it
+		}
+		a.members[parent][path.Base(child)] = true
+		child = parent
+	}
+}
+
+func (a *archiveFS) Stat(name string) (fs.FileInfo, error) {
+	info, ok := a.infos[path.Clean(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return info, nil
+}
+
+func (a *archiveFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	name = path.Clean(name)
+	info, ok := a.infos[name]
+	if !ok || !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	var entries []fs.DirEntry
+	for _, child := range a.children[name] {
+		entries = append(entries, fs.FileInfoToDirEntry(a.infos[path.Join(name, child)]))
+	}
+	return entries, nil
+}
+
+func (a *archiveFS) ReadFile(name string) ([]byte, error) {
+	content, ok := a.files[path.Clean(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return content, nil
+}
+
+func (a *archiveFS) Open(name string) (fs.File, error) {
+	info, err := a.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteFile{Reader: bytes.NewReader(a.files[path.Clean(name)]), info: info}, nil
+}
+
+func (a *archiveFS) WriteFile(name string, _ []byte, _ fs.FileMode) error {
+	return &fs.PathError{Op: "write", Path: name, Err: errReadOnly}
+}
+
+func (a *archiveFS) MkdirAll(name string, _ fs.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: errReadOnly}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// --attach hands the first mission the artifacts it is about, a screenshot of the bug or the crash log, so the
+// agent starts from them instead of searching for them with tools. Text files go into the mission's message,
+// images go with it as image parts, which needs --vision. Files are read from the local disk even under --remote,
+// since that is where the user has them.
+var attachPaths stringList
+
+func init() {
+	flag.Var(&attachPaths, "attach", "Attach a file or image to the first mission; repeat for several")
+}
+
+// stringList is a flag that may be given many times, collecting every value.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ", ") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+const maxAttachedText = 64 << 10 // per file; a longer log keeps its end, where the failure usually is
+
+// attachments holds the loaded --attach files until the first mission takes them.
+var attachments struct {
+	text   string
+	images []string
+}
+
+// loadAttachments reads every --attach file, failing on any that can't be sent, before a mission starts.
+func loadAttachments() error {
+	var b strings.Builder
+	for _, path := range attachPaths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name := filepath.Base(path)
+		switch mime := http.DetectContentType(raw); {
+		case strings.HasPrefix(mime, "image/"):
+			if !*vision {
+				return fmt.Errorf("%s is an image; attach it with --vision, if the model accepts images", path)
+			}
+			url, _, _, err := imageDataURL(raw)
+			if err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			attachments.images = append(attachments.images, url)
+			fmt.Fprintf(&b, "\n\nAttached image: %s", name)
+		case bytes.IndexByte(raw, 0) != -1:
+			return fmt.Errorf("%s is neither text nor an image (%s)", path, mime)
+		default:
+			text := clipOutput(string(raw), maxAttachedText, true)
+			fence := "```"
+			for _, run := range backtickRun.FindAllString(text, -1) {
+				if len(run) >= len(fence) {
+					fence = run + "`"
+				}
+			}
+			fmt.Fprintf(&b, "\n\nAttached file: %s\n%s\n%s\n%s", name, fence, strings.TrimRight(text, "\n"), fence)
+		}
+	}
+	attachments.text = b.String()
+	return nil
+}
+
+// missionMessage is a mission's user message, carrying the attachments if no mission has yet.
+func missionMessage(mission string) ChatMessage {
+	msg := ChatMessage{Role: "user", Content: fmt.Sprintf(userPromptFormat, mission) + attachments.text, Images: attachments.images}
+	attachments.text, attachments.images = "", nil
+	return msg
+}
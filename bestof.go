@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A small model's first plan and its final answer decide most of a mission, and it gets them wrong more often
+// than it gets them wrong every time. With --best-of N those turns are sampled N times, the extra candidates at
+// a higher temperature, and a judge request picks the best one; the others are dropped from the history. The
+// plan turn is the mission's first; the answer turn is any reply that answers rather than calling tools, so its
+// extra candidates are drawn only once the first answers. It costs N-1 extra requests and one judge per turn.
+var (
+	bestOf            = flag.Int("best-of", 1, "Sample the critical turns this many times and keep the candidate a judge request picks (1 disables)")
+	bestOfTurns       = flag.String("best-of-turns", "plan,answer", "Which turns --best-of samples: plan (the first), answer (the final answer), or all")
+	bestOfTemperature = flag.Float64("best-of-temperature", 0.8, "Sampling temperature of the extra --best-of candidates")
+)
+
+const bestOfJudgePrompt = `You compare candidate replies of a software agent and pick the best. The best candidate is the one most likely to get the task done correctly: for answers, the most accurate, specific, and complete, grounded in what was found; for tool calls, the most useful next step. Reply with the number of the best candidate and nothing else.`
+
+var judgeChoice = regexp.MustCompile(`\d+`)
+
+type temperatureKey struct{}
+
+// withTemperature samples the LLM requests made under ctx at t instead of the usual temperature.
+func withTemperature(ctx context.Context, t float64) context.Context {
+	return context.WithValue(ctx, temperatureKey{}, t)
+}
+
+func temperatureOf(ctx context.Context) float64 {
+	if t, ok := ctx.Value(temperatureKey{}).(float64); ok {
+		return t
+	}
+	return 0.3
+}
+
+// turnSampling reports whether --best-of samples a turn, and whether only candidates that answer may win it,
+// which is so when it is sampled only for being the answer.
+func turnSampling(first, answering bool) (sample, answersOnly bool) {
+	if *bestOf <= 1 {
+		return false, false
+	}
+	kinds := map[string]bool{}
+	for _, kind := range strings.Split(*bestOfTurns, ",") {
+		kinds[strings.TrimSpace(kind)] = true
+	}
+	wide := kinds["all"] || (first && kinds["plan"])
+	return wide || (answering && kinds["answer"]), !wide
+}
+
+// checkBestOfTurns validates --best-of-turns.
+func checkBestOfTurns() error {
+	for _, kind := range strings.Split(*bestOfTurns, ",") {
+		if kind = strings.TrimSpace(kind); kind != "plan" && kind != "answer" && kind != "all" {
+			return fmt.Errorf("--best-of-turns: %q is not plan, answer, or all", kind)
+		}
+	}
+	return nil
+}
+
+type candidate struct {
+	msg      *ChatMessage
+	thoughts string
+}
+
+// sampleCandidates draws n more replies to the conversation in parallel. Failed ones are left out; the turn
+// already has the first reply to fall back on.
+func sampleCandidates(ctx context.Context, messages []ChatMessage, tools string, n int) []candidate {
+	ctx = withTemperature(withPurpose(ctx, "best-of"), *bestOfTemperature)
+	results := make([]candidate, n)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			msg, thoughts, err := sendChatRequest(ctx, *model, messages, []byte(tools))
+			if err != nil {
+				event(slog.LevelWarn, fmt.Sprintf("\033[33mA --best-of candidate failed: %v\033[0m\n", err), "best-of candidate failed", "err", err)
+				return
+			}
+			results[i] = candidate{msg, thoughts}
+		}()
+	}
+	wg.Wait()
+	var drawn []candidate
+	for _, c := range results {
+		if c.msg != nil && (strings.TrimSpace(c.msg.Content) != "" || len(c.msg.ToolCalls) > 0) {
+			drawn = append(drawn, c)
+		}
+	}
+	return drawn
+}
+
+// bestCandidate samples more replies to a turn whose first reply is first and returns the one the judge picks.
+// answersOnly drops the candidates that call tools instead.
+func bestCandidate(ctx context.Context, mission string, messages []ChatMessage, tools string, first candidate, answersOnly bool) candidate {
+	candidates := []candidate{first}
+	for _, c := range sampleCandidates(ctx, messages, tools, *bestOf-1) {
+		if !answersOnly || len(c.msg.ToolCalls) == 0 {
+			candidates = append(candidates, c)
+		}
+	}
+	if len(candidates) == 1 {
+		return first
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "The task: %s\n\nThe conversation so far:\n%s\n", mission, clipOutput(compactTranscript(messages), 8000, true))
+	for i, c := range candidates {
+		fmt.Fprintf(&b, "\nCANDIDATE %d:\n", i+1)
+		if c.msg.Content != "" {
+			fmt.Fprintln(&b, strings.TrimSpace(c.msg.Content))
+		}
+		for _, tc := range c.msg.ToolCalls {
+			fmt.Fprintf(&b, "Calls %s\n", describeCall(tc.Function.Name, tc.Function.Arguments))
+		}
+	}
+	fmt.Fprintf(&b, "\nWhich candidate is best? Reply with its number, 1 to %d.", len(candidates))
+	verdict, _, err := sendChatRequest(withPurpose(ctx, "judge"), *model, []ChatMessage{
+		{Role: "system", Content: bestOfJudgePrompt},
+		{Role: "user", Content: b.String()},
+	}, nil)
+	if err != nil {
+		event(slog.LevelWarn, fmt.Sprintf("\033[33mThe --best-of judge failed, keeping the first candidate: %v\033[0m\n", err), "best-of judge failed", "err", err)
+		return first
+	}
+	pick, _ := strconv.Atoi(judgeChoice.FindString(verdict.Content))
+	if pick < 1 || pick > len(candidates) {
+		event(slog.LevelWarn, fmt.Sprintf("\033[33mThe --best-of judge named no candidate (%q), keeping the first\033[0m\n", clip(verdict.Content)), "best-of judge unclear", "reply", verdict.Content)
+		return first
+	}
+	event(slog.LevelInfo, fmt.Sprintf("\033[90m🏅 Picked candidate %d of %d\033[0m\n", pick, len(candidates)), "best-of pick", "pick", pick, "candidates", len(candidates))
+	return candidates[pick-1]
+}
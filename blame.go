@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// git_blame answers "when and why did this change": for a range of lines it gives each commit that last
+// touched them, with author, date, and the commit's subject, followed by the lines themselves.
+const blameToolDef = `[
+		{"type":"function","function":{"name":"git_blame","description":"Show who last changed each line in a range of a file, when, and in which commit with which summary. Use to learn why code is the way it is.","parameters":{"type":"object","properties":{
+			"path":{"type":"string","description":"Target file relative to current working directory"},
+			"start":{"type":"integer","description":"First line, 1-based"},
+			"end":{"type":"integer","description":"Last line, inclusive; at most 200 lines after start"} },"required":["path","start","end"]}}}
+		]`
+
+// maxBlameLines bounds one call, since every line comes back with its text.
+const maxBlameLines = 200
+
+// inGitRepo reports whether the workspace, or any of its roots, is inside a git work tree.
+var inGitRepo = sync.OnceValue(func() bool {
+	for _, dir := range workDirs() {
+		out, err := workCommand(context.Background(), "git", "-C", dir, "rev-parse", "--is-inside-work-tree").Output()
+		if err == nil && strings.TrimSpace(string(out)) == "true" {
+			return true
+		}
+	}
+	return false
+})
+
+var blameTools = registerToolset(&toolset{
+	def:      blameToolDef,
+	enabled:  inGitRepo,
+	readOnly: true,
+	run: map[string]func(context.Context, string) (string, error){
+		"git_blame": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Path  string `json:"path"`
+				Start int    `json:"start"`
+				End   int    `json:"end"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			return gitBlame(ctx, params.Path, params.Start, params.End)
+		},
+	},
+})
+
+type blameCommit struct {
+	author, summary string
+	when            time.Time
+}
+
+func gitBlame(ctx context.Context, path string, start, end int) (string, error) {
+	if !filepath.IsLocal(path) {
+		return "", fmt.Errorf("Permanent Error: Path %s is outside of current working directory", path)
+	}
+	start = max(start, 1)
+	if end < start {
+		end = start
+	}
+	end = min(end, start+maxBlameLines-1)
+	dir, rest, err := rootOf(path)
+	if err != nil {
+		return "", err
+	}
+	cmd := workCommand(ctx, "git", "blame", "--porcelain", "-L", fmt.Sprintf("%d,%d", start, end), "--", rest)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git blame failed: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	// The porcelain format gives a header per line, "<sha> <original line> <final line>", with the commit's
+	// details only the first time that commit appears, then the line itself after a tab.
+	commits := map[string]*blameCommit{}
+	var b strings.Builder
+	fmt.Fprintf(&b, "git_blame %s lines %d-%d results\n", toolPath(path), start, end)
+	sha, previous := "", ""
+	lineNo := 0
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if text, ok := strings.CutPrefix(line, "\t"); ok {
+			if sha != previous {
+				c := commits[sha]
+				short := sha[:min(len(sha), 8)]
+				if strings.Trim(sha, "0") == "" {
+					fmt.Fprintf(&b, "\nNot committed yet:\n")
+				} else {
+					fmt.Fprintf(&b, "\n%s by %s on %s: %s\n", short, c.author, c.when.Format("2006-01-02"), c.summary)
+				}
+				previous = sha
+			}
+			fmt.Fprintf(&b, "%5d| %s\n", lineNo, text)
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && len(fields[0]) == 40 {
+			sha = fields[0]
+			lineNo, _ = strconv.Atoi(fields[2])
+			if commits[sha] == nil {
+				commits[sha] = &blameCommit{}
+			}
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		switch c := commits[sha]; key {
+		case "author":
+			c.author = value
+		case "author-time":
+			secs, _ := strconv.ParseInt(value, 10, 64)
+			c.when = time.Unix(secs, 0)
+		case "summary":
+			c.summary = value
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Chat bots take missions from other people, so each runs in a conversation of its own rather than on top of the
+// terminal's: one per mission, or one per thread where a service has threads to follow up in. The agent's state
+// is global, so missions run one at a time whichever service sent them; the terminal keeps showing everything,
+// which makes it the bot's log.
+var botMu sync.Mutex
+
+// runBotMission runs mission to completion in the conversation messages, which may start empty, reporting each
+// tool call to notify unless it is nil, and returns its answer.
+func runBotMission(ctx context.Context, tools string, messages *[]ChatMessage, mission string, notify func(line string)) (string, error) {
+	botMu.Lock()
+	defer botMu.Unlock()
+	beginMission()
+	if len(*messages) == 0 {
+		*messages = append(*messages, ChatMessage{Role: "system", Content: agentPrompt})
+	}
+	*messages = append(*messages, ChatMessage{Role: "user", Content: fmt.Sprintf(userPromptFormat, mission)})
+	if notify != nil {
+		ctx = withMissionEvents(ctx, notify)
+	}
+	answer, err := runMission(ctx, mission, messages, tools)
+	if err == nil {
+		appendOutput(mission, answer)
+	}
+	return answer, err
+}
+
+// splitMessage cuts text into pieces of at most limit bytes for services that cap a message's length, breaking
+// at line ends where it can so code blocks and lists survive.
+func splitMessage(text string, limit int) []string {
+	var parts []string
+	for len(text) > limit {
+		cut := strings.LastIndexByte(text[:limit], '\n')
+		if cut < limit/2 {
+			cut = limit
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+		}
+		parts = append(parts, text[:cut])
+		text = strings.TrimPrefix(text[cut:], "\n")
+	}
+	return append(parts, text)
+}
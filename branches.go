@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// /fork copies the conversation into a new branch and carries on in it, so a second approach can start from
+// everything already investigated without spoiling the first. /switch moves between branches, /branches lists
+// them with their last answers side by side, and /drop discards one. Each branch keeps its own transcript file.
+var branches = struct {
+	sync.Mutex
+	current string
+	order   []string
+	saved   map[string][]ChatMessage // every branch but the current one, whose messages are the live conversation
+}{current: "main", order: []string{"main"}, saved: map[string][]ChatMessage{}}
+
+var branchName = regexp.MustCompile(`^[\w.-]+$`)
+
+// branchSuffix tells the current branch's transcript apart from the main one's.
+func branchSuffix() string {
+	branches.Lock()
+	defer branches.Unlock()
+	if branches.current == "main" {
+		return ""
+	}
+	return "-" + branches.current
+}
+
+func forkBranch(messages *[]ChatMessage, name string) {
+	branches.Lock()
+	defer branches.Unlock()
+	if name == "" {
+		for n := len(branches.order); name == "" || slices.Contains(branches.order, name); n++ {
+			name = fmt.Sprintf("fork-%d", n)
+		}
+	}
+	switch {
+	case !branchName.MatchString(name):
+		event(slog.LevelError, "\033[31mError: a branch name is letters, digits, and . _ -\033[0m\n", "bad fork command", "name", name)
+		return
+	case slices.Contains(branches.order, name):
+		event(slog.LevelError, fmt.Sprintf("\033[31mError: there is already a branch %s\033[0m\n", name), "bad fork command", "name", name)
+		return
+	}
+	branches.saved[branches.current] = slices.Clone(*messages)
+	from := branches.current
+	branches.current = name
+	branches.order = append(branches.order, name)
+	*messages = slices.Clone(*messages)
+	event(slog.LevelInfo, fmt.Sprintf("\033[90m🌿 Forked %s into %s; /switch %s goes back\033[0m\n", from, name, from), "branch forked", "from", from, "branch", name)
+}
+
+func switchBranch(messages *[]ChatMessage, name string) {
+	branches.Lock()
+	defer branches.Unlock()
+	target, ok := branches.saved[name]
+	switch {
+	case name == branches.current:
+		event(slog.LevelInfo, fmt.Sprintf("\033[90mAlready on %s\033[0m\n", name), "branch switched", "branch", name)
+		return
+	case !ok:
+		event(slog.LevelError, fmt.Sprintf("\033[31mError: no branch %q, /branches lists them\033[0m\n", name), "bad switch command", "name", name)
+		return
+	}
+	branches.saved[branches.current] = *messages
+	delete(branches.saved, name)
+	branches.current = name
+	*messages = target
+	event(slog.LevelInfo, fmt.Sprintf("\033[90m🌿 On %s, %d missions in\033[0m\n", name, len(sessionMissions(target))), "branch switched", "branch", name)
+}
+
+func dropBranch(name string) {
+	branches.Lock()
+	defer branches.Unlock()
+	switch _, ok := branches.saved[name]; {
+	case name == branches.current:
+		event(slog.LevelError, "\033[31mError: can't drop the branch you are on; /switch away first\033[0m\n", "bad drop command", "name", name)
+		return
+	case !ok:
+		event(slog.LevelError, fmt.Sprintf("\033[31mError: no branch %q, /branches lists them\033[0m\n", name), "bad drop command", "name", name)
+		return
+	}
+	delete(branches.saved, name)
+	branches.order = slices.DeleteFunc(branches.order, func(n string) bool { return n == name })
+	event(slog.LevelInfo, fmt.Sprintf("\033[90mDropped %s\033[0m\n", name), "branch dropped", "branch", name)
+}
+
+// branchTable lists the branches with their size and last answer, for comparing them.
+func branchTable(messages []ChatMessage) string {
+	branches.Lock()
+	defer branches.Unlock()
+	var b strings.Builder
+	for _, name := range branches.order {
+		conversation, marker := branches.saved[name], " "
+		if name == branches.current {
+			conversation, marker = messages, "*"
+		}
+		answer := strings.Join(strings.Fields(lastAnswer(conversation)), " ")
+		if answer == "" {
+			answer = "(no answer yet)"
+		}
+		fmt.Fprintf(&b, "%s %-16s %3d missions %5d tokens  %s\n", marker, name, len(sessionMissions(conversation)), historyTokens(conversation, ""), clip(answer))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Giant directories (asset folders, node_modules, generated code) used to be dumped whole into the prompt.
+// Listings are now paginated, and any extension with many files collapses into a single counted line.
+const (
+	entriesPerPage     = 200
+	aggregateThreshold = 25
+	aggregateExamples  = 3
+)
+
+// browseDirectory lists one page of a directory's children grouped by kind.
+func browseDirectory(path string, pageNum int) (string, error) {
+	entries, err := workspace.ReadDir(path)
+	if err != nil {
+		return "", fmt.Errorf("Error reading directory: %v", err)
+	}
+
+	byExt := map[string][]string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			ext := strings.ToLower(filepath.Ext(entry.Name()))
+			byExt[ext] = append(byExt[ext], entry.Name())
+		}
+	}
+
+	// Items keep directory order; an aggregated extension takes the slot of its first file.
+	type item struct {
+		entry   fs.DirEntry
+		summary string
+	}
+	var items []item
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		group := byExt[ext]
+		switch {
+		case entry.IsDir() || ext == "" || len(group) <= aggregateThreshold:
+			items = append(items, item{entry: entry})
+		case group[0] == entry.Name():
+			examples := make([]string, 0, aggregateExamples)
+			for _, name := range group[:aggregateExamples] {
+				examples = append(examples, "`"+toolPath(filepath.Join(path, name))+"`")
+			}
+			items = append(items, item{summary: fmt.Sprintf("%d `%s` files (e.g. %s)", len(group), ext, strings.Join(examples, ", "))})
+		}
+	}
+
+	pages := max((len(items)+entriesPerPage-1)/entriesPerPage, 1)
+	if pageNum < 0 || pageNum >= pages {
+		return "", fmt.Errorf("Page %d does not exist, %s has %d pages (0-%d)", pageNum, toolPath(path), pages, pages-1)
+	}
+	first, last := pageNum*entriesPerPage, min((pageNum+1)*entriesPerPage, len(items))
+
+	filesByType := make(map[string][]string)
+	for _, it := range items[first:last] {
+		if it.summary != "" {
+			filesByType["aggregated files"] = append(filesByType["aggregated files"], it.summary)
+			continue
+		}
+		fullPath := filepath.Join(path, it.entry.Name())
+		if it.entry.IsDir() {
+			filesByType["subdirectories"] = append(filesByType["subdirectories"], "`"+toolPath(fullPath)+"`")
+		} else {
+			typ := fileType(fullPath)
+			filesByType[typ+" files"] = append(filesByType[typ+" files"], "`"+toolPath(fullPath)+"`")
+		}
+	}
+
+	types := make([]string, 0, len(filesByType))
+	for typ := range filesByType {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	parts := make([]string, 0, len(types))
+	for _, typ := range types {
+		parts = append(parts, fmt.Sprintf("- %s: %s", typ, filesByType[typ]))
+	}
+
+	header := fmt.Sprintf("analyze_path `%s` results:", toolPath(path))
+	if pages > 1 {
+		more := ""
+		if pageNum+1 < pages {
+			more = ", ask for the next page to see more"
+		}
+		header = fmt.Sprintf("analyze_path `%s` page %d of %d (entries %d-%d of %d%s) results:", toolPath(path), pageNum, pages, first+1, last, len(items), more)
+	}
+	return header + "\n" + strings.Join(parts, "\n"), nil
+}
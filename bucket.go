@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A bucket given as a --root, s3://bucket/prefix or gs://bucket/prefix, is browsed like a directory: "/" in object
+// names makes the folders, as the consoles show them. Objects are fetched whole when a tool opens one, and
+// buckets are read-only. S3 uses the usual AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, and
+// AWS_REGION, with AWS_ENDPOINT_URL for S3-compatible stores; GCS uses GOOGLE_OAUTH_ACCESS_TOKEN or gcloud's
+// login, with STORAGE_EMULATOR_HOST for the emulator. Without credentials, public buckets still work.
+
+// maxObjectBytes bounds one object fetched for a tool.
+const maxObjectBytes = 64 << 20
+
+// bucketFS is the objects under a prefix of one bucket.
+type bucketFS struct {
+	scheme, bucket, prefix string
+
+	tokenOnce sync.Once
+	token     string // GCS access token, "" when anonymous
+}
+
+// bucketObject is an object or, with dir set, a folder, as a listing gives it.
+type bucketObject struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func isBucket(name string) bool {
+	return strings.HasPrefix(name, "s3://") || strings.HasPrefix(name, "gs://")
+}
+
+func openBucket(source string) (*bucketFS, error) {
+	scheme, rest, _ := strings.Cut(source, "://")
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("%s names no bucket", source)
+	}
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		prefix += "/"
+	}
+	b := &bucketFS{scheme: scheme, bucket: bucket, prefix: prefix}
+	// Listing the top proves the bucket exists and the credentials work before a mission depends on them.
+	if _, _, err := b.list(context.Background(), "", 1); err != nil {
+		return nil, fmt.Errorf("%s: %v", source, err)
+	}
+	return b, nil
+}
+
+// key is the object name of a workspace path; "" is the top of the prefix.
+func (b *bucketFS) key(name string) string {
+	if name = path.Clean(strings.TrimPrefix(name, "/")); name == "." {
+		return b.prefix
+	}
+	return b.prefix + name
+}
+
+// list returns up to max objects and folders whose names start with prefix but go no further than its next "/";
+// a max of 0 lists everything.
+func (b *bucketFS) list(ctx context.Context, prefix string, max int) ([]bucketObject, bool, error) {
+	var objects []bucketObject
+	for next := ""; ; {
+		page, token, err := b.listPage(ctx, prefix, next, max)
+		if err != nil {
+			return nil, false, err
+		}
+		objects = append(objects, page...)
+		if token == "" || (max > 0 && len(objects) >= max) {
+			return objects, len(objects) > 0, nil
+		}
+		next = token
+	}
+}
+
+func (b *bucketFS) listPage(ctx context.Context, prefix, next string, max int) ([]bucketObject, string, error) {
+	query := url.Values{"prefix": {prefix}, "delimiter": {"/"}}
+	var objects []bucketObject
+	if b.scheme == "gs" {
+		if max > 0 {
+			query.Set("maxResults", fmt.Sprint(max))
+		}
+		if next != "" {
+			query.Set("pageToken", next)
+		}
+		var page struct {
+			Prefixes []string `json:"prefixes"`
+			Items    []struct {
+				Name    string    `json:"name"`
+				Size    string    `json:"size"`
+				Updated time.Time `json:"updated"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		raw, err := b.get(ctx, "/storage/v1/b/"+url.PathEscape(b.bucket)+"/o", query)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return nil, "", err
+		}
+		for _, p := range page.Prefixes {
+			objects = append(objects, bucketObject{name: p, dir: true})
+		}
+		for _, item := range page.Items {
+			var size int64
+			fmt.Sscan(item.Size, &size)
+			objects = append(objects, bucketObject{name: item.Name, size: size, modTime: item.Updated})
+		}
+		return objects, page.NextPageToken, nil
+	}
+
+	query.Set("list-type", "2")
+	if max > 0 {
+		query.Set("max-keys", fmt.Sprint(max))
+	}
+	if next != "" {
+		query.Set("continuation-token", next)
+	}
+	var page struct {
+		CommonPrefixes []struct {
+			Prefix string `xml:"Prefix"`
+		} `xml:"CommonPrefixes"`
+		Contents []struct {
+			Key          string    `xml:"Key"`
+			Size         int64     `xml:"Size"`
+			LastModified time.Time `xml:"LastModified"`
+		} `xml:"Contents"`
+		NextContinuationToken string `xml:"NextContinuationToken"`
+	}
+	raw, err := b.get(ctx, "", query)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := xml.Unmarshal(raw, &page); err != nil {
+		return nil, "", err
+	}
+	for _, p := range page.CommonPrefixes {
+		objects = append(objects, bucketObject{name: p.Prefix, dir: true})
+	}
+	for _, c := range page.Contents {
+		objects = append(objects, bucketObject{name: c.Key, size: c.Size, modTime: c.LastModified})
+	}
+	return objects, page.NextContinuationToken, nil
+}
+
+// get sends a GET to the bucket's API: for S3 the object key or "" for the bucket, for GCS the API path.
+func (b *bucketFS) get(ctx context.Context, key string, query url.Values) ([]byte, error) {
+	var u *url.URL
+	var err error
+	if b.scheme == "gs" {
+		base := "https://storage.googleapis.com"
+		if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+			base = strings.TrimSuffix(host, "/")
+			if !strings.Contains(base, "://") {
+				base = "http://" + base
+			}
+		}
+		u, err = url.Parse(base + key)
+	} else {
+		u, err = b.s3URL(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = awsQuery(query)
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if b.scheme == "gs" {
+		if token := b.gcsToken(); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	} else {
+		signS3(req, time.Now())
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxObjectBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fs.ErrNotExist
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s: %s", resp.Status, clip(strings.TrimSpace(string(body))))
+	case len(body) > maxObjectBytes:
+		return nil, fmt.Errorf("the object is larger than %d MB", maxObjectBytes>>20)
+	}
+	return body, nil
+}
+
+// s3URL addresses an object, or the bucket for "": by virtual host on AWS, and by path on the S3-compatible
+// stores that AWS_ENDPOINT_URL points at, which mostly expect that.
+func (b *bucketFS) s3URL(key string) (*url.URL, error) {
+	endpoint := os.Getenv("AWS_ENDPOINT_URL")
+	if endpoint == "" {
+		return &url.URL{Scheme: "https", Host: b.bucket + ".s3." + awsRegion() + ".amazonaws.com", Path: "/" + key, RawPath: "/" + awsEscape(key, true)}, nil
+	}
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	base := u.EscapedPath()
+	u.Path += "/" + b.bucket
+	u.RawPath = base + "/" + awsEscape(b.bucket, false)
+	if key != "" {
+		u.Path += "/" + key
+		u.RawPath += "/" + awsEscape(key, true)
+	}
+	return u, nil
+}
+
+func (b *bucketFS) gcsToken() string {
+	b.tokenOnce.Do(func() {
+		if b.token = os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); b.token != "" || os.Getenv("STORAGE_EMULATOR_HOST") != "" {
+			return
+		}
+		if out, err := exec.Command("gcloud", "auth", "print-access-token").Output(); err == nil {
+			b.token = strings.TrimSpace(string(out))
+		}
+	})
+	return b.token
+}
+
+func (b *bucketFS) object(name string) ([]byte, error) {
+	key := b.key(name)
+	if b.scheme == "gs" {
+		return b.get(context.Background(), "/storage/v1/b/"+url.PathEscape(b.bucket)+"/o/"+url.PathEscape(key), url.Values{"alt": {"media"}})
+	}
+	return b.get(context.Background(), key, nil)
+}
+
+func (b *bucketFS) Stat(name string) (fs.FileInfo, error) {
+	key := b.key(name)
+	if key == b.prefix {
+		return remoteInfo{name: ".", mode: fs.ModeDir | 0o555}, nil
+	}
+	// An object's own name sorts before every longer name it prefixes, so the first listed is it if it exists;
+	// failing that, anything under key/ makes it a folder.
+	objects, _, err := b.list(context.Background(), key, 1)
+	if err == nil && (len(objects) == 0 || objects[0].name != key) {
+		objects, _, err = b.list(context.Background(), key+"/", 1)
+		if len(objects) > 0 {
+			return remoteInfo{name: path.Base(key), mode: fs.ModeDir | 0o555}, nil
+		}
+		objects = nil
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	if len(objects) == 0 {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return remoteInfo{name: path.Base(key), size: objects[0].size, mode: 0o444, modTime: objects[0].modTime}, nil
+}
+
+func (b *bucketFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	dir := b.key(name)
+	if dir != "" && !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	objects, found, err := b.list(context.Background(), dir, 0)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	if !found && dir != b.prefix {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	var entries []fs.DirEntry
+	for _, o := range objects {
+		base := path.Base(strings.TrimSuffix(o.name, "/"))
+		if o.name == dir {
+			continue // the folder's own placeholder object
+		}
+		mode := fs.FileMode(0o444)
+		if o.dir {
+			mode = fs.ModeDir | 0o555
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(remoteInfo{name: base, size: o.size, mode: mode, modTime: o.modTime}))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (b *bucketFS) ReadFile(name string) ([]byte, error) {
+	content, err := b.object(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return content, nil
+}
+
+// Open fetches the whole object, like a remote file, so the tools can page through it with ReadAt.
+func (b *bucketFS) Open(name string) (fs.File, error) {
+	info, err := b.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	var content []byte
+	if !info.IsDir() {
+		if content, err = b.ReadFile(name); err != nil {
+			return nil, err
+		}
+	}
+	return &remoteFile{Reader: bytes.NewReader(content), info: info}, nil
+}
+
+func (b *bucketFS) WriteFile(name string, _ []byte, _ fs.FileMode) error {
+	return &fs.PathError{Op: "write", Path: name, Err: errReadOnly}
+}
+
+func (b *bucketFS) MkdirAll(name string, _ fs.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: errReadOnly}
+}
+
+func awsRegion() string {
+	for _, name := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(name); region != "" {
+			return region
+		}
+	}
+	return "us-east-1"
+}
+
+// awsEscape percent-encodes everything but the unreserved characters, as signature version 4 requires; url's
+// escaping leaves more alone.
+func awsEscape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~', keepSlash && c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// awsQuery is the query string in the sorted, strictly escaped form the signature covers.
+func awsQuery(query url.Values) string {
+	var pairs []string
+	for key, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, awsEscape(key, false)+"="+awsEscape(v, false))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// signS3 signs a bodiless request with AWS signature version 4, covering the host and every header set on it.
+// Without credentials the request goes unsigned, which public buckets allow.
+func signS3(req *http.Request, now time.Time) {
+	id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if id == "" || secret == "" {
+		return
+	}
+	const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	stamp, day := now.UTC().Format("20060102T150405Z"), now.UTC().Format("20060102")
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", emptyHash)
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := sortedKeys(headers)
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signed := strings.Join(names, ";")
+	request := strings.Join([]string{req.Method, req.URL.EscapedPath(), req.URL.RawQuery, canonical.String(), signed, emptyHash}, "\n")
+
+	scope := day + "/" + awsRegion() + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(request))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	key := []byte("AWS4" + secret)
+	for _, part := range []string{day, awsRegion(), "s3", "aws4_request", toSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", id, scope, signed, hex.EncodeToString(key)))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Small models often re-ask the same question about the same page. Keying answers by the file's content hash
+// (not its path or mtime) means an edited file is never served a stale answer, while repeats cost nothing.
+// Answers are also persisted under --cache-dir, so later sessions on the same repo skip unchanged files.
+var (
+	summaryMu    sync.Mutex
+	summaryCache = map[string]string{}
+
+	cacheDir = flag.String("cache-dir", filepath.Join(".tinyagent", "cache"), "Directory for persisted file summaries (empty disables)")
+)
+
+// summaryKey identifies an answer by file content, page and page size, and the question with case, spacing, and trailing
+// punctuation normalized away.
+func summaryKey(file io.ReaderAt, size int64, page, pageSize int, question, model string) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(file, 0, size)); err != nil {
+		return "", err
+	}
+	q := strings.Join(strings.Fields(strings.ToLower(question)), " ")
+	q = strings.TrimRight(q, "?.! ")
+	return fmt.Sprintf("%s:%d/%d:%s:%s", hex.EncodeToString(h.Sum(nil)), page, pageSize, model, q), nil
+}
+
+type cachedEntry struct {
+	Key    string `json:"key"`
+	Answer string `json:"answer"`
+}
+
+// cacheFile maps a key to its file; the key itself is stored inside to rule out hash collisions.
+func cacheFile(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(*cacheDir, name[:2], name+".json")
+}
+
+func cachedSummary(key string) (string, bool) {
+	summaryMu.Lock()
+	answer, ok := summaryCache[key]
+	summaryMu.Unlock()
+	if ok || *cacheDir == "" {
+		return answer, ok
+	}
+
+	var entry cachedEntry
+	raw, err := os.ReadFile(cacheFile(key))
+	if err != nil || json.Unmarshal(raw, &entry) != nil || entry.Key != key {
+		return "", false
+	}
+	summaryMu.Lock()
+	summaryCache[key] = entry.Answer
+	summaryMu.Unlock()
+	return entry.Answer, true
+}
+
+func storeSummary(key, answer string) {
+	summaryMu.Lock()
+	summaryCache[key] = answer
+	summaryMu.Unlock()
+	if *cacheDir == "" {
+		return
+	}
+
+	// Writes go through a temp file and rename so a concurrent or interrupted write never leaves a torn entry.
+	path := cacheFile(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		event(slog.LevelWarn, fmt.Sprintf("\033[33mCould not create cache directory: %v\033[0m\n", err), "cache write failed", "err", err)
+		return
+	}
+	ignore := filepath.Join(*cacheDir, ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		os.WriteFile(ignore, []byte("*\n"), 0o644)
+	}
+	raw, _ := json.Marshal(cachedEntry{key, answer})
+	tmp, err := os.CreateTemp(filepath.Dir(path), "entry-*")
+	if err != nil {
+		return
+	}
+	_, werr := tmp.Write(raw)
+	if cerr := tmp.Close(); werr != nil || cerr != nil || os.Rename(tmp.Name(), path) != nil {
+		os.Remove(tmp.Name())
+	}
+}
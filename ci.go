@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// --ci runs a review unattended and writes what it finds where CI systems look: SARIF, which GitHub code
+// scanning and GitLab turn into annotations on the changed lines, and optionally a JUnit report for test
+// dashboards. The agent reports each problem through the report_finding tool, so findings are structured as
+// they are made rather than parsed out of prose. The exit status is 1 when any finding is an error.
+var (
+	ciMode   = flag.Bool("ci", false, "Review unattended and write the findings as SARIF (and JUnit with --junit); exits 1 on any error-level finding")
+	ciBase   = flag.String("ci-base", "", "With --ci, review the changes since this git ref rather than the whole --ci-target")
+	ciTarget = flag.String("ci-target", ".", "With --ci, the directory to review")
+	sarifOut = flag.String("sarif", "tinyagent.sarif", "SARIF file written by --ci")
+	junitOut = flag.String("junit", "", "JUnit XML file written by --ci (default: none)")
+)
+
+const findingToolDef = `[
+		{"type":"function","function":{"name":"report_finding","description":"Record one problem found in the review. Call it once per distinct problem, as soon as you have confirmed it.","parameters":{"type":"object","properties":{
+			"path":{"type":"string","description":"File relative to current working directory"},
+			"line":{"type":"integer","description":"1-based line the problem is on"},
+			"severity":{"type":"string","enum":["error","warning","note"],"description":"error for bugs, warning for risky code, note for suggestions"},
+			"rule":{"type":"string","description":"Short kebab-case name for the kind of problem, such as nil-dereference or unchecked-error"},
+			"message":{"type":"string","description":"What is wrong and how to fix it, in a sentence or two"} },"required":["path","line","severity","message"]}}}
+		]`
+
+const ciMissionFormat = `Review %s for bugs and risky code. Call report_finding for every real problem, with the exact file and line; skip style nits. When the review is complete, reply with a one-paragraph summary.`
+
+// finding is one problem reported by a review.
+type finding struct {
+	Path     string `json:"path"`
+	Line     int    `json:"line"`
+	Severity string `json:"severity"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+var findings struct {
+	sync.Mutex
+	list []finding
+}
+
+var findingTools = registerToolset(&toolset{
+	def:      findingToolDef,
+	enabled:  func() bool { return *ciMode },
+	readOnly: true,
+	run: map[string]func(context.Context, string) (string, error){
+		"report_finding": func(ctx context.Context, args string) (string, error) {
+			var f finding
+			json.Unmarshal([]byte(args), &f)
+			return reportFinding(f)
+		},
+	},
+})
+
+func reportFinding(f finding) (string, error) {
+	if !filepath.IsLocal(f.Path) {
+		return "", fmt.Errorf("Permanent Error: Path %s is outside of current working directory", f.Path)
+	}
+	if f.Severity != "error" && f.Severity != "warning" && f.Severity != "note" {
+		return "", fmt.Errorf("severity must be error, warning, or note, not %q", f.Severity)
+	}
+	if strings.TrimSpace(f.Message) == "" {
+		return "", fmt.Errorf("message is required")
+	}
+	f.Path, f.Line = toolPath(filepath.Clean(f.Path)), max(f.Line, 1)
+	if f.Rule == "" {
+		f.Rule = "review"
+	}
+	findings.Lock()
+	findings.list = append(findings.list, f)
+	n := len(findings.list)
+	findings.Unlock()
+	return fmt.Sprintf("Recorded finding %d at %s:%d", n, f.Path, f.Line), nil
+}
+
+// runCI reviews the target or the changes since --ci-base, then writes the reports.
+func runCI(ctx context.Context, tools string) error {
+	subject := toolPath(*ciTarget)
+	if *ciBase != "" {
+		out, err := exec.Command("git", "diff", "--name-only", *ciBase+"...HEAD").Output()
+		if err != nil {
+			return fmt.Errorf("--ci-base %s: git diff failed: %v", *ciBase, err)
+		}
+		changed := strings.Fields(string(out))
+		if len(changed) == 0 {
+			event(slog.LevelInfo, fmt.Sprintf("\033[90mNothing changed since %s\033[0m\n", *ciBase), "nothing to review", "base", *ciBase)
+			return writeCIReports(nil)
+		}
+		subject = fmt.Sprintf("the changes since %s, in these files: %s. Use git_blame or study the files to see the changed code", *ciBase, strings.Join(changed, ", "))
+	}
+	m := *mission
+	if m == "" {
+		m = fmt.Sprintf(ciMissionFormat, subject)
+	}
+	var messages []ChatMessage
+	answer, err := runBotMission(ctx, tools, &messages, m, nil)
+	if err != nil {
+		return err
+	}
+	result(answer)
+
+	findings.Lock()
+	list := sortFindings(findings.list)
+	findings.Unlock()
+	if err := writeCIReports(list); err != nil {
+		return err
+	}
+	for _, f := range list {
+		if f.Severity == "error" {
+			event(slog.LevelWarn, fmt.Sprintf("\033[33m%d findings, some at error level\033[0m\n", len(list)), "review failed", "findings", len(list))
+			os.Exit(1)
+		}
+	}
+	return nil
+}
+
+// sortFindings returns a copy of list ordered by file and line.
+func sortFindings(list []finding) []finding {
+	list = append([]finding(nil), list...)
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Path != list[j].Path {
+			return list[i].Path < list[j].Path
+		}
+		return list[i].Line < list[j].Line
+	})
+	return list
+}
+
+// writeCIReports writes the SARIF and JUnit reports of list, which the caller has sorted.
+func writeCIReports(list []finding) error {
+	if err := writeSARIF(*sarifOut, list); err != nil {
+		return err
+	}
+	if *junitOut != "" {
+		if err := writeJUnit(*junitOut, list); err != nil {
+			return err
+		}
+	}
+	event(slog.LevelInfo, fmt.Sprintf("\033[90m%d findings written to %s\033[0m\n", len(list), *sarifOut), "findings written", "findings", len(list), "sarif", *sarifOut, "junit", *junitOut)
+	return nil
+}
+
+// writeSARIF writes findings as a SARIF 2.1.0 log with one run.
+func writeSARIF(path string, list []finding) error {
+	type message struct {
+		Text string `json:"text"`
+	}
+	type result struct {
+		RuleID    string  `json:"ruleId"`
+		Level     string  `json:"level"`
+		Message   message `json:"message"`
+		Locations []any   `json:"locations"`
+	}
+	var rules []map[string]any
+	seen := map[string]bool{}
+	results := []result{}
+	for _, f := range list {
+		if !seen[f.Rule] {
+			seen[f.Rule] = true
+			rules = append(rules, map[string]any{"id": f.Rule, "shortDescription": message{f.Rule}})
+		}
+		results = append(results, result{RuleID: f.Rule, Level: f.Severity, Message: message{f.Message}, Locations: []any{
+			map[string]any{"physicalLocation": map[string]any{
+				"artifactLocation": map[string]string{"uri": f.Path, "uriBaseId": "%SRCROOT%"},
+				"region":           map[string]int{"startLine": f.Line},
+			}},
+		}})
+	}
+	release := version
+	if release == "" {
+		release = "dev"
+	}
+	log := map[string]any{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []any{map[string]any{
+			"tool": map[string]any{"driver": map[string]any{
+				"name": "tinyagent", "version": release, "informationUri": "https://github.com/dans-stuff/tinyagent", "rules": rules,
+			}},
+			"results": results,
+		}},
+	}
+	raw, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(raw, '\n'), 0o644)
+}
+
+// writeJUnit writes one test case per finding, failing for errors and warnings, or a single passing case when
+// the review found nothing.
+func writeJUnit(path string, list []finding) error {
+	type failure struct {
+		Message string `xml:"message,attr"`
+		Type    string `xml:"type,attr"`
+		Text    string `xml:",chardata"`
+	}
+	type testcase struct {
+		Name      string   `xml:"name,attr"`
+		Classname string   `xml:"classname,attr"`
+		Failure   *failure `xml:"failure,omitempty"`
+		Output    string   `xml:"system-out,omitempty"`
+	}
+	type testsuite struct {
+		XMLName  xml.Name   `xml:"testsuite"`
+		Name     string     `xml:"name,attr"`
+		Tests    int        `xml:"tests,attr"`
+		Failures int        `xml:"failures,attr"`
+		Cases    []testcase `xml:"testcase"`
+	}
+	suite := testsuite{Name: "tinyagent review"}
+	for _, f := range list {
+		c := testcase{Name: fmt.Sprintf("%s at %s:%d", f.Rule, f.Path, f.Line), Classname: f.Path}
+		if f.Severity == "note" {
+			c.Output = f.Message
+		} else {
+			c.Failure = &failure{Message: f.Message, Type: f.Severity, Text: fmt.Sprintf("%s:%d: %s", f.Path, f.Line, f.Message)}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	if len(suite.Cases) == 0 {
+		suite.Cases = []testcase{{Name: "review", Classname: "tinyagent"}}
+	}
+	suite.Tests = len(suite.Cases)
+	raw, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), append(raw, '\n')...), 0o644)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// The usual next step after a mission is pasting its conclusion into an issue or PR, so answers can go straight
+// to the clipboard, via --copy for every answer or /copy for the last one.
+var copyResult = flag.Bool("copy", false, "Copy each final answer to the system clipboard")
+
+// clipboardCommands are tried in order; the first one installed wins.
+var clipboardCommands = map[string][][]string{
+	"darwin":  {{"pbcopy"}},
+	"windows": {{"powershell.exe", "-NoProfile", "-Command", "$input | Set-Clipboard"}, {"clip.exe"}},
+	"linux":   {{"wl-copy"}, {"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}},
+}
+
+// copyToClipboard uses the platform's clipboard tool, falling back to the OSC 52 escape, which most terminal
+// emulators honor even over SSH where no local clipboard tool can help.
+func copyToClipboard(text string) error {
+	for _, argv := range clipboardCommands[runtime.GOOS] {
+		if _, err := exec.LookPath(argv[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(argv[0], argv[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err == nil {
+			return nil
+		}
+	}
+	if isTerminal(os.Stdout) {
+		termMu.Lock()
+		fmt.Printf("\033]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+		termMu.Unlock()
+		return nil
+	}
+	return errors.New("no clipboard tool found (install wl-copy, xclip, or xsel)")
+}
+
+// copyAnswer copies an answer and reports the outcome.
+func copyAnswer(text string) {
+	if err := copyToClipboard(text); err != nil {
+		event(slog.LevelWarn, fmt.Sprintf("\033[33mCould not copy to clipboard: %v\033[0m\n", err), "clipboard copy failed", "err", err)
+		return
+	}
+	event(slog.LevelInfo, "\033[90m📋 Copied to clipboard\033[0m\n", "copied to clipboard", "bytes", len(text))
+}
+
+// lastAnswer returns the most recent final answer in the conversation.
+func lastAnswer(messages []ChatMessage) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if m := messages[i]; m.Role == "assistant" && len(m.ToolCalls) == 0 && strings.TrimSpace(m.Content) != "" {
+			return strings.TrimSpace(m.Content)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The cloud_cli tool lets an investigation look at the real infrastructure through the aws, gcloud, and az
+// CLIs, using whatever credentials they already have. It runs only commands the project allows in
+// .tinyagent/cloud.json, and only listing verbs, never get, which can return secret values:
+//
+//	{"aws": ["ec2 describe-instances", "s3 ls"], "gcloud": ["run services list"], "az": ["vm list", "vm show"]}
+//
+// Extra arguments such as --region or a filter may follow an allowed command.
+const cloudConfigFile = ".tinyagent/cloud.json"
+
+// maxCloudOutput bounds one result; a describe across a whole account is easily megabytes of JSON.
+const maxCloudOutput = 32 << 10
+
+// cloudCLIs are the CLIs the allowlist may name, with the environment that keeps each from paging or prompting.
+var cloudCLIs = map[string][]string{
+	"aws":    {"AWS_PAGER="},
+	"gcloud": {"CLOUDSDK_CORE_DISABLE_PROMPTS=1"},
+	"az":     {"AZURE_CORE_NO_COLOR=1", "AZURE_CORE_ONLY_SHOW_ERRORS=1"},
+}
+
+// cloudAllowlist maps each configured CLI to its allowed commands, split into words.
+var cloudAllowlist = sync.OnceValues(func() (map[string][][]string, error) {
+	raw, err := os.ReadFile(filepath.FromSlash(cloudConfigFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var config map[string][]string
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, fmt.Errorf("%s: %v", cloudConfigFile, err)
+	}
+	allowed := map[string][][]string{}
+	for cli, commands := range config {
+		if _, ok := cloudCLIs[cli]; !ok {
+			return nil, fmt.Errorf("%s: unknown CLI %q, want aws, gcloud, or az", cloudConfigFile, cli)
+		}
+		for _, command := range commands {
+			words := strings.Fields(command)
+			if len(words) == 0 || !listingVerb(words[len(words)-1]) {
+				return nil, fmt.Errorf("%s: %s %q does not end in a listing verb (describe, list, ls, or show)", cloudConfigFile, cli, command)
+			}
+			allowed[cli] = append(allowed[cli], words)
+		}
+	}
+	return allowed, nil
+})
+
+func listingVerb(verb string) bool {
+	return verb == "describe" || verb == "list" || verb == "ls" || verb == "show" ||
+		strings.HasPrefix(verb, "describe-") || strings.HasPrefix(verb, "list-")
+}
+
+var cloudWarning sync.Once
+
+var cloudTools = registerToolset(&toolset{
+	def: cloudToolDef(),
+	enabled: func() bool {
+		allowed, err := cloudAllowlist()
+		if err != nil {
+			cloudWarning.Do(func() {
+				event(slog.LevelWarn, fmt.Sprintf("\033[33mcloud_cli is off: %v\033[0m\n", err), "cloud allowlist invalid", "err", err)
+			})
+		}
+		return len(allowed) > 0
+	},
+	readOnly: true,
+	run: map[string]func(context.Context, string) (string, error){
+		"cloud_cli": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				CLI  string   `json:"cli"`
+				Args []string `json:"args"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			return cloudCLI(ctx, params.CLI, params.Args)
+		},
+	},
+})
+
+// cloudToolDef lists the allowed commands in the tool's description, so the model picks from them rather than
+// guessing and being refused.
+func cloudToolDef() string {
+	allowed, _ := cloudAllowlist()
+	var commands []string
+	for cli, list := range allowed {
+		for _, words := range list {
+			commands = append(commands, cli+" "+strings.Join(words, " "))
+		}
+	}
+	sort.Strings(commands)
+	description, _ := json.Marshal("Run a read-only cloud CLI command to inspect infrastructure. Allowed commands, which may be followed by options such as --region or filters: " + strings.Join(commands, "; "))
+	return fmt.Sprintf(`[
+		{"type":"function","function":{"name":"cloud_cli","description":%s,"parameters":{"type":"object","properties":{
+			"cli":{"type":"string","enum":["aws","gcloud","az"],"description":"Which CLI to run"},
+			"args":{"type":"array","items":{"type":"string"},"description":"Arguments after the CLI name, one per item, such as [\"ec2\", \"describe-instances\", \"--region\", \"us-east-1\"]"} },"required":["cli","args"]}}}
+		]`, description)
+}
+
+func cloudCLI(ctx context.Context, cli string, args []string) (string, error) {
+	allowed, _ := cloudAllowlist()
+	permitted := false
+	for _, words := range allowed[cli] {
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == strings.Join(words, " ") {
+			permitted = true
+			break
+		}
+	}
+	if !permitted {
+		return "", fmt.Errorf("Permanent Error: %s %s is not allowed in %s; use one of the commands in the tool description", cli, strings.Join(args, " "), cloudConfigFile)
+	}
+	// Every CLI has some way to read arguments from a file; none of the listing commands need one.
+	for _, arg := range args {
+		if strings.Contains(arg, "file://") || strings.Contains(arg, "fileb://") || strings.HasPrefix(arg, "@") {
+			return "", fmt.Errorf("Permanent Error: %s reads a local file, which cloud_cli does not allow", arg)
+		}
+	}
+	cmd := exec.CommandContext(ctx, cli, args...)
+	cmd.Env = append(os.Environ(), cloudCLIs[cli]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("%s %s failed: %s", cli, args[0], clip(msg))
+	}
+	return fmt.Sprintf("%s %s results\n%s", cli, strings.Join(args, " "), clipOutput(string(out), maxCloudOutput, false)), nil
+}
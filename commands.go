@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// Slash commands manage the session from the mission prompt, so starting over or saving doesn't mean restarting
+// the binary. Commands get the conversation to inspect or replace; one that wants to start a mission sets it.
+type slashCommand struct {
+	name, args, help string
+	run              func(messages *[]ChatMessage, args string)
+}
+
+var slashCommands []slashCommand
+
+func init() {
+	// Assigned in init because /help reads the table it belongs to.
+	slashCommands = []slashCommand{
+		{"help", "", "List the available commands", func(*[]ChatMessage, string) {
+			var b strings.Builder
+			for _, c := range slashCommands {
+				fmt.Fprintf(&b, "%-22s %s\n", strings.TrimSpace("/"+c.name+" "+c.args), c.help)
+			}
+			b.WriteString(`A line of """ starts a multi-line mission, ended by another """; a trailing \ also continues a line.` + "\n")
+			b.WriteString("While a mission runs, Enter pauses it to add guidance and Ctrl-C stops it.")
+			report("Commands", b.String())
+		}},
+		{"reset", "", "Forget the conversation and start fresh", func(messages *[]ChatMessage, _ string) {
+			*messages = (*messages)[:1]
+			event(slog.LevelInfo, "\033[90mConversation cleared\033[0m\n", "history reset")
+		}},
+		{"compact", "[focus]", "Replace the conversation with a brief of it, to free context for a long session", compactHistory},
+		{"cost", "", "Show what the session has cost so far", func(*[]ChatMessage, string) {
+			report("Session cost", costTable())
+		}},
+		{"stats", "", "Show latency and error rates per provider", func(*[]ChatMessage, string) {
+			report("Provider stats", statsTable())
+		}},
+		{"save", "[file]", "Save the transcript, to .tinyagent/sessions unless a file is given", func(messages *[]ChatMessage, args string) {
+			path, err := saveTranscript(*messages, args)
+			if err != nil {
+				event(slog.LevelError, fmt.Sprintf("\033[31mError: %v\n", err), "transcript save failed", "err", err)
+				return
+			}
+			event(slog.LevelInfo, fmt.Sprintf("\033[90mTranscript saved to %s\033[0m\n", path), "transcript saved", "path", path)
+		}},
+		{"fork", "[name]", "Copy the conversation into a new branch and continue there, to try another approach", func(messages *[]ChatMessage, args string) {
+			forkBranch(messages, args)
+		}},
+		{"switch", "<name>", "Continue in another branch of the conversation", func(messages *[]ChatMessage, args string) {
+			switchBranch(messages, args)
+		}},
+		{"branches", "", "List the branches with their last answers, to compare them", func(messages *[]ChatMessage, _ string) {
+			report("Branches", branchTable(*messages))
+		}},
+		{"drop", "<name>", "Discard a branch", func(_ *[]ChatMessage, args string) {
+			dropBranch(args)
+		}},
+		{"copy", "", "Copy the last answer to the clipboard", func(messages *[]ChatMessage, _ string) {
+			answer := lastAnswer(*messages)
+			if answer == "" {
+				event(slog.LevelWarn, "\033[33mNo answer to copy yet\033[0m\n", "nothing to copy")
+				return
+			}
+			copyAnswer(answer)
+		}},
+		{"comment", "<number>", "Post the last answer on a GitHub issue or pull request, or a GitLab merge request", func(messages *[]ChatMessage, args string) {
+			number, err := strconv.Atoi(strings.TrimLeft(strings.TrimSpace(args), "#!"))
+			answer := lastAnswer(*messages)
+			missions := sessionMissions(*messages)
+			switch {
+			case err != nil || number <= 0:
+				event(slog.LevelError, "\033[31mError: usage is /comment <issue or pull request number>\033[0m\n", "bad comment command", "args", args)
+			case answer == "" || len(missions) == 0:
+				event(slog.LevelWarn, "\033[33mNo answer to post yet\033[0m\n", "nothing to post")
+			default:
+				post, err := originPoster()
+				if err != nil {
+					event(slog.LevelError, fmt.Sprintf("\033[31mError: %v\033[0m\n", err), "comment failed", "err", err)
+					return
+				}
+				shareAnswer(post, number, missions[len(missions)-1], answer)
+			}
+		}},
+		{"edit", "[last]", "Write a mission in $EDITOR; with last, start from the previous mission to retry it", func(messages *[]ChatMessage, args string) {
+			initial := ""
+			if missions := sessionMissions(*messages); args == "last" && len(missions) > 0 {
+				initial = missions[len(missions)-1]
+			}
+			edited, err := editMission(initial)
+			if err != nil {
+				event(slog.LevelError, fmt.Sprintf("\033[31mError: %v\n", err), "editor failed", "err", err)
+				return
+			}
+			if edited == "" {
+				event(slog.LevelInfo, "\033[90mEmpty mission, cancelled\033[0m\n", "edit cancelled")
+				return
+			}
+			*mission = edited
+		}},
+		{"model", "[name [url]]", "Show or switch the model for the following turns, keeping the conversation", func(_ *[]ChatMessage, args string) {
+			fields := strings.Fields(args)
+			if len(fields) > 2 {
+				event(slog.LevelError, "\033[31mError: usage is /model [name [url]]\033[0m\n", "bad model command", "args", args)
+				return
+			}
+			if len(fields) > 0 {
+				*model = fields[0]
+			}
+			if len(fields) > 1 {
+				*apiURL = fields[1]
+			}
+			event(slog.LevelInfo, fmt.Sprintf("\033[90mModel \033[35m%s\033[90m at %s\033[0m\n", *model, *apiURL), "model", "model", *model, "url", *apiURL)
+		}},
+		{"history", "", "List past missions; run one again with !N, or !! for the last", func(*[]ChatMessage, string) {
+			past := missionHistory()
+			if len(past) == 0 {
+				report("Missions", "(none yet)")
+				return
+			}
+			var lines []string
+			for i := max(len(past)-20, 0); i < len(past); i++ {
+				lines = append(lines, fmt.Sprintf("%3d. %s", i+1, strings.ReplaceAll(past[i], "\n", " ")))
+			}
+			report("Missions", strings.Join(lines, "\n"))
+		}},
+		{"tools", "", "List the tools the model can call", func(*[]ChatMessage, string) {
+			var defs []struct {
+				Function struct {
+					Name        string `json:"name"`
+					Description string `json:"description"`
+				} `json:"function"`
+			}
+			json.Unmarshal([]byte(activeTools()), &defs)
+			var b strings.Builder
+			for _, d := range defs {
+				fmt.Fprintf(&b, "%-22s %s\n", d.Function.Name, d.Function.Description)
+			}
+			report("Tools", strings.TrimRight(b.String(), "\n"))
+		}},
+	}
+}
+
+// runSlashCommand runs input if it is a slash command and reports whether it was one. A mission that starts with
+// a path, like /etc/nginx/nginx.conf, is not taken for an unknown command.
+func runSlashCommand(messages *[]ChatMessage, input string) bool {
+	input = strings.TrimSpace(input)
+	if !strings.HasPrefix(input, "/") {
+		return false
+	}
+	name, args, _ := strings.Cut(input[1:], " ")
+	for _, c := range slashCommands {
+		if c.name == name {
+			c.run(messages, strings.TrimSpace(args))
+			return true
+		}
+	}
+	if strings.Contains(name, "/") {
+		return false
+	}
+	event(slog.LevelWarn, fmt.Sprintf("\033[33mUnknown command /%s, /help lists them\033[0m\n", name), "unknown command", "command", name)
+	return true
+}
+
+// sessionMissions recovers the missions typed so far from their user messages.
+func sessionMissions(messages []ChatMessage) []string {
+	prefix, _, _ := strings.Cut(userPromptFormat, "%s")
+	var missions []string
+	for _, m := range messages {
+		if m.Role == "user" && strings.HasPrefix(m.Content, prefix) {
+			missions = append(missions, strings.TrimPrefix(m.Content, prefix))
+		}
+	}
+	return missions
+}
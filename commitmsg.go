@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// `tinyagent commit-msg` writes a Conventional Commits message for the staged changes with one request to the
+// configured model, no tools involved. Given a file it fills that file in, which is what git's
+// prepare-commit-msg hook asks for, and `tinyagent commit-msg install` sets that hook up. Without a file it
+// prints the message.
+const commitPrompt = `You write git commit messages in the Conventional Commits format. The first line is type(scope): summary, at most 72 characters, in the imperative mood, where type is one of feat, fix, docs, style, refactor, perf, test, build, ci, chore, or revert and the scope is optional. If the change needs explaining, add a blank line and a body wrapped at 72 columns saying what changed and why. Reply with the message only.`
+
+// maxCommitDiff bounds the staged diff sent to the model; the stat summary always covers every file.
+const maxCommitDiff = 48 << 10
+
+// hookMarker identifies hooks installed by tinyagent, which are the only ones install overwrites.
+const hookMarker = "# installed by tinyagent commit-msg install"
+
+var messageFence = regexp.MustCompile("(?s)^```\\w*\\n(.*?)\\n?```$")
+
+// runCommitMsg implements the subcommand; args are flags followed by the hook's arguments, if any.
+func runCommitMsg(args []string) int {
+	flag.CommandLine.Parse(args)
+	*quiet = true // stdout is for the message alone
+	if err := setupUI(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	args = flag.Args()
+	if len(args) > 0 && args[0] == "install" {
+		return installCommitHook()
+	}
+	// git passes the message's source as the second argument; a message given with -m, a merge, a squash, or
+	// an amend already has one.
+	if len(args) > 1 && args[1] != "template" {
+		return 0
+	}
+
+	stat, err := exec.Command("git", "diff", "--cached", "--stat").Output()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "tinyagent commit-msg: not in a git repository")
+		return 2
+	}
+	if strings.TrimSpace(string(stat)) == "" {
+		fmt.Fprintln(os.Stderr, "tinyagent commit-msg: nothing is staged")
+		return 1
+	}
+	diff, _ := exec.Command("git", "diff", "--cached", "--no-color").Output()
+	if len(diff) > maxCommitDiff {
+		diff = append([]byte(strings.ToValidUTF8(string(diff[:maxCommitDiff]), "")), fmt.Sprintf("\n[diff truncated, %d more bytes]\n", len(diff)-maxCommitDiff)...)
+	}
+
+	msg, _, err := sendChatRequest(withPurpose(context.Background(), "commit message"), *model, []ChatMessage{
+		{Role: "system", Content: commitPrompt},
+		{Role: "user", Content: fmt.Sprintf("Files changed:\n%s\nStaged diff:\n%s", stat, diff)},
+	}, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tinyagent commit-msg: %v\n", err)
+		return 1
+	}
+	message := strings.TrimSpace(msg.Content)
+	if m := messageFence.FindStringSubmatch(message); m != nil {
+		message = strings.TrimSpace(m[1])
+	}
+	if message == "" {
+		fmt.Fprintln(os.Stderr, "tinyagent commit-msg: the model returned an empty message")
+		return 1
+	}
+
+	if len(args) == 0 {
+		fmt.Println(message)
+		return 0
+	}
+	// The file already holds git's comments, or a template, which stay below the message to edit.
+	existing, _ := os.ReadFile(args[0])
+	if err := os.WriteFile(args[0], []byte(message+"\n\n"+string(existing)), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "tinyagent commit-msg: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// installCommitHook writes a prepare-commit-msg hook running this binary, leaving any other hook alone.
+func installCommitHook() int {
+	out, err := exec.Command("git", "rev-parse", "--git-path", "hooks/prepare-commit-msg").Output()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "tinyagent commit-msg: not in a git repository")
+		return 2
+	}
+	path := strings.TrimSpace(string(out))
+	if existing, err := os.ReadFile(path); err == nil && !strings.Contains(string(existing), hookMarker) {
+		fmt.Fprintf(os.Stderr, "tinyagent commit-msg: %s already exists; add `tinyagent commit-msg \"$@\"` to it yourself\n", path)
+		return 1
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		exe = "tinyagent"
+	}
+	script := fmt.Sprintf("#!/bin/sh\n%s\nexec %q commit-msg \"$@\"\n", hookMarker, filepath.ToSlash(exe))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("Installed %s\n", path)
+	return 0
+}
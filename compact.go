@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// /compact trades a long conversation for a brief of it, for a session that should go on without dragging every
+// old tool result along. Unlike trimHistory, which condenses results one at a time as the budget demands, it is
+// deliberate and wholesale: the history becomes the system prompt and the brief.
+const compactPrompt = `You are handing a software investigation over to a colleague who will continue it. Write a brief of the session below: what the user asked for, what was found, with the exact file paths, names, and values that matter, what was changed or decided, and what is still open. Leave out dead ends unless they rule something out. Be concise and information dense.`
+
+const compactBriefFormat = "Our session so far, compacted into a brief:\n\n%s\n\nContinue from this; earlier tool results are gone, so call the tools again if you need details."
+
+// compactResultKeep is how much of each tool result the brief is written from; the calls and answers matter more.
+const compactResultKeep = 600
+
+func compactHistory(messages *[]ChatMessage, focus string) {
+	if len(*messages) < 3 {
+		event(slog.LevelInfo, "\033[90mNothing to compact\033[0m\n", "nothing to compact")
+		return
+	}
+	tools := activeTools()
+	before := historyTokens(*messages, tools)
+
+	// The transcript is bounded by the context budget too; if it is over, the latest part is what matters.
+	transcript := compactTranscript((*messages)[1:])
+	if limit := 3 * max(*contextTokens, 8000); len(transcript) > limit {
+		transcript = clipOutput(transcript, limit, true)
+	}
+	instructions := compactPrompt
+	if focus != "" {
+		instructions += " Focus on: " + focus
+	}
+	status := startStatus("🗜  Compacting")
+	msg, _, err := sendChatRequest(withPurpose(context.Background(), "compaction"), *model, []ChatMessage{
+		{Role: "system", Content: instructions},
+		{Role: "user", Content: transcript},
+	}, nil)
+	status.stop()
+	if err != nil {
+		event(slog.LevelError, fmt.Sprintf("\033[31mError: could not compact, the conversation is unchanged: %v\033[0m\n", err), "compaction failed", "err", err)
+		return
+	}
+	brief := strings.TrimSpace(msg.Content)
+	if brief == "" {
+		event(slog.LevelWarn, "\033[33mThe model wrote an empty brief, the conversation is unchanged\033[0m\n", "compaction failed", "err", "empty brief")
+		return
+	}
+
+	*messages = append((*messages)[:1], ChatMessage{Role: "user", Content: fmt.Sprintf(compactBriefFormat, brief)})
+	after := historyTokens(*messages, tools)
+	event(slog.LevelInfo, fmt.Sprintf("\033[90m🗜  Compacted the conversation from ~%d to ~%d tokens, reclaiming ~%d\033[0m\n%s\n", before, after, before-after, brief),
+		"history compacted", "tokens_before", before, "tokens_after", after, "brief", brief)
+}
+
+// compactTranscript writes the conversation as plain text for the model to summarize.
+func compactTranscript(messages []ChatMessage) string {
+	var b strings.Builder
+	for _, m := range messages {
+		switch m.Role {
+		case "user":
+			fmt.Fprintf(&b, "USER: %s\n\n", m.Content)
+		case "assistant":
+			for _, tc := range m.ToolCalls {
+				fmt.Fprintf(&b, "TOOL CALL: %s %s\n", tc.Function.Name, tc.Function.Arguments)
+			}
+			if m.Content != "" {
+				fmt.Fprintf(&b, "ASSISTANT: %s\n\n", m.Content)
+			}
+		case "tool":
+			content := m.Content
+			if len(content) > compactResultKeep {
+				content = clipOutput(content, compactResultKeep, false)
+			}
+			fmt.Fprintf(&b, "TOOL RESULT: %s\n\n", content)
+		}
+	}
+	return b.String()
+}
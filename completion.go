@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// `tinyagent completion bash|zsh|fish` prints a completion script built from the registered flags and recipes, so
+// it never drifts from the real flag set. It is a subcommand rather than a flag to keep it out of the usage text.
+//
+// flagValues lists the accepted values of enum-like flags; a func so values loaded at runtime can be offered.
+var flagValues = map[string]func() []string{
+	"format":     func() []string { return []string{"markdown", "json"} },
+	"log-format": func() []string { return []string{"color", "text", "json"} },
+	"log-level":  func() []string { return []string{"debug", "info", "warn", "error"} },
+}
+
+type completionFlag struct {
+	name, usage string
+	isBool      bool
+	isString    bool // only string flags complete file names, a number has nothing to offer
+	values      []string
+}
+
+func completionFlags() []completionFlag {
+	var flags []completionFlag
+	flag.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		cf := completionFlag{name: f.Name, usage: strings.SplitN(f.Usage, "\n", 2)[0], isBool: ok && b.IsBoolFlag()}
+		if g, ok := f.Value.(flag.Getter); ok {
+			_, cf.isString = g.Get().(string)
+		}
+		if values, ok := flagValues[f.Name]; ok {
+			cf.values = values()
+		}
+		flags = append(flags, cf)
+	})
+	sort.Slice(flags, func(i, j int) bool { return flags[i].name < flags[j].name })
+	return flags
+}
+
+// runCompletion handles the completion subcommand and returns the process exit code.
+func runCompletion(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: tinyagent completion bash|zsh|fish")
+		return 2
+	}
+	flags := completionFlags()
+	var b strings.Builder
+	switch args[0] {
+	case "bash":
+		b.WriteString("# bash completion for tinyagent; add to ~/.bashrc: source <(tinyagent completion bash)\n")
+		b.WriteString("_tinyagent() {\n\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n\tcase \"$prev\" in\n")
+		var names []string
+		for _, f := range flags {
+			names = append(names, "--"+f.name)
+			if f.values != nil {
+				fmt.Fprintf(&b, "\t--%[1]s|-%[1]s) COMPREPLY=($(compgen -W %[2]q -- \"$cur\")); return ;;\n", f.name, strings.Join(f.values, " "))
+			} else if f.isString {
+				fmt.Fprintf(&b, "\t--%[1]s|-%[1]s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", f.name)
+			} else if !f.isBool {
+				fmt.Fprintf(&b, "\t--%[1]s|-%[1]s) COMPREPLY=(); return ;;\n", f.name)
+			}
+		}
+		names = append(names, append([]string{"commit-msg", "completion", "config", "eval", "gen-tests", "recipes"}, recipeNames()...)...)
+		fmt.Fprintf(&b, "\tesac\n\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n}\ncomplete -o default -F _tinyagent tinyagent\n", strings.Join(names, " "))
+	case "zsh":
+		b.WriteString("#compdef tinyagent\n# zsh completion for tinyagent; add to ~/.zshrc: source <(tinyagent completion zsh)\n_tinyagent() {\n\t_arguments \\\n")
+		zshQuote := strings.NewReplacer("'", "'\\''", "[", "\\[", "]", "\\]", ":", "\\:")
+		for _, f := range flags {
+			spec := fmt.Sprintf("--%s[%s]", f.name, zshQuote.Replace(f.usage))
+			switch {
+			case f.values != nil:
+				spec += fmt.Sprintf(":%s:(%s)", f.name, strings.Join(f.values, " "))
+			case f.isString:
+				spec += fmt.Sprintf(":%s:_files", f.name)
+			case !f.isBool:
+				spec += fmt.Sprintf(":%s: ", f.name)
+			}
+			fmt.Fprintf(&b, "\t\t'%s' \\\n", spec)
+		}
+		fmt.Fprintf(&b, "\t\t'1:command:(commit-msg completion config eval gen-tests recipes %s)'\n}\n", strings.Join(recipeNames(), " "))
+		b.WriteString("if [ \"$funcstack[1]\" = \"_tinyagent\" ]; then _tinyagent \"$@\"; else compdef _tinyagent tinyagent; fi\n")
+	case "fish":
+		b.WriteString("# fish completion for tinyagent; save as ~/.config/fish/completions/tinyagent.fish\n")
+		fishQuote := strings.NewReplacer(`\`, `\\`, "'", `\'`)
+		for _, f := range flags {
+			fmt.Fprintf(&b, "complete -c tinyagent -l %s -d '%s'", f.name, fishQuote.Replace(f.usage))
+			switch {
+			case f.values != nil:
+				fmt.Fprintf(&b, " -x -a '%s'", strings.Join(f.values, " "))
+			case f.isString:
+				b.WriteString(" -r -F")
+			case !f.isBool:
+				b.WriteString(" -x")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("complete -c tinyagent -n __fish_use_subcommand -f -a commit-msg -d 'Write a commit message for the staged changes'\n")
+		b.WriteString("complete -c tinyagent -n __fish_use_subcommand -f -a completion -d 'Print a shell completion script'\n")
+		b.WriteString("complete -c tinyagent -n __fish_use_subcommand -f -a config -d 'Export or import a shareable config bundle'\n")
+		b.WriteString("complete -c tinyagent -n __fish_use_subcommand -f -a eval -d 'Run an evaluation suite and report pass rates and cost'\n")
+		b.WriteString("complete -c tinyagent -n __fish_use_subcommand -f -a gen-tests -d 'Write and run table-driven tests for a Go package'\n")
+		b.WriteString("complete -c tinyagent -n __fish_use_subcommand -f -a recipes -d 'List the mission recipes'\n")
+		for _, name := range recipeNames() {
+			fmt.Fprintf(&b, "complete -c tinyagent -n __fish_use_subcommand -f -a %s -d 'Recipe'\n", name)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unsupported shell %q, want bash, zsh, or fish\n", args[0])
+		return 2
+	}
+	fmt.Print(b.String())
+	return 0
+}
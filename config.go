@@ -0,0 +1,354 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A team's standard setup travels as one bundle: `tinyagent config export --model qwen3 setup.tgz` packs the
+// user's flag defaults, with any flags given to export on top, and the recipe and cloud allowlist files of the
+// user and the project; `tinyagent config import setup.tgz` unpacks them on another machine, showing each flag
+// setting and asking before it takes one that changes where requests go or what the agent may do. Flag defaults live
+// in tinyagent/defaults.json in the user config directory, {"model": "qwen3", "tool-workers": "8"}, and apply
+// to every run before its own flags. Secrets never go into a bundle: flags named like one are left out, and
+// credentials are cut from URLs.
+
+// configFiles maps each bundle entry to where it lives; "user/" is the user config directory.
+var configFiles = map[string]string{
+	"defaults.json":        "user/tinyagent/defaults.json",
+	"recipes.json":         "user/tinyagent/recipes.json",
+	"project/recipes.json": ".tinyagent/recipes.json",
+	"project/cloud.json":   cloudConfigFile,
+}
+
+// secretFlag spots settings that must not be shared; tokens themselves come from the environment, but a flag may
+// still carry one.
+var secretFlag = regexp.MustCompile(`(?i)token|secret|password|passwd|api[-_]?key|credential`)
+
+// machineFlags describe one run or one machine rather than a setup worth sharing.
+var machineFlags = map[string]bool{"mission": true, "mission-file": true, "attach": true, "version": true, "remote": true, "root": true}
+
+// trustFlags choose where requests go, what the agent may do, or who may drive it and hear from it, so an
+// imported bundle sets them only when the user accepts each one; a shared setup could otherwise send the API key
+// to another host, turn on writes, or hand the bot to someone else.
+var trustFlags = map[string]bool{"allow-writes": true, "telegram-writes": true, "policy": true, "policy-model": true,
+	"tool-limits": true, "github-comment": true, "gitlab-comment": true, "confirm-cost": true, "confirm-tokens": true, "dry-run": true,
+	"telegram-users": true, "slack-users": true, "slack-channels": true, "discord-channels": true, "email-to": true}
+
+var endpointFlag = regexp.MustCompile(`(?i)url|api|endpoint|server|addr|host`)
+
+// needsTrust reports whether an imported flag setting must be accepted by the user.
+func needsTrust(name, value string) bool {
+	if trustFlags[name] || endpointFlag.MatchString(name) {
+		return true
+	}
+	u, err := url.Parse(value)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+// configPath resolves a configFiles location on this machine.
+func configPath(location string) (string, error) {
+	rest, ok := strings.CutPrefix(location, "user/")
+	if !ok {
+		return filepath.FromSlash(location), nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.FromSlash(rest)), nil
+}
+
+// loadDefaults reads the flag defaults file; a missing one is no defaults.
+func loadDefaults() (map[string]string, error) {
+	path, err := configPath(configFiles["defaults.json"])
+	if err != nil {
+		return nil, nil
+	}
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var defaults map[string]string
+	if err := json.Unmarshal(raw, &defaults); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return defaults, nil
+}
+
+// applyDefaults sets the flags in the defaults file before the command line is parsed, which overrides them.
+func applyDefaults() {
+	defaults, err := loadDefaults()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tinyagent: ignoring flag defaults: %v\n", err)
+		return
+	}
+	for name, value := range defaults {
+		if err := flag.Set(name, value); err != nil {
+			fmt.Fprintf(os.Stderr, "tinyagent: ignoring flag default %s=%q: %v\n", name, value, err)
+		}
+	}
+}
+
+// shareable reports whether a flag setting may go into a bundle, and its value with any URL credentials cut.
+func shareable(name, value string) (string, bool) {
+	if secretFlag.MatchString(name) || machineFlags[name] {
+		return "", false
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return value, true
+	}
+	u.User = nil
+	query := u.Query()
+	for key := range query {
+		if secretFlag.MatchString(key) || strings.EqualFold(key, "key") {
+			query.Del(key)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), true
+}
+
+// runConfig implements the config subcommand and returns the process exit code.
+func runConfig(args []string) int {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		fmt.Fprintln(os.Stderr, "usage: tinyagent config export [flags] bundle.tgz | tinyagent config import [--overwrite] bundle.tgz")
+		return 2
+	}
+	var err error
+	if args[0] == "export" {
+		err = exportConfig(args[1:])
+	} else {
+		err = importConfig(args[1:])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tinyagent config %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func exportConfig(args []string) error {
+	// Flags may come before or after the bundle name.
+	var positional []string
+	for rest := args; ; rest = flag.Args()[1:] {
+		if err := flag.CommandLine.Parse(rest); err != nil {
+			return err
+		}
+		if flag.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flag.Arg(0))
+	}
+	if len(positional) != 1 {
+		return errors.New("give one bundle file to write")
+	}
+
+	defaults, err := loadDefaults()
+	if err != nil {
+		return err
+	}
+	if defaults == nil {
+		defaults = map[string]string{}
+	}
+	flag.Visit(func(f *flag.Flag) { defaults[f.Name] = f.Value.String() })
+	var left []string
+	for name, value := range defaults {
+		if shared, ok := shareable(name, value); ok {
+			defaults[name] = shared
+			continue
+		}
+		delete(defaults, name)
+		left = append(left, "--"+name)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	add := func(name string, content []byte) error {
+		fmt.Fprintf(os.Stderr, "  %s (%d bytes)\n", name, len(content))
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), ModTime: time.Now()}); err != nil {
+			return err
+		}
+		_, err := tw.Write(content)
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Writing %s:\n", positional[0])
+	if len(defaults) > 0 {
+		raw, _ := json.MarshalIndent(defaults, "", "  ")
+		if err := add("defaults.json", append(raw, '\n')); err != nil {
+			return err
+		}
+	}
+	for _, entry := range sortedKeys(configFiles) {
+		if entry == "defaults.json" {
+			continue
+		}
+		path, err := configPath(configFiles[entry])
+		if err != nil {
+			continue
+		}
+		raw, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := add(entry, raw); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if len(left) > 0 {
+		sort.Strings(left)
+		fmt.Fprintf(os.Stderr, "Left out as secret or machine-specific: %s\n", strings.Join(left, " "))
+	}
+	return os.WriteFile(positional[0], buf.Bytes(), 0o644)
+}
+
+func importConfig(args []string) error {
+	flags := flag.NewFlagSet("config import", flag.ContinueOnError)
+	overwrite := flags.Bool("overwrite", false, "Replace config files that already exist and differ")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("give one bundle file to read")
+	}
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("%s is not a config bundle: %v", flags.Arg(0), err)
+	}
+	tr := tar.NewReader(zr)
+	answers := bufio.NewReader(os.Stdin)
+	kept := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("%s is not a config bundle: %v", flags.Arg(0), err)
+		}
+		// Only the known entries are written, so a bundle can't place files anywhere else.
+		location, ok := configFiles[hdr.Name]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "  skipped %s, not a config file\n", hdr.Name)
+			continue
+		}
+		content, err := io.ReadAll(io.LimitReader(tr, 1<<20))
+		if err != nil {
+			return err
+		}
+		if !json.Valid(content) {
+			return fmt.Errorf("%s in the bundle is not valid JSON", hdr.Name)
+		}
+		switch hdr.Name {
+		case "defaults.json":
+			if content, err = reviewDefaults(content, answers); err != nil {
+				return err
+			}
+		case "project/cloud.json":
+			// It lists the commands the agent may run, so it is the user's call like a permission flag.
+			fmt.Fprintf(os.Stderr, "  the bundle allows these cloud commands:\n%s\n  accept them? [y/N] ", "    "+strings.ReplaceAll(strings.TrimSpace(string(content)), "\n", "\n    "))
+			answer, _ := answers.ReadString('\n')
+			if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+				fmt.Fprintf(os.Stderr, "  left out %s\n", hdr.Name)
+				continue
+			}
+		}
+		path, err := configPath(location)
+		if err != nil {
+			return err
+		}
+		existing, err := os.ReadFile(path)
+		switch {
+		case err == nil && bytes.Equal(existing, content):
+			fmt.Fprintf(os.Stderr, "  %s is already up to date\n", path)
+			continue
+		case err == nil && !*overwrite:
+			fmt.Fprintf(os.Stderr, "  kept %s, which differs; import with --overwrite to replace it\n", path)
+			kept++
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "  wrote %s\n", path)
+	}
+	if kept > 0 {
+		return fmt.Errorf("%d existing files were kept", kept)
+	}
+	return nil
+}
+
+// reviewDefaults shows every flag setting of an imported defaults file and returns the file with only the ones
+// that may be imported: secrets and machine settings are dropped as on export, and endpoint and permission
+// flags are kept only if the user says so.
+func reviewDefaults(content []byte, answers *bufio.Reader) ([]byte, error) {
+	var defaults map[string]string
+	if err := json.Unmarshal(content, &defaults); err != nil {
+		return nil, fmt.Errorf("defaults.json in the bundle: %v", err)
+	}
+	fmt.Fprintln(os.Stderr, "  flag defaults in the bundle:")
+	for _, name := range sortedKeys(defaults) {
+		value, ok := shareable(name, defaults[name])
+		switch {
+		case !ok:
+			fmt.Fprintf(os.Stderr, "    left out --%s, which is secret or machine-specific\n", name)
+			delete(defaults, name)
+			continue
+		case needsTrust(name, value):
+			fmt.Fprintf(os.Stderr, "    --%s=%s decides where requests go, what the agent may do, or who may use it; accept it? [y/N] ", name, value)
+			answer, _ := answers.ReadString('\n')
+			if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+				fmt.Fprintf(os.Stderr, "    left out --%s\n", name)
+				delete(defaults, name)
+				continue
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "    --%s=%s\n", name, value)
+		}
+		defaults[name] = value
+	}
+	raw, _ := json.MarshalIndent(defaults, "", "  ")
+	return append(raw, '\n'), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
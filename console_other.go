@@ -0,0 +1,44 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// enableColor reports whether f can render ANSI escapes; every Unix terminal can.
+func enableColor(f *os.File) bool {
+	return true
+}
+
+// sttyState holds the terminal settings to restore after cbreak(true), or "" when nothing was changed.
+var sttyState string
+
+// cbreak switches the terminal to unbuffered input without echo, keeping Ctrl-C as a signal, and back. It
+// shells out to stty rather than pulling in a terminal package; false means the mode could not be changed.
+func cbreak(on bool) bool {
+	stty := func(args ...string) (string, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = os.Stdin
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	if !on {
+		if sttyState != "" {
+			stty(sttyState)
+			sttyState = ""
+		}
+		return true
+	}
+	state, err := stty("-g")
+	if err != nil {
+		return false
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return false
+	}
+	sttyState = state
+	return true
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+var setConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// enableColor turns on ANSI escape handling for a Windows console. Terminals that already support it, such as
+// Windows Terminal, accept the flag as a no-op; legacy consoles that refuse it get plain output instead.
+func enableColor(f *os.File) bool {
+	const enableVirtualTerminalProcessing = 0x0004
+	var mode uint32
+	handle := syscall.Handle(f.Fd())
+	if syscall.GetConsoleMode(handle, &mode) != nil {
+		return false // redirected, or a NUL device that only looks like a character device
+	}
+	ok, _, _ := setConsoleMode.Call(uintptr(handle), uintptr(mode|enableVirtualTerminalProcessing))
+	return ok != 0
+}
+
+// cbreak is unsupported on Windows, whose console already offers its own line history, so input stays buffered.
+func cbreak(on bool) bool {
+	return false
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+// Every request's cost is estimated before it is sent, so a huge history pointed at an expensive model is caught
+// before it is paid for rather than in the session report. Above --confirm-cost the user is asked first; with
+// nobody at the terminal to ask, the request is refused. The estimate is the prompt at ~4 bytes per token plus
+// a full max_tokens reply, an upper bound.
+var (
+	inputPrice  = flag.Float64("input-price", 0.10, "Price of prompt tokens in dollars per million, for cost reports and --confirm-cost")
+	outputPrice = flag.Float64("output-price", 0.40, "Price of completion tokens in dollars per million, for cost reports and --confirm-cost")
+	confirmCost = flag.Float64("confirm-cost", 10, "Ask before sending an LLM request estimated to cost more than this many cents (0 disables)")
+)
+
+var errCostDeclined = errors.New("request declined over its estimated cost")
+
+// costConfirmed is set once the user answers "always", ending the questions for the session.
+var costConfirmed atomic.Bool
+
+// requestCost prices a request in dollars.
+func requestCost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*(*inputPrice) + float64(completionTokens)*(*outputPrice)) / 1_000_000
+}
+
+// confirmRequestCost asks before a request estimated above --confirm-cost is sent, returning errCostDeclined
+// when it shouldn't be. The warm-up is a fixed one-line prompt, never worth asking about.
+func confirmRequestCost(ctx context.Context, body []byte, maxTokens int) error {
+	tokens := estimateTokens(string(body))
+	cents := requestCost(tokens, maxTokens) * 100
+	if *confirmCost <= 0 || cents <= *confirmCost || costConfirmed.Load() || purposeOf(ctx) == "warm-up" {
+		return nil
+	}
+	answer, ok := ask(fmt.Sprintf("\n\033[33m⚠️  The next request is ~%d tokens, up to ~%.2fc (threshold %gc). \033[34mSend it? [y]es, [a]lways, [N]o\033[90m > \033[0m", tokens, cents, *confirmCost))
+	if !ok {
+		event(slog.LevelError, fmt.Sprintf("\033[31mError: a request of ~%d tokens, up to ~%.2fc, is over --confirm-cost %gc, and nobody is at the terminal to confirm it\033[0m\n", tokens, cents, *confirmCost),
+			"request cost declined", "tokens", tokens, "cents", cents, "threshold", *confirmCost, "reason", "no terminal")
+		return errCostDeclined
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "a", "always":
+		costConfirmed.Store(true)
+		return nil
+	case "y", "yes":
+		return nil
+	}
+	event(slog.LevelInfo, "\033[90mRequest not sent\033[0m\n", "request cost declined", "tokens", tokens, "cents", cents, "threshold", *confirmCost, "reason", "user")
+	return errCostDeclined
+}
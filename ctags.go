@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// find_symbol answers "where is X defined" from a ctags index, for any of the languages ctags knows and
+// without gopls' startup cost. It reads the project's own tags file when there is one, and otherwise builds
+// one with universal-ctags on first use, kept with the cache so it never shows up in git.
+var tagsFile = flag.String("tags", "tags", "ctags index used by find_symbol; built with ctags when missing")
+
+const ctagsToolDef = `[
+		{"type":"function","function":{"name":"find_symbol","description":"Find where a symbol (function, type, class, method, constant) is defined anywhere in the codebase, in any language, from a ctags index.","parameters":{"type":"object","properties":{
+			"name":{"type":"string","description":"Exact symbol name, such as parseConfig or HttpClient"} },"required":["name"]}}}
+		]`
+
+// maxSymbolMatches bounds one answer; common names like New or init match in every package.
+const maxSymbolMatches = 50
+
+var ctagsTools = registerToolset(&toolset{
+	def: ctagsToolDef,
+	enabled: func() bool {
+		if *remote != "" {
+			return false // the index points into local files
+		}
+		if _, err := os.Stat(*tagsFile); err == nil {
+			return true
+		}
+		_, err := exec.LookPath("ctags")
+		return err == nil
+	},
+	readOnly: true,
+	run: map[string]func(context.Context, string) (string, error){
+		"find_symbol": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Name string `json:"name"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			return findSymbol(ctx, params.Name)
+		},
+	},
+})
+
+var buildTags struct {
+	sync.Mutex
+	path string
+}
+
+// tagsPath returns the index to read, building it the first time when the project has none.
+func tagsPath(ctx context.Context) (string, error) {
+	if _, err := os.Stat(*tagsFile); err == nil {
+		return *tagsFile, nil
+	}
+	buildTags.Lock()
+	defer buildTags.Unlock()
+	if buildTags.path != "" {
+		return buildTags.path, nil
+	}
+	dir := *cacheDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	ignore := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		os.WriteFile(ignore, []byte("*\n"), 0o644)
+	}
+	path := filepath.Join(dir, "tags")
+	progress(ctx, "building the ctags index")
+	cmd := exec.CommandContext(ctx, "ctags", "-R", "--fields=+nK", "--exclude=.git", "--exclude=.tinyagent", "--exclude=node_modules", "--exclude=vendor", "-f", path, ".")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("ctags failed: %v: %s", err, clip(strings.TrimSpace(string(out))))
+	}
+	buildTags.path = path
+	return path, nil
+}
+
+func findSymbol(ctx context.Context, name string) (string, error) {
+	if name = strings.TrimSpace(name); name == "" {
+		return "", fmt.Errorf("Permanent Error: name is required")
+	}
+	path, err := tagsPath(ctx)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var matches []string
+	total := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		// name<TAB>file<TAB>address;"<TAB>kind<TAB>fields...
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 3 || fields[0] != name {
+			continue
+		}
+		total++
+		if len(matches) < maxSymbolMatches {
+			matches = append(matches, describeTag(fields))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if total == 0 {
+		return fmt.Sprintf("find_symbol %s results\nNo definition of %s in the index.", name, name), nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "find_symbol %s results (%d definitions)\n", name, total)
+	for _, m := range matches {
+		fmt.Fprintf(&b, "- %s\n", m)
+	}
+	if total > len(matches) {
+		fmt.Fprintf(&b, "(%d more definitions)\n", total-len(matches))
+	}
+	return b.String(), nil
+}
+
+// describeTag renders one tags entry as file:line, kind, and scope, with the defining line. Indexes built
+// without line numbers give a search pattern instead, which is looked up in the file.
+func describeTag(fields []string) string {
+	file, address := filepath.ToSlash(fields[1]), fields[2]
+	kind, line, scope := "", 0, ""
+	for _, field := range fields[3:] {
+		key, value, ok := strings.Cut(field, ":")
+		switch {
+		case !ok:
+			kind = field // the short kind of indexes without extension fields
+		case key == "kind":
+			kind = value
+		case key == "line":
+			line, _ = strconv.Atoi(value)
+		case key == "class" || key == "struct" || key == "interface" || key == "namespace" || key == "module" || key == "implementation" || key == "typeref":
+			scope = fmt.Sprintf(" in %s %s", key, value)
+		}
+	}
+	address, _, _ = strings.Cut(address, ";\"")
+	if n, err := strconv.Atoi(address); err == nil && line == 0 {
+		line = n
+	}
+	pattern := ""
+	if strings.HasPrefix(address, "/^") {
+		pattern = strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(address, "/^"), "/"), "$")
+		pattern = strings.ReplaceAll(pattern, `\/`, "/")
+	}
+	source := pattern
+	if raw, err := os.ReadFile(fields[1]); err == nil {
+		lines := strings.Split(string(raw), "\n")
+		if line == 0 && pattern != "" {
+			for i, l := range lines {
+				if l == pattern {
+					line = i + 1
+					break
+				}
+			}
+		}
+		if line > 0 && line <= len(lines) {
+			source = lines[line-1]
+		}
+	}
+	where := file
+	if line > 0 {
+		where = fmt.Sprintf("%s:%d", file, line)
+	}
+	if kind != "" {
+		kind = " (" + kind + scope + ")"
+	}
+	return fmt.Sprintf("%s%s: %s", where, kind, clip(strings.TrimSpace(source)))
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change, as in `diff -u`.
+const diffContext = 3
+
+// maxDiffEdits bounds the Myers search; rewrites beyond it are shown as a whole-file replacement.
+const maxDiffEdits = 1000
+
+type diffOp struct {
+	kind byte // ' ', '-', or '+'
+	text string
+}
+
+// diffLines computes a shortest edit script between a and b with Myers' algorithm, which stays fast on the
+// mostly-unchanged files an edit produces.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	off := n + m
+	v := make([]int, 2*off+2)
+	var trace [][]int
+	for d := 0; d <= off; d++ {
+		if d > maxDiffEdits {
+			// A near-total rewrite: the trace would cost too much memory, and a line diff says little anyway.
+			var ops []diffOp
+			for _, line := range a {
+				ops = append(ops, diffOp{'-', line})
+			}
+			for _, line := range b {
+				ops = append(ops, diffOp{'+', line})
+			}
+			return ops
+		}
+		// Step d reads only diagonals -d to d, so that is all of v the backtrack needs kept.
+		trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, d)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack walks the trace back from the end; trace[d] holds diagonals -d to d, diagonal k at index k+d.
+func backtrack(trace [][]int, a, b []string, d int) []diffOp {
+	x, y := len(a), len(b)
+	var ops []diffOp
+	for ; d > 0; d-- {
+		v, off := trace[d], d
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[off+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			ops = append(ops, diffOp{' ', a[x]})
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{'+', b[y]})
+		} else {
+			x--
+			ops = append(ops, diffOp{'-', a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x, y = x-1, y-1
+		ops = append(ops, diffOp{' ', a[x]})
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// noNewline follows a last line that has no newline, as diff marks it; being part of the line, it also makes
+// adding or removing that newline a change.
+const noNewline = "\n\\ No newline at end of file"
+
+// unifiedDiff renders the change from before to after as a unified diff, or "" when nothing changed.
+func unifiedDiff(path, before, after string) string {
+	a, b := splitLines(before), splitLines(after)
+	if len(a) > 0 && !strings.HasSuffix(before, "\n") {
+		a[len(a)-1] += noNewline
+	}
+	if len(b) > 0 && !strings.HasSuffix(after, "\n") {
+		b[len(b)-1] += noNewline
+	}
+	ops := diffLines(a, b)
+
+	var out strings.Builder
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// Grow the hunk while the next change is within two contexts of the last one.
+		start := max(i-diffContext, 0)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			} else if j-end > 2*diffContext {
+				break
+			}
+		}
+		end = min(end+diffContext+1, len(ops))
+
+		oldStart, newStart := 1, 1
+		for _, op := range ops[:start] {
+			if op.kind != '+' {
+				oldStart++
+			}
+			if op.kind != '-' {
+				newStart++
+			}
+		}
+		oldLen, newLen := 0, 0
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				oldLen++
+			}
+			if op.kind != '-' {
+				newLen++
+			}
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", toolPath(path), toolPath(path))
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldStart, oldLen), hunkRange(newStart, newLen))
+		for _, op := range ops[start:end] {
+			out.WriteString(string(op.kind) + op.text + "\n")
+		}
+		i = end
+	}
+	return out.String()
+}
+
+// hunkRange formats a hunk side as diff does: an empty side names the line before it.
+func hunkRange(start, length int) string {
+	if length == 0 {
+		start--
+	}
+	if length == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, length)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
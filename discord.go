@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --discord is the Slack bot for Discord. A mention of the bot in one of the --discord-channels starts a mission
+// in a new thread, where progress and the answer are posted, and any later message in that thread is a follow-up
+// in the same conversation. With --allow-writes, each proposed change is posted as a diff for someone in the
+// thread to approve with ✅ or reject with ❌, or to answer with what to do instead. DISCORD_BOT_TOKEN
+// authenticates, and the bot needs the Message Content intent.
+var (
+	discordMode     = flag.Bool("discord", false, "Run as a Discord bot, taking missions from mentions in the --discord-channels (needs DISCORD_BOT_TOKEN)")
+	discordChannels = flag.String("discord-channels", "", "Comma-separated IDs of the Discord channels the bot takes missions in")
+	discordAPI      = flag.String("discord-api", "https://discord.com/api/v10", "Discord API base URL")
+)
+
+const (
+	maxDiscordText      = 2000 // Discord rejects longer messages
+	discordApprovalWait = 30 * time.Minute
+	// Guilds, guild messages, guild message reactions, and message content.
+	discordIntents = 1<<0 | 1<<9 | 1<<10 | 1<<15
+)
+
+var discordMention = regexp.MustCompile(`<@!?\d+>`)
+
+type discordMission struct {
+	thread, user, text string
+}
+
+// discordBot is the state shared between the gateway reader and the mission worker.
+type discordBot struct {
+	token, tools string
+	self         string // the bot's user ID, from READY
+	allowed      map[string]bool
+
+	mu        sync.Mutex
+	threads   map[string]*[]ChatMessage // conversation of each thread the bot started
+	approvals map[string]*discordApproval
+}
+
+// discordApproval is a proposed change waiting in a thread for a reaction or a reply.
+type discordApproval struct {
+	message string
+	answer  chan string
+}
+
+type discordMessage struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	Content   string `json:"content"`
+	Author    struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+		Bot      bool   `json:"bot"`
+	} `json:"author"`
+	Mentions []struct {
+		ID string `json:"id"`
+	} `json:"mentions"`
+}
+
+func (b *discordBot) call(ctx context.Context, method, path string, body, v any) error {
+	release := version
+	if release == "" {
+		release = "dev"
+	}
+	header := http.Header{"Authorization": {"Bot " + b.token}, "User-Agent": {"DiscordBot (https://github.com/dans-stuff/tinyagent, " + release + ")"}}
+	return sendJSON(ctx, method, strings.TrimSuffix(*discordAPI, "/")+path, header, body, v)
+}
+
+// post sends text to a channel or thread, split to fit, and returns the last message's ID. Failing to post is
+// reported but never stops the bot.
+func (b *discordBot) post(ctx context.Context, channel, text string) string {
+	var sent discordMessage
+	for _, part := range splitMessage(text, maxDiscordText) {
+		if err := b.call(ctx, "POST", "/channels/"+channel+"/messages", map[string]string{"content": part}, &sent); err != nil {
+			event(slog.LevelWarn, fmt.Sprintf("\033[33mCould not post to Discord: %v\033[0m\n", err), "discord post failed", "channel", channel, "err", err)
+			return ""
+		}
+	}
+	return sent.ID
+}
+
+// runDiscord serves missions from Discord until ctx ends, reconnecting to the gateway whenever it drops.
+func runDiscord(ctx context.Context, tools string) error {
+	b := &discordBot{token: os.Getenv("DISCORD_BOT_TOKEN"), tools: tools, allowed: map[string]bool{},
+		threads: map[string]*[]ChatMessage{}, approvals: map[string]*discordApproval{}}
+	if b.token == "" {
+		return fmt.Errorf("--discord needs DISCORD_BOT_TOKEN")
+	}
+	for _, id := range strings.Split(*discordChannels, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			b.allowed[id] = true
+		}
+	}
+	if len(b.allowed) == 0 {
+		return fmt.Errorf("--discord needs --discord-channels, the channels it may take missions in")
+	}
+	missions := make(chan discordMission, 64)
+	go func() {
+		for m := range missions {
+			b.runMission(ctx, m)
+		}
+	}()
+
+	for backoff := time.Second; ctx.Err() == nil; {
+		err := b.serveGateway(ctx, missions)
+		if err == nil {
+			backoff = time.Second
+			continue
+		}
+		event(slog.LevelWarn, fmt.Sprintf("\033[33mDiscord connection lost: %v, reconnecting in %s\033[0m\n", err, backoff), "discord disconnected", "err", err, "retry_in", backoff.String())
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+	return ctx.Err()
+}
+
+// serveGateway holds one gateway session: it identifies, keeps the heartbeat going, and turns messages and
+// reactions into missions and approvals. It returns nil when Discord asks for a reconnect.
+func (b *discordBot) serveGateway(ctx context.Context, missions chan<- discordMission) error {
+	var gateway struct {
+		URL string `json:"url"`
+	}
+	if err := b.call(ctx, "GET", "/gateway/bot", nil, &gateway); err != nil {
+		return err
+	}
+	ws, err := dialWebsocket(ctx, gateway.URL+"/?v=10&encoding=json")
+	if err != nil {
+		return err
+	}
+	defer ws.close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			ws.close()
+		case <-done:
+		}
+	}()
+
+	var seq sync.Mutex
+	var last *int64
+	heartbeat := func() error {
+		seq.Lock()
+		raw, _ := json.Marshal(map[string]any{"op": 1, "d": last})
+		seq.Unlock()
+		return ws.send(raw)
+	}
+	for {
+		raw, err := ws.read()
+		if err != nil {
+			return err
+		}
+		var frame struct {
+			Op int             `json:"op"`
+			T  string          `json:"t"`
+			S  *int64          `json:"s"`
+			D  json.RawMessage `json:"d"`
+		}
+		if err := json.Unmarshal(raw, &frame); err != nil {
+			continue
+		}
+		if frame.S != nil {
+			seq.Lock()
+			last = frame.S
+			seq.Unlock()
+		}
+
+		switch frame.Op {
+		case 10: // hello: start the heartbeat, then identify
+			var hello struct {
+				Interval int `json:"heartbeat_interval"`
+			}
+			json.Unmarshal(frame.D, &hello)
+			go func() {
+				ticker := time.NewTicker(time.Duration(hello.Interval) * time.Millisecond)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-done:
+						return
+					case <-ticker.C:
+						if heartbeat() != nil {
+							return
+						}
+					}
+				}
+			}()
+			identify, _ := json.Marshal(map[string]any{"op": 2, "d": map[string]any{
+				"token":      b.token,
+				"intents":    discordIntents,
+				"properties": map[string]string{"os": "linux", "browser": "tinyagent", "device": "tinyagent"},
+			}})
+			if err := ws.send(identify); err != nil {
+				return err
+			}
+		case 1: // the gateway wants a heartbeat now
+			if err := heartbeat(); err != nil {
+				return err
+			}
+		case 7, 9: // reconnect, invalid session
+			return nil
+		case 0:
+			b.dispatch(ctx, frame.T, frame.D, missions)
+		}
+	}
+}
+
+func (b *discordBot) dispatch(ctx context.Context, kind string, data json.RawMessage, missions chan<- discordMission) {
+	switch kind {
+	case "READY":
+		var ready struct {
+			User struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		}
+		json.Unmarshal(data, &ready)
+		b.self = ready.User.ID
+		event(slog.LevelInfo, "\033[32mConnected to Discord, waiting for missions\033[0m\n", "discord connected", "user", b.self)
+
+	case "MESSAGE_CREATE":
+		var m discordMessage
+		json.Unmarshal(data, &m)
+		if m.Author.Bot {
+			return
+		}
+		text := strings.TrimSpace(discordMention.ReplaceAllString(m.Content, ""))
+		b.mu.Lock()
+		_, followUp := b.threads[m.ChannelID]
+		pending := b.approvals[m.ChannelID]
+		b.mu.Unlock()
+		switch {
+		case pending != nil:
+			// A reply while a change awaits approval says what to do instead.
+			select {
+			case pending.answer <- text:
+			default:
+			}
+		case followUp && text != "":
+			b.queue(ctx, missions, discordMission{thread: m.ChannelID, user: m.Author.Username, text: text})
+		case b.allowed[m.ChannelID] && b.mentioned(m):
+			if text == "" {
+				b.post(ctx, m.ChannelID, "Tell me what to investigate, like: where is the retry logic for uploads?")
+				return
+			}
+			title, _, _ := strings.Cut(text, "\n")
+			var thread struct {
+				ID string `json:"id"`
+			}
+			path := fmt.Sprintf("/channels/%s/messages/%s/threads", m.ChannelID, m.ID)
+			if err := b.call(ctx, "POST", path, map[string]string{"name": clip(title)}, &thread); err != nil {
+				event(slog.LevelWarn, fmt.Sprintf("\033[33mCould not start a Discord thread: %v\033[0m\n", err), "discord thread failed", "channel", m.ChannelID, "err", err)
+				return
+			}
+			b.mu.Lock()
+			b.threads[thread.ID] = &[]ChatMessage{}
+			b.mu.Unlock()
+			b.queue(ctx, missions, discordMission{thread: thread.ID, user: m.Author.Username, text: text})
+		}
+
+	case "MESSAGE_REACTION_ADD":
+		var r struct {
+			UserID    string `json:"user_id"`
+			ChannelID string `json:"channel_id"`
+			MessageID string `json:"message_id"`
+			Emoji     struct {
+				Name string `json:"name"`
+			} `json:"emoji"`
+		}
+		json.Unmarshal(data, &r)
+		b.mu.Lock()
+		pending := b.approvals[r.ChannelID]
+		b.mu.Unlock()
+		if r.UserID == b.self || pending == nil || pending.message != r.MessageID {
+			return
+		}
+		answer := map[string]string{"✅": "y", "❌": "n"}[r.Emoji.Name]
+		if answer != "" {
+			select {
+			case pending.answer <- answer:
+			default:
+			}
+		}
+	}
+}
+
+func (b *discordBot) mentioned(m discordMessage) bool {
+	for _, u := range m.Mentions {
+		if u.ID == b.self {
+			return true
+		}
+	}
+	return false
+}
+
+// queue hands m to the mission runner. A full queue turns it away rather than blocking, since the gateway reader
+// calling this also delivers the answers a running mission's approvals wait on.
+func (b *discordBot) queue(ctx context.Context, missions chan<- discordMission, m discordMission) {
+	waiting := len(missions)
+	select {
+	case missions <- m:
+		if waiting > 0 {
+			go b.post(ctx, m.thread, fmt.Sprintf("Queued behind %d other missions.", waiting))
+		}
+	default:
+		go b.post(ctx, m.thread, "The queue is full, try again once some missions have finished.")
+	}
+}
+
+func (b *discordBot) runMission(ctx context.Context, m discordMission) {
+	event(slog.LevelInfo, fmt.Sprintf("\033[34m💬 Discord mission from %s:\033[0m %s\n", m.user, m.text), "discord mission", "user", m.user, "thread", m.thread, "mission", m.text)
+	b.post(ctx, m.thread, "🧭 On it.")
+	b.mu.Lock()
+	messages := b.threads[m.thread]
+	b.mu.Unlock()
+	missionCtx := withApprover(ctx, func(question, diff string) string { return b.approve(ctx, m.thread, question, diff) })
+	answer, err := runBotMission(missionCtx, b.tools, messages, m.text, func(line string) { b.post(ctx, m.thread, line) })
+	if err != nil {
+		b.post(ctx, m.thread, fmt.Sprintf("⚠️ The mission failed: %v", err))
+		return
+	}
+	result(answer)
+	b.post(ctx, m.thread, answer)
+}
+
+// approve posts a proposed change in the thread and waits for a reaction or a reply. Nobody answering in time
+// counts as a rejection, so an unattended bot never writes.
+func (b *discordBot) approve(ctx context.Context, thread, question, diff string) string {
+	fence := "```"
+	body := strings.ReplaceAll(strings.TrimRight(diff, "\n"), fence, "'''")
+	room := maxDiscordText - len(question) - 64
+	if len(body) > room {
+		body = strings.ToValidUTF8(body[:room], "") + "\n…"
+	}
+	id := b.post(ctx, thread, fmt.Sprintf("%s\n%sdiff\n%s\n%s\nReact ✅ to apply or ❌ to reject, or reply with what to do instead.", question, fence, body, fence))
+	if id == "" {
+		return "n"
+	}
+	pending := &discordApproval{message: id, answer: make(chan string, 1)}
+	b.mu.Lock()
+	b.approvals[thread] = pending
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.approvals, thread)
+		b.mu.Unlock()
+	}()
+	for _, emoji := range []string{"✅", "❌"} {
+		b.call(ctx, "PUT", fmt.Sprintf("/channels/%s/messages/%s/reactions/%s/@me", thread, id, url.PathEscape(emoji)), nil, nil)
+	}
+	select {
+	case answer := <-pending.answer:
+		return answer
+	case <-time.After(discordApprovalWait):
+		b.post(ctx, thread, "No answer, so the change was not applied.")
+		return "n"
+	case <-ctx.Done():
+		return "n"
+	}
+}
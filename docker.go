@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// The docker tools let the agent check the code against what is actually running locally: which containers
+// and images exist, how a container is configured, and what it logged. They only read, except for
+// docker_restart, which is offered with --allow-writes and asks first like every other change.
+const dockerToolDef = `[
+		{"type":"function","function":{"name":"docker_ps","description":"List Docker containers with image, status, ports, and name.","parameters":{"type":"object","properties":{
+			"all":{"type":"boolean","default":false,"description":"Include stopped containers"} }}}},
+		{"type":"function","function":{"name":"docker_images","description":"List local Docker images with tag, ID, age, and size.","parameters":{"type":"object","properties":{}}}},
+		{"type":"function","function":{"name":"docker_inspect","description":"Show a container's or image's configuration: command, environment, mounts, networks, health, and state.","parameters":{"type":"object","properties":{
+			"name":{"type":"string","description":"Container or image name or ID"} },"required":["name"]}}},
+		{"type":"function","function":{"name":"docker_logs","description":"Read the last lines of a container's logs.","parameters":{"type":"object","properties":{
+			"container":{"type":"string","description":"Container name or ID"},
+			"tail":{"type":"integer","default":200,"description":"How many lines from the end, at most 1000"},
+			"since":{"type":"string","description":"Optional start, as a duration like 10m or a timestamp"} },"required":["container"]}}}
+		]`
+
+const dockerWriteToolDef = `[
+		{"type":"function","function":{"name":"docker_restart","description":"Restart a container. The user approves it first.","parameters":{"type":"object","properties":{
+			"container":{"type":"string","description":"Container name or ID"} },"required":["container"]}}}
+		]`
+
+// maxDockerOutput bounds one result; inspect output and logs both run long.
+const (
+	maxDockerOutput = 32 << 10
+	maxDockerTail   = 1000
+)
+
+// dockerName accepts container and image names, IDs, and durations, and never a value docker would read as a flag.
+var dockerName = regexp.MustCompile(`^[\w][\w.:/@+-]*$`)
+
+// dockerReady reports whether the docker CLI is installed and its daemon answers.
+var dockerReady = sync.OnceValue(func() bool {
+	return exec.Command("docker", "version", "--format", "{{.Server.Version}}").Run() == nil
+})
+
+var dockerTools = registerToolset(&toolset{
+	def:      dockerToolDef,
+	enabled:  dockerReady,
+	readOnly: true,
+	run: map[string]func(context.Context, string) (string, error){
+		"docker_ps": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				All bool `json:"all"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			argv := []string{"ps", "--format", "table {{.Names}}\t{{.Image}}\t{{.Status}}\t{{.Ports}}"}
+			if params.All {
+				argv = append(argv, "--all")
+			}
+			return docker(ctx, false, argv...)
+		},
+		"docker_images": func(ctx context.Context, args string) (string, error) {
+			return docker(ctx, false, "images", "--format", "table {{.Repository}}:{{.Tag}}\t{{.ID}}\t{{.CreatedSince}}\t{{.Size}}")
+		},
+		"docker_inspect": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Name string `json:"name"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			if err := checkDockerNames(params.Name); err != nil {
+				return "", err
+			}
+			return docker(ctx, false, "inspect", params.Name)
+		},
+		"docker_logs": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Container string `json:"container"`
+				Tail      int    `json:"tail"`
+				Since     string `json:"since"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			if err := checkDockerNames(params.Container, params.Since); err != nil {
+				return "", err
+			}
+			argv := []string{"logs", "--timestamps", fmt.Sprintf("--tail=%d", min(max(params.Tail, 1), maxDockerTail))}
+			if params.Since != "" {
+				argv = append(argv, "--since", params.Since)
+			}
+			return docker(ctx, true, append(argv, params.Container)...)
+		},
+	},
+})
+
+var dockerWriteTools = registerToolset(&toolset{
+	def:     dockerWriteToolDef,
+	enabled: func() bool { return dockerReady() && *allowWrites },
+	writes:  true,
+	run: map[string]func(context.Context, string) (string, error){
+		"docker_restart": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Container string `json:"container"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			if err := checkDockerNames(params.Container); err != nil {
+				return "", err
+			}
+			question := fmt.Sprintf("Restart container %s?", params.Container)
+			event(slog.LevelInfo, fmt.Sprintf("\n\033[90m🐳 %s\033[0m\n", question), "proposed docker restart", "container", params.Container)
+			switch answer := approve(ctx, question, ""); strings.ToLower(answer) {
+			case "y", "yes":
+			case "", "n", "no":
+				return fmt.Sprintf("The user rejected restarting %s; it is still running as before.", params.Container), nil
+			default:
+				return fmt.Sprintf("The user rejected restarting %s and said: %s", params.Container, answer), nil
+			}
+			if _, err := docker(ctx, false, "restart", params.Container); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Restarted %s", params.Container), nil
+		},
+	},
+})
+
+func checkDockerNames(values ...string) error {
+	for _, v := range values {
+		if v != "" && !dockerName.MatchString(v) {
+			return fmt.Errorf("Permanent Error: %q is not a valid Docker name or ID", v)
+		}
+	}
+	return nil
+}
+
+// docker runs a docker command. A long result keeps its start, or its end for logs, where the newest lines are.
+// Containers write their logs to both streams, so logs read stderr as part of the result.
+func docker(ctx context.Context, keepEnd bool, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	var out, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &stderr
+	if keepEnd {
+		cmd.Stderr = &out
+	}
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(out.String())
+		}
+		return "", fmt.Errorf("docker %s failed: %s", args[0], clip(msg))
+	}
+	return fmt.Sprintf("docker %s results\n%s", args[0], clipOutput(out.String(), maxDockerOutput, keepEnd)), nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// --dry-run previews what a mission would do without letting it do anything: the write tools are offered, as
+// with --allow-writes, but each proposed change is only shown as its diff, and every other tool that changes or
+// runs something is only shown as its call. The model is told the call was not executed, so it can carry on,
+// and the session ends with the list of everything that was skipped.
+var dryRun = flag.Bool("dry-run", false, "Offer every tool but run none that changes or executes anything; show the intended calls and diffs instead")
+
+const dryRunResult = "Not executed (dry-run): this session only previews changes, so nothing was written or run. Continue as if it had succeeded, and describe what you would do next."
+
+var skippedCalls struct {
+	sync.Mutex
+	calls []string
+}
+
+// skipCall records a call that the dry run did not execute and returns the result the model sees.
+func skipCall(call string) string {
+	skippedCalls.Lock()
+	skippedCalls.calls = append(skippedCalls.calls, clip(call))
+	skippedCalls.Unlock()
+	return dryRunResult
+}
+
+// describeCall writes a tool call as one line, its name and then each argument.
+func describeCall(name, args string) string {
+	var parts []string
+	for _, arg := range toolArgs(args) {
+		parts = append(parts, arg[0]+": "+arg[1])
+	}
+	return strings.TrimSpace(name + " " + strings.Join(parts, ", "))
+}
+
+// dryRunTable lists the skipped calls in order, for the report at the end of the session.
+func dryRunTable() string {
+	skippedCalls.Lock()
+	defer skippedCalls.Unlock()
+	if len(skippedCalls.calls) == 0 {
+		return "(no calls would have changed anything)"
+	}
+	var b strings.Builder
+	for i, call := range skippedCalls.calls {
+		fmt.Fprintf(&b, "%3d. %s\n", i+1, call)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
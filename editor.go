@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Long missions are easier to write in a real editor than on one prompt line. /edit and --editor open $VISUAL
+// or $EDITOR on a Markdown buffer; whatever is saved becomes the mission, and an empty buffer cancels.
+var useEditor = flag.Bool("editor", false, "Compose the first mission in $EDITOR instead of at the prompt")
+
+const editorTemplate = "<!-- Write the mission below and save. Comments like this one are ignored; an empty file cancels. -->\n\n"
+
+var htmlComment = regexp.MustCompile(`(?s)<!--.*?-->`)
+
+// editMission opens the editor prefilled with initial and returns the saved mission, "" if the user cancelled.
+func editMission(initial string) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = map[bool]string{true: "notepad", false: "vi"}[runtime.GOOS == "windows"]
+	}
+
+	file, err := os.CreateTemp("", "tinyagent-mission-*.md")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(editorTemplate + initial)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// $EDITOR may carry arguments, as in "code --wait".
+	argv := append(strings.Fields(editor), file.Name())
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	termMu.Lock()
+	paused = true
+	termMu.Unlock()
+	err = cmd.Run()
+	termMu.Lock()
+	paused, lineStart = false, true
+	termMu.Unlock()
+	if err != nil {
+		return "", fmt.Errorf("editor %s failed: %v", argv[0], err)
+	}
+
+	raw, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(htmlComment.ReplaceAllString(string(raw), "")), nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+// --email-to mails each scheduled run's answer, with the whole session attached as the Markdown report, so a
+// weekly mission lands in the inbox. SMTP_USERNAME and SMTP_PASSWORD authenticate when the server needs it;
+// net/smtp upgrades to TLS whenever the server offers it and won't send a password without it.
+var (
+	emailTo    = flag.String("email-to", "", "With --every, mail each run's report to these comma-separated addresses")
+	emailFrom  = flag.String("email-from", "", "Sender address for --email-to (default: SMTP_USERNAME)")
+	smtpServer = flag.String("smtp-server", "localhost:25", "SMTP server as host:port for --email-to")
+)
+
+// emailReport mails the outcome of a scheduled run; failing to send is reported but never stops the schedule.
+func emailReport(mission, answer string, failure error, messages []ChatMessage) {
+	if *emailTo == "" {
+		return
+	}
+	title, _, _ := strings.Cut(mission, "\n")
+	subject, body := "tinyagent: "+clip(title), answer
+	if failure != nil {
+		subject, body = "tinyagent failed: "+clip(title), fmt.Sprintf("The scheduled mission failed: %v", failure)
+	}
+	total := sessionTotal()
+	body = fmt.Sprintf("> %s\n\n%s\n\n-- \ntinyagent · %s · %s · %.2fc this session\n", quoteLines(mission), body, time.Now().Format("2006-01-02 15:04"), *model, total.Cost*100)
+	report := "tinyagent-" + time.Now().Format("2006-01-02") + ".md"
+	if err := sendEmail(strings.Split(*emailTo, ","), subject, body, report, renderReport(messages)); err != nil {
+		event(slog.LevelWarn, fmt.Sprintf("\033[33mCould not email the report: %v\033[0m\n", err), "email failed", "to", *emailTo, "err", err)
+		return
+	}
+	event(slog.LevelInfo, fmt.Sprintf("\033[90mReport emailed to %s\033[0m\n", *emailTo), "report emailed", "to", *emailTo)
+}
+
+// sendEmail sends body as text with one Markdown attachment.
+func sendEmail(to []string, subject, body, attachmentName, attachment string) error {
+	for i := range to {
+		to[i] = strings.TrimSpace(to[i])
+	}
+	user, password := os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD")
+	from := *emailFrom
+	if from == "" {
+		from = user
+	}
+	if from == "" {
+		return fmt.Errorf("no sender, pass --email-from or set SMTP_USERNAME")
+	}
+
+	var msg bytes.Buffer
+	parts := multipart.NewWriter(&msg)
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%s\r\n\r\n",
+		from, strings.Join(to, ", "), mime.QEncoding.Encode("utf-8", subject), time.Now().Format(time.RFC1123Z), parts.Boundary())
+
+	text, _ := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}, "Content-Transfer-Encoding": {"quoted-printable"}})
+	qp := quotedprintable.NewWriter(text)
+	qp.Write([]byte(body))
+	qp.Close()
+	file, _ := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/markdown; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", attachmentName)},
+	})
+	encoded := base64.StdEncoding.EncodeToString([]byte(attachment))
+	for len(encoded) > 76 {
+		fmt.Fprintf(file, "%s\r\n", encoded[:76])
+		encoded = encoded[76:]
+	}
+	fmt.Fprintf(file, "%s\r\n", encoded)
+	parts.Close()
+
+	var auth smtp.Auth
+	if user != "" {
+		host, _, _ := net.SplitHostPort(*smtpServer)
+		auth = smtp.PlainAuth("", user, password, host)
+	}
+	return smtp.SendMail(*smtpServer, auth, from, to, msg.Bytes())
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// `tinyagent eval --suite tasks.yaml` measures the agent instead of guessing at it: it runs every task's mission
+// with each model in the suite, scores the answer with assertions, a judge model, or both, and reports what
+// passed and what it cost, so a model or prompt change can be compared on the same work. A suite looks like:
+//
+//	models: [qwen/qwen3-4b, {model: gpt-4.1-mini, url: https://api.openai.com/v1/chat/completions}]
+//	judge: gpt-4.1-mini
+//	threshold: 0.8
+//	tasks:
+//	  - name: finds-server
+//	    repo: https://github.com/example/app
+//	    ref: v1.2.0
+//	    mission: Where does the HTTP server start listening?
+//	    expect: {contains: [server.go], matches: ["(?i)listenandserve"], command: go vet ./...}
+//	    judge: Names server.go and the ListenAndServe call
+//
+// A repo is a local directory, relative to the suite, or anything git can clone; a clone, or a ref of a local
+// one, is checked out in a temporary directory. Changes are approved there when --allow-writes is on and
+// refused in a local directory, since nobody is watching. Models default to --model and the judge to the same.
+var (
+	evalSuite  = flag.String("suite", "", "With the eval subcommand, the YAML or JSON suite of tasks to run")
+	evalReport = flag.String("eval-report", "", "With the eval subcommand, also write every result with its answer to this JSON file")
+)
+
+const judgePrompt = `You grade the answer of a coding agent against the criteria given. Judge only whether the answer meets the criteria, not its style. Reply with PASS or FAIL on the first line, then one sentence saying why.`
+
+type evalModel struct {
+	Model string `json:"model"`
+	URL   string `json:"url"`
+}
+
+// UnmarshalJSON accepts a model's bare name as well as {model, url}.
+func (m *evalModel) UnmarshalJSON(raw []byte) error {
+	if json.Unmarshal(raw, &m.Model) == nil {
+		return nil
+	}
+	type plain evalModel
+	return json.Unmarshal(raw, (*plain)(m))
+}
+
+type evalTask struct {
+	Name    string `json:"name"`
+	Repo    string `json:"repo"`
+	Ref     string `json:"ref"`
+	Mission string `json:"mission"`
+	Timeout string `json:"timeout"`
+	Judge   string `json:"judge"`
+	Expect  struct {
+		Contains    []string `json:"contains"`
+		NotContains []string `json:"not_contains"`
+		Matches     []string `json:"matches"`
+		Command     string   `json:"command"`
+	} `json:"expect"`
+}
+
+type evalResult struct {
+	Task     string  `json:"task"`
+	Model    string  `json:"model"`
+	Passed   bool    `json:"passed"`
+	Reason   string  `json:"reason,omitempty"`
+	Seconds  float64 `json:"seconds"`
+	Cost     float64 `json:"cost"`
+	Requests int     `json:"requests"`
+	Answer   string  `json:"answer"`
+}
+
+type suite struct {
+	Models    []evalModel `json:"models"`
+	Judge     evalModel   `json:"judge"`
+	Threshold float64     `json:"threshold"`
+	Tasks     []evalTask  `json:"tasks"`
+}
+
+// loadSuite reads a suite and checks every task can be run and scored before any of them starts.
+func loadSuite(path string) (*suite, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := parseYAML(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	// The YAML comes back as plain maps and lists, which JSON turns into the typed suite.
+	encoded, _ := json.Marshal(parsed)
+	var s suite
+	if err := json.Unmarshal(encoded, &s); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(s.Tasks) == 0 {
+		return nil, fmt.Errorf("%s: no tasks", path)
+	}
+	if len(s.Models) == 0 {
+		s.Models = []evalModel{{Model: *model}}
+	}
+	if s.Judge.Model == "" {
+		s.Judge.Model = *model
+	}
+	for i := range s.Tasks {
+		t := &s.Tasks[i]
+		if t.Name == "" {
+			t.Name = fmt.Sprintf("task-%d", i+1)
+		}
+		if strings.TrimSpace(t.Mission) == "" {
+			return nil, fmt.Errorf("%s: task %s has no mission", path, t.Name)
+		}
+		e := t.Expect
+		if t.Judge == "" && len(e.Contains)+len(e.NotContains)+len(e.Matches) == 0 && e.Command == "" {
+			return nil, fmt.Errorf("%s: task %s has nothing to score it, add expect or judge", path, t.Name)
+		}
+		for _, pattern := range e.Matches {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("%s: task %s: %v", path, t.Name, err)
+			}
+		}
+		if t.Timeout != "" {
+			if _, err := time.ParseDuration(t.Timeout); err != nil {
+				return nil, fmt.Errorf("%s: task %s: timeout %q is not a duration", path, t.Name, t.Timeout)
+			}
+		}
+		// A local repo is relative to the suite, so the suite runs the same from any directory.
+		if t.Repo == "" {
+			t.Repo = "."
+		}
+		if !isGitURL(t.Repo) {
+			if !filepath.IsAbs(t.Repo) {
+				t.Repo = filepath.Join(filepath.Dir(path), t.Repo)
+			}
+			if t.Repo, err = filepath.Abs(t.Repo); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &s, nil
+}
+
+func isGitURL(repo string) bool {
+	return strings.Contains(repo, "://") || strings.HasPrefix(repo, "git@")
+}
+
+// runEval implements the eval subcommand and returns the process exit code: 1 when a model's pass rate is
+// below the suite's threshold, 2 when the suite can't run at all.
+func runEval(args []string) int {
+	flag.CommandLine.Parse(args)
+	if err := setupUI(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *evalSuite == "" {
+		fmt.Fprintln(os.Stderr, "usage: tinyagent eval --suite tasks.yaml")
+		return 2
+	}
+	s, err := loadSuite(*evalSuite)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tinyagent eval: %v\n", err)
+		return 2
+	}
+	home, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tinyagent eval: %v\n", err)
+		return 2
+	}
+	defaultModel, defaultURL := *model, *apiURL
+
+	var results []evalResult
+	for _, task := range s.Tasks {
+		dir, scratch, err := checkoutTask(task)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "tinyagent eval: %s: %v\n", task.Name, err)
+			return 2
+		}
+		for _, m := range s.Models {
+			*model, *apiURL = m.Model, defaultURL
+			if m.URL != "" {
+				*apiURL = m.URL
+			}
+			// Models that change files each start from the commit, not from the last model's edits.
+			if scratch {
+				exec.Command("git", "-C", dir, "checkout", "--quiet", "--", ".").Run()
+				exec.Command("git", "-C", dir, "clean", "--quiet", "-fd").Run()
+			}
+			r := runEvalTask(task, m.Model, dir, scratch)
+			if r.Passed && task.Judge != "" {
+				*model, *apiURL = s.Judge.Model, defaultURL
+				if s.Judge.URL != "" {
+					*apiURL = s.Judge.URL
+				}
+				r.Passed, r.Reason = judgeAnswer(task, r.Answer)
+			}
+			verdict := "\033[32mPASS"
+			if !r.Passed {
+				verdict = "\033[31mFAIL"
+			}
+			event(slog.LevelInfo, fmt.Sprintf("\033[90m=== %s %s\033[90m with \033[35m%s\033[90m in %.0fs for %.2fc %s\033[0m\n", task.Name, verdict, r.Model, r.Seconds, r.Cost*100, r.Reason),
+				"eval result", "task", task.Name, "model", r.Model, "passed", r.Passed, "reason", r.Reason, "seconds", r.Seconds, "cost_cents", r.Cost*100)
+			results = append(results, r)
+		}
+		os.Chdir(home)
+		if scratch {
+			os.RemoveAll(dir)
+		}
+	}
+	*model, *apiURL = defaultModel, defaultURL
+
+	fmt.Print(renderEval(s, results))
+	if *evalReport != "" {
+		raw, _ := json.MarshalIndent(results, "", "  ")
+		if err := os.WriteFile(*evalReport, append(raw, '\n'), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "tinyagent eval: %v\n", err)
+			return 2
+		}
+	}
+	for _, m := range s.Models {
+		if passRate(results, m.Model) < s.Threshold {
+			return 1
+		}
+	}
+	return 0
+}
+
+// checkoutTask returns the directory a task runs in, and whether it is a scratch checkout that eval made and
+// removes afterwards.
+func checkoutTask(task evalTask) (string, bool, error) {
+	if !isGitURL(task.Repo) && task.Ref == "" {
+		info, err := os.Stat(task.Repo)
+		if err != nil {
+			return "", false, err
+		}
+		if !info.IsDir() {
+			return "", false, fmt.Errorf("%s is not a directory", task.Repo)
+		}
+		return task.Repo, false, nil
+	}
+	dir, err := os.MkdirTemp("", "tinyagent-eval-")
+	if err != nil {
+		return "", false, err
+	}
+	event(slog.LevelInfo, fmt.Sprintf("\033[90mCloning %s %s\033[0m\n", task.Repo, task.Ref), "cloning", "repo", task.Repo, "ref", task.Ref)
+	steps := [][]string{{"clone", "--quiet", "--filter=blob:none", task.Repo, dir}}
+	if task.Ref != "" {
+		steps = append(steps, []string{"-C", dir, "checkout", "--quiet", task.Ref})
+	}
+	for _, step := range steps {
+		if out, err := exec.Command("git", step...).CombinedOutput(); err != nil {
+			os.RemoveAll(dir)
+			return "", false, fmt.Errorf("git %s failed: %s", step[0], clip(strings.TrimSpace(string(out))))
+		}
+	}
+	return dir, true, nil
+}
+
+// runEvalTask runs one task's mission in dir with the current model in a conversation of its own, and checks
+// the assertions; the judge, if any, comes after.
+func runEvalTask(task evalTask, name, dir string, scratch bool) evalResult {
+	r := evalResult{Task: task.Name, Model: name}
+	if err := os.Chdir(dir); err != nil {
+		r.Reason = err.Error()
+		return r
+	}
+	timeout := 10 * time.Minute
+	if task.Timeout != "" {
+		timeout, _ = time.ParseDuration(task.Timeout)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	ctx = withApprover(ctx, func(question, diff string) string {
+		if scratch {
+			return "y"
+		}
+		return "No changes are allowed during an evaluation in the user's working copy; answer without them."
+	})
+
+	before, start := sessionTotal(), time.Now()
+	var messages []ChatMessage
+	answer, err := runBotMission(ctx, activeTools(), &messages, task.Mission, nil)
+	after := sessionTotal()
+	r.Seconds = time.Since(start).Seconds()
+	r.Cost, r.Requests = after.Cost-before.Cost, after.Requests-before.Requests
+	r.Answer = answer
+	if err != nil {
+		r.Reason = fmt.Sprintf("mission failed: %v", err)
+		return r
+	}
+	r.Passed, r.Reason = checkAnswer(task, answer)
+	return r
+}
+
+// checkAnswer applies a task's assertions to the answer. Text is compared case-insensitively, and the command,
+// split at spaces rather than run by a shell, runs in the task's directory and passes when it exits 0.
+func checkAnswer(task evalTask, answer string) (bool, string) {
+	lower := strings.ToLower(answer)
+	for _, want := range task.Expect.Contains {
+		if !strings.Contains(lower, strings.ToLower(want)) {
+			return false, fmt.Sprintf("answer does not mention %q", want)
+		}
+	}
+	for _, unwanted := range task.Expect.NotContains {
+		if strings.Contains(lower, strings.ToLower(unwanted)) {
+			return false, fmt.Sprintf("answer mentions %q", unwanted)
+		}
+	}
+	for _, pattern := range task.Expect.Matches {
+		if !regexp.MustCompile(pattern).MatchString(answer) {
+			return false, fmt.Sprintf("answer does not match %s", pattern)
+		}
+	}
+	if task.Expect.Command != "" {
+		argv := strings.Fields(task.Expect.Command)
+		if out, err := exec.Command(argv[0], argv[1:]...).CombinedOutput(); err != nil {
+			return false, fmt.Sprintf("%s failed: %s", task.Expect.Command, clip(strings.TrimSpace(string(out))))
+		}
+	}
+	return true, ""
+}
+
+// judgeAnswer asks the judge model, already selected, whether the answer meets the task's criteria.
+func judgeAnswer(task evalTask, answer string) (bool, string) {
+	msg, _, err := sendChatRequest(withPurpose(context.Background(), "judge"), *model, []ChatMessage{
+		{Role: "system", Content: judgePrompt},
+		{Role: "user", Content: fmt.Sprintf("Task given to the agent:\n%s\n\nCriteria:\n%s\n\nThe agent's answer:\n%s", task.Mission, task.Judge, answer)},
+	}, nil)
+	if err != nil {
+		return false, fmt.Sprintf("judge failed: %v", err)
+	}
+	verdict, why, _ := strings.Cut(strings.TrimSpace(msg.Content), "\n")
+	why = clip(strings.TrimSpace(why))
+	if strings.HasPrefix(strings.ToUpper(strings.Trim(verdict, "*# ")), "PASS") {
+		return true, why
+	}
+	if why == "" {
+		why = clip(verdict)
+	}
+	return false, "judge: " + why
+}
+
+func passRate(results []evalResult, name string) float64 {
+	passed, total := 0, 0
+	for _, r := range results {
+		if r.Model == name {
+			total++
+			if r.Passed {
+				passed++
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(passed) / float64(total)
+}
+
+// renderEval prints each result and then one line per model, in the suite's order, to compare them at a glance.
+func renderEval(s *suite, results []evalResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-28s %-30s %-6s %8s %9s  %s\n", "TASK", "MODEL", "RESULT", "TIME", "COST", "REASON")
+	for _, r := range results {
+		verdict := "pass"
+		if !r.Passed {
+			verdict = "FAIL"
+		}
+		fmt.Fprintf(&b, "%-28s %-30s %-6s %7.0fs %8.2fc  %s\n", r.Task, r.Model, verdict, r.Seconds, r.Cost*100, r.Reason)
+	}
+	b.WriteString("\n")
+	for _, m := range s.Models {
+		passed, total, seconds, cost, requests := 0, 0, 0.0, 0.0, 0
+		for _, r := range results {
+			if r.Model != m.Model {
+				continue
+			}
+			total++
+			if r.Passed {
+				passed++
+			}
+			seconds, cost, requests = seconds+r.Seconds, cost+r.Cost, requests+r.Requests
+		}
+		fmt.Fprintf(&b, "%-30s %d/%d passed (%.0f%%), %.2fc over %d requests, %.0fs\n", m.Model, passed, total, passRate(results, m.Model)*100, cost*100, requests, seconds)
+	}
+	return b.String()
+}
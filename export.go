@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// --export-markdown writes the session as a document to attach to a ticket: each mission, every tool call with
+// its result folded away, and the conclusion. The model's reasoning is included when --show-thoughts is on.
+var exportMarkdown = flag.String("export-markdown", "", "When the session ends, write it to this file as a Markdown report")
+
+var backtickRun = regexp.MustCompile("`{3,}")
+
+// writeReport renders messages as Markdown and writes them to path.
+func writeReport(messages []ChatMessage, path string) error {
+	return os.WriteFile(path, []byte(renderReport(messages)), 0o644)
+}
+
+// renderReport renders messages as a Markdown report.
+func renderReport(messages []ChatMessage) string {
+	var b strings.Builder
+	total := sessionTotal()
+	fmt.Fprintf(&b, "# tinyagent report\n\n_%s · %s · %d requests · %.2fc_\n", *model, sessionStart.Format("2006-01-02 15:04"), total.Requests, total.Cost*100)
+
+	prefix, _, _ := strings.Cut(userPromptFormat, "%s")
+	results := map[string]string{}
+	for _, m := range messages {
+		if m.Role == "tool" {
+			results[m.ToolCallID] = m.Content
+		}
+	}
+	missions := 0
+	for _, m := range messages {
+		switch {
+		case m.Role == "user" && strings.HasPrefix(m.Content, prefix):
+			missions++
+			mission := strings.TrimPrefix(m.Content, prefix)
+			title, _, _ := strings.Cut(mission, "\n")
+			fmt.Fprintf(&b, "\n## %d. %s\n\n> %s\n", missions, clip(title), quoteLines(mission))
+		case m.Role == "user":
+			// Nudges and steering are part of how the mission went, so they stay in, set apart from the work.
+			fmt.Fprintf(&b, "\n> **Note to the agent:** %s\n", quoteLines(m.Content))
+		case m.Role == "assistant":
+			if *showThoughts && m.Thoughts != "" {
+				fmt.Fprintf(&b, "\n<details><summary>Reasoning</summary>\n\n%s\n\n</details>\n", m.Thoughts)
+			}
+			for _, tc := range m.ToolCalls {
+				var args []string
+				for _, arg := range toolArgs(tc.Function.Arguments) {
+					args = append(args, fmt.Sprintf("%s: `%s`", arg[0], strings.ReplaceAll(arg[1], "`", "'")))
+				}
+				res := results[tc.ID]
+				fence := "```"
+				for _, run := range backtickRun.FindAllString(res, -1) {
+					if len(run) >= len(fence) {
+						fence = run + "`"
+					}
+				}
+				fmt.Fprintf(&b, "\n- **%s** %s\n\n  <details><summary>Result, %d lines</summary>\n\n%s\n%s\n%s\n\n  </details>\n",
+					tc.Function.Name, strings.Join(args, ", "), strings.Count(strings.TrimRight(res, "\n"), "\n")+1, fence, strings.TrimRight(res, "\n"), fence)
+			}
+			if m.Content != "" && len(m.ToolCalls) == 0 {
+				fmt.Fprintf(&b, "\n### Conclusion\n\n%s\n", strings.TrimSpace(m.Content))
+			}
+		}
+	}
+	return b.String()
+}
+
+// exportReport writes the --export-markdown report, if one was asked for, as the session ends.
+func exportReport(messages []ChatMessage) {
+	if *exportMarkdown == "" || len(messages) < 2 {
+		return
+	}
+	if err := writeReport(messages, *exportMarkdown); err != nil {
+		event(slog.LevelWarn, fmt.Sprintf("\033[33mCould not export the session to %s: %v\033[0m\n", *exportMarkdown, err), "export failed", "path", *exportMarkdown, "err", err)
+		return
+	}
+	event(slog.LevelInfo, fmt.Sprintf("\033[90mSession exported to %s\033[0m\n", *exportMarkdown), "session exported", "path", *exportMarkdown)
+}
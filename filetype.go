@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// File kinds reported to the model. Only the text kinds can be studied.
+const (
+	kindText    = "text"
+	kindUTF16   = "utf-16 text"
+	kindImage   = "image"
+	kindPDF     = "pdf"
+	kindArchive = "archive"
+	kindMedia   = "audio/video"
+	kindBinary  = "binary"
+)
+
+// extKinds classifies well-known binary extensions without opening the file, which keeps listings of asset-heavy
+// directories cheap. Text files are always sniffed, since a .txt or .cs can still turn out to be UTF-16.
+var extKinds = map[string]string{
+	".png": kindImage, ".jpg": kindImage, ".jpeg": kindImage, ".gif": kindImage, ".webp": kindImage, ".bmp": kindImage,
+	".ico": kindImage, ".tiff": kindImage, ".psd": kindImage, ".heic": kindImage,
+	".pdf": kindPDF,
+	".zip": kindArchive, ".gz": kindArchive, ".tgz": kindArchive, ".tar": kindArchive, ".bz2": kindArchive,
+	".xz": kindArchive, ".7z": kindArchive, ".rar": kindArchive, ".jar": kindArchive, ".zst": kindArchive,
+	".mp3": kindMedia, ".mp4": kindMedia, ".wav": kindMedia, ".mov": kindMedia, ".avi": kindMedia, ".mkv": kindMedia,
+	".flac": kindMedia, ".ogg": kindMedia, ".webm": kindMedia,
+	".exe": kindBinary, ".dll": kindBinary, ".so": kindBinary, ".dylib": kindBinary, ".o": kindBinary, ".a": kindBinary,
+	".class": kindBinary, ".wasm": kindBinary, ".pyc": kindBinary, ".sqlite": kindBinary, ".db": kindBinary,
+	".woff": kindBinary, ".woff2": kindBinary, ".ttf": kindBinary, ".otf": kindBinary,
+}
+
+type kindEntry struct {
+	kind    string
+	size    int64
+	modTime time.Time
+}
+
+var (
+	kindMu    sync.Mutex
+	kindCache = map[string]kindEntry{} // path -> kind, valid while size and mtime match
+)
+
+// fileType classifies a file by extension and magic number, falling back to UTF-8 validity for everything else.
+// Results are cached per path until the file changes, since the same files are listed and studied repeatedly.
+func fileType(path string) string {
+	info, err := workspace.Stat(path)
+	if err != nil {
+		return fmt.Sprintf("Error opening file: %v", err)
+	}
+	kindMu.Lock()
+	cached, ok := kindCache[path]
+	kindMu.Unlock()
+	if ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.kind
+	}
+
+	kind, ok := extKinds[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		if kind, err = sniffKind(path); err != nil {
+			return err.Error()
+		}
+	}
+	kindMu.Lock()
+	kindCache[path] = kindEntry{kind, info.Size(), info.ModTime()}
+	kindMu.Unlock()
+	return kind
+}
+
+// sniffKind reads the first 512 bytes and lets http.DetectContentType recognize magic numbers and BOMs.
+func sniffKind(path string) (string, error) {
+	file, err := workspace.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("Error opening file: %v", err)
+	}
+	defer file.Close()
+
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", fmt.Errorf("Error reading file header: %v", err)
+	}
+	header = header[:n]
+
+	mime := http.DetectContentType(header)
+	switch {
+	case strings.Contains(mime, "utf-16") || looksUTF16(header):
+		return kindUTF16, nil
+	case strings.HasPrefix(mime, "image/"):
+		return kindImage, nil
+	case mime == "application/pdf":
+		return kindPDF, nil
+	case mime == "application/zip" || mime == "application/x-gzip" || mime == "application/x-rar-compressed":
+		return kindArchive, nil
+	case strings.HasPrefix(mime, "audio/") || strings.HasPrefix(mime, "video/") || mime == "application/ogg":
+		return kindMedia, nil
+	}
+
+	if bytes.IndexByte(header, 0) != -1 {
+		return kindBinary, nil
+	}
+	// The header may end partway through a multi-byte rune, so up to three trailing bytes are forgiven.
+	for cut := 0; cut <= 3 && cut <= len(header); cut++ {
+		if utf8.Valid(header[:len(header)-cut]) {
+			return kindText, nil
+		}
+	}
+	return kindBinary, nil
+}
+
+// looksUTF16 spots BOM-less UTF-16, where ASCII text shows up as every other byte being zero.
+func looksUTF16(header []byte) bool {
+	if len(header) < 16 {
+		return false
+	}
+	even, odd := 0, 0
+	for i, b := range header {
+		if b == 0 {
+			if i%2 == 0 {
+				even++
+			} else {
+				odd++
+			}
+		}
+	}
+	half := len(header) / 2
+	return (odd > half*3/4 && even == 0) || (even > half*3/4 && odd == 0)
+}
+
+// openText returns a reader of the file as UTF-8, transcoding UTF-16 files so they can be paged like any other.
+func openText(file io.ReaderAt, size int64, kind string) (io.Reader, error) {
+	section := io.NewSectionReader(file, 0, size)
+	if kind != kindUTF16 {
+		return section, nil
+	}
+	raw, err := io.ReadAll(section)
+	if err != nil {
+		return nil, err
+	}
+
+	bigEndian := len(raw) >= 2 && raw[0] == 0xFE && raw[1] == 0xFF
+	if !bigEndian && (len(raw) < 2 || raw[0] != 0xFF || raw[1] != 0xFE) {
+		bigEndian = len(raw) >= 2 && raw[0] == 0 && raw[1] != 0 // BOM-less: the high byte comes first
+	}
+	units := make([]uint16, 0, len(raw)/2)
+	for i := 0; i+1 < len(raw); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		} else {
+			units = append(units, uint16(raw[i+1])<<8|uint16(raw[i]))
+		}
+	}
+	if len(units) > 0 && units[0] == 0xFEFF {
+		units = units[1:]
+	}
+	return strings.NewReader(string(utf16.Decode(units))), nil
+}
+
+// toolPath renders a path for the model with forward slashes on every platform, so results read the same on
+// Windows and a path copied from one result works unchanged in the next call.
+func toolPath(path string) string {
+	return filepath.ToSlash(path)
+}
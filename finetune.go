@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// --export-finetune turns saved transcripts into training data, so a big model's good runs can teach a small
+// local one. Each mission that ended in an answer becomes one example in OpenAI's chat fine-tuning format,
+// {"messages": [...], "tools": [...]}, holding the conversation up to its answer: the tool calls and results,
+// with the earlier missions of the session as context whose assistant turns have weight 0, so only this
+// mission's are learned. Missions that failed or were interrupted are left out, as are examples already written
+// from another copy of the same session.
+var exportFinetune = flag.String("export-finetune", "", "Convert saved transcripts, the files given or all of .tinyagent/sessions, to fine-tuning JSONL in this file, then exit")
+
+type finetuneMessage struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content,omitempty"`
+	ToolCalls  []ToolCall      `json:"tool_calls,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+	Weight     *int            `json:"weight,omitempty"`
+}
+
+type finetuneExample struct {
+	Messages []finetuneMessage `json:"messages"`
+	Tools    []json.RawMessage `json:"tools,omitempty"`
+}
+
+// runExportFinetune writes the examples in the transcripts named by files, or in every saved transcript, and
+// returns the process exit code.
+func runExportFinetune(files []string) int {
+	if len(files) == 0 {
+		files, _ = filepath.Glob(filepath.Join(sessionsDir, "*.json"))
+		sort.Strings(files)
+		if len(files) == 0 {
+			fmt.Fprintf(os.Stderr, "tinyagent: no transcripts in %s; save one with /save, or name the files\n", sessionsDir)
+			return 1
+		}
+	}
+	out, err := os.Create(*exportFinetune)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tinyagent: %v\n", err)
+		return 1
+	}
+	defer out.Close()
+	w := bufio.NewWriter(out)
+
+	defs := allToolDefs()
+	written, skipped, seen := 0, 0, map[string]bool{}
+	for _, file := range files {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "tinyagent: skipping %v\n", err)
+			continue
+		}
+		var t struct {
+			Messages []finetuneMessage `json:"messages"`
+		}
+		if err := json.Unmarshal(raw, &t); err != nil {
+			fmt.Fprintf(os.Stderr, "tinyagent: skipping %s, not a transcript: %v\n", file, err)
+			continue
+		}
+		examples, failed := finetuneExamples(t.Messages, defs)
+		skipped += failed
+		for _, example := range examples {
+			line, _ := json.Marshal(example)
+			if seen[string(line)] {
+				continue
+			}
+			seen[string(line)] = true
+			w.Write(append(line, '\n'))
+			written++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "tinyagent: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d examples from %d transcripts to %s", written, len(files), *exportFinetune)
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, ", leaving out %d missions without an answer", skipped)
+	}
+	fmt.Fprintln(os.Stderr)
+	if written == 0 {
+		return 1
+	}
+	return 0
+}
+
+// finetuneExamples cuts a conversation into one example per answered mission, and counts the missions that
+// never got an answer.
+func finetuneExamples(messages []finetuneMessage, defs map[string]json.RawMessage) (examples []finetuneExample, failed int) {
+	prefix, _, _ := strings.Cut(userPromptFormat, "%s")
+	var starts []int
+	for i, m := range messages {
+		var content string
+		if m.Role == "user" && json.Unmarshal(m.Content, &content) == nil && strings.HasPrefix(content, prefix) {
+			starts = append(starts, i)
+		}
+	}
+	zero := 0
+	for k, start := range starts {
+		end := len(messages)
+		if k+1 < len(starts) {
+			end = starts[k+1]
+		}
+		answer := -1
+		for i := start + 1; i < end; i++ {
+			if m := messages[i]; m.Role == "assistant" && len(m.ToolCalls) == 0 && len(m.Content) > 0 && string(m.Content) != `""` {
+				answer = i
+			}
+		}
+		if answer < 0 {
+			failed++
+			continue
+		}
+		example := finetuneExample{Messages: make([]finetuneMessage, answer+1)}
+		used := map[string]bool{}
+		for i, m := range messages[:answer+1] {
+			if m.Role == "assistant" && i < start {
+				m.Weight = &zero
+			}
+			for _, tc := range m.ToolCalls {
+				if def, ok := defs[tc.Function.Name]; ok && !used[tc.Function.Name] {
+					used[tc.Function.Name] = true
+					example.Tools = append(example.Tools, def)
+				}
+			}
+			example.Messages[i] = m
+		}
+		examples = append(examples, example)
+	}
+	return examples, failed
+}
+
+// allToolDefs indexes the definition of every tool tinyagent has, offered in this session or not, by name.
+func allToolDefs() map[string]json.RawMessage {
+	all := []string{toolDef, writeToolDef}
+	for _, ts := range toolsets {
+		all = append(all, ts.def)
+	}
+	var list []json.RawMessage
+	json.Unmarshal([]byte(joinToolDefs(all...)), &list)
+	defs := map[string]json.RawMessage{}
+	for _, def := range list {
+		var d struct {
+			Function struct {
+				Name string `json:"name"`
+			} `json:"function"`
+		}
+		if json.Unmarshal(def, &d) == nil {
+			defs[d.Function.Name] = def
+		}
+	}
+	return defs
+}
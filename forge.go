@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// poster publishes an answer on an issue, pull request, or merge request and returns a link to it.
+type poster func(ctx context.Context, number int, mission, answer string) (string, error)
+
+// commentBody is the answer plus a footer naming the mission, so readers of the thread know what was asked.
+func commentBody(mission, answer string) string {
+	title, _, _ := strings.Cut(mission, "\n")
+	return fmt.Sprintf("%s\n\n<sub>Answer from tinyagent (%s) to: %s</sub>", answer, *model, clip(title))
+}
+
+// originPoster picks the forge of the origin remote for /comment.
+func originPoster() (poster, error) {
+	if repoSlug() != "" {
+		return postGitHubComment, nil
+	}
+	if _, project := gitlabRemote(); project != "" {
+		return postGitLabNote, nil
+	}
+	return nil, fmt.Errorf("the origin remote is neither GitHub nor GitLab, pass --github-repo or --gitlab-project")
+}
+
+// shareAnswer posts a final answer with post, reporting where it went; a failure never ends the session.
+func shareAnswer(post poster, number int, mission, answer string) {
+	link, err := post(context.Background(), number, mission, answer)
+	if err != nil {
+		event(slog.LevelError, fmt.Sprintf("\033[31mError: could not comment on #%d: %v\033[0m\n", number, err), "comment failed", "number", number, "err", err)
+		return
+	}
+	event(slog.LevelInfo, fmt.Sprintf("\033[90mPosted to %s\033[0m\n", link), "comment posted", "number", number, "url", link)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// The github_issue tool lets a mission like "investigate issue #42" start from the actual report: title, body,
+// and discussion, plus the changed files for a pull request. The repository comes from --github-repo or the
+// origin remote, and GITHUB_TOKEN or GH_TOKEN is used when set, which private repos and rate limits need.
+var (
+	githubRepo = flag.String("github-repo", "", "GitHub repository as owner/name for the GitHub tools (default: from the origin remote)")
+	githubAPI  = flag.String("github-api", "https://api.github.com", "GitHub API base URL, for GitHub Enterprise")
+)
+
+const githubToolDef = `[
+		{"type":"function","function":{"name":"github_issue","description":"Fetch a GitHub issue or pull request: title, state, labels, description, and comments, plus changed files for a pull request.","parameters":{"type":"object","properties":{
+			"number":{"type":"integer","description":"Issue or pull request number"},
+			"repo":{"type":"string","description":"Optional owner/name, defaults to this repository"} },"required":["number"]}}}
+		]`
+
+// maxIssueComments bounds the discussion pulled into one result; the newest are the ones dropped.
+const maxIssueComments = 50
+
+var githubTools = registerToolset(&toolset{
+	def:      githubToolDef,
+	enabled:  func() bool { return repoSlug() != "" },
+	readOnly: true,
+	run: map[string]func(context.Context, string) (string, error){
+		"github_issue": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Number int    `json:"number"`
+				Repo   string `json:"repo"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			return githubIssue(ctx, params.Repo, params.Number)
+		},
+	},
+})
+
+var githubRemote = regexp.MustCompile(`github\.com[:/]([\w.-]+/[\w.-]+?)(?:\.git)?/?$`)
+
+// repoSlug returns the owner/name of this repository on GitHub, or "" when it isn't one.
+var repoSlug = sync.OnceValue(func() string {
+	if *githubRepo != "" {
+		return *githubRepo
+	}
+	out, err := exec.Command("git", "remote", "get-url", "origin").Output()
+	if err != nil {
+		return ""
+	}
+	if m := githubRemote.FindStringSubmatch(strings.TrimSpace(string(out))); m != nil {
+		return m[1]
+	}
+	return ""
+})
+
+func githubHeader() http.Header {
+	h := http.Header{"Accept": {"application/vnd.github+json"}, "X-Github-Api-Version": {"2022-11-28"}}
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		token = os.Getenv("GH_TOKEN")
+	}
+	if token != "" {
+		h.Set("Authorization", "Bearer "+token)
+	}
+	return h
+}
+
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+type githubComment struct {
+	User      githubUser `json:"user"`
+	CreatedAt string     `json:"created_at"`
+	Body      string     `json:"body"`
+}
+
+func githubIssue(ctx context.Context, repo string, number int) (string, error) {
+	if repo == "" {
+		repo = repoSlug()
+	}
+	if !strings.Contains(repo, "/") {
+		return "", fmt.Errorf("Permanent Error: repo must be owner/name, got %q", repo)
+	}
+	base := fmt.Sprintf("%s/repos/%s", strings.TrimSuffix(*githubAPI, "/"), repo)
+	var issue struct {
+		Title     string     `json:"title"`
+		State     string     `json:"state"`
+		User      githubUser `json:"user"`
+		Body      string     `json:"body"`
+		HTMLURL   string     `json:"html_url"`
+		CreatedAt string     `json:"created_at"`
+		Labels    []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+		PullRequest *struct{} `json:"pull_request"`
+	}
+	if err := getJSON(ctx, fmt.Sprintf("%s/issues/%d", base, number), githubHeader(), &issue); err != nil {
+		return "", fmt.Errorf("Error fetching %s#%d: %v", repo, number, err)
+	}
+
+	kind := "Issue"
+	if issue.PullRequest != nil {
+		kind = "Pull request"
+	}
+	var labels []string
+	for _, l := range issue.Labels {
+		labels = append(labels, l.Name)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "github_issue %s#%d results\n%s: %s\nState: %s, opened by %s on %s\n", repo, number, kind, issue.Title, issue.State, issue.User.Login, issue.CreatedAt)
+	if len(labels) > 0 {
+		fmt.Fprintf(&b, "Labels: %s\n", strings.Join(labels, ", "))
+	}
+	fmt.Fprintf(&b, "URL: %s\n\n%s\n", issue.HTMLURL, strings.TrimSpace(issue.Body))
+
+	if issue.PullRequest != nil {
+		var pr struct {
+			Head struct {
+				Ref string `json:"ref"`
+			} `json:"head"`
+			Base struct {
+				Ref string `json:"ref"`
+			} `json:"base"`
+			Merged bool `json:"merged"`
+		}
+		var files []struct {
+			Filename  string `json:"filename"`
+			Status    string `json:"status"`
+			Additions int    `json:"additions"`
+			Deletions int    `json:"deletions"`
+		}
+		if err := getJSON(ctx, fmt.Sprintf("%s/pulls/%d", base, number), githubHeader(), &pr); err == nil {
+			fmt.Fprintf(&b, "\nMerges %s into %s (merged: %v)\n", pr.Head.Ref, pr.Base.Ref, pr.Merged)
+		}
+		if err := getJSON(ctx, fmt.Sprintf("%s/pulls/%d/files?per_page=100", base, number), githubHeader(), &files); err == nil {
+			fmt.Fprintf(&b, "Changed files (%d):\n", len(files))
+			for _, f := range files {
+				fmt.Fprintf(&b, "- %s (%s, +%d -%d)\n", f.Filename, f.Status, f.Additions, f.Deletions)
+			}
+		}
+	}
+
+	var comments []githubComment
+	if err := getJSON(ctx, fmt.Sprintf("%s/issues/%d/comments?per_page=%d", base, number, maxIssueComments), githubHeader(), &comments); err != nil {
+		fmt.Fprintf(&b, "\n(comments could not be fetched: %v)\n", err)
+	}
+	for _, c := range comments {
+		fmt.Fprintf(&b, "\n--- %s on %s:\n%s\n", c.User.Login, c.CreatedAt, strings.TrimSpace(c.Body))
+	}
+	if len(comments) == maxIssueComments {
+		fmt.Fprintf(&b, "\n(only the first %d comments are shown)\n", maxIssueComments)
+	}
+	return b.String(), nil
+}
+
+// --github-comment posts every final answer as a comment on that issue or pull request, closing the loop
+// for triage workflows. It is opt-in because it publishes, and it needs a token.
+var githubCommentOn = flag.Int("github-comment", 0, "Post each final answer as a comment on this GitHub issue or pull request number (needs GITHUB_TOKEN)")
+
+// postGitHubComment publishes answer on issue number of this repository and returns the comment's URL.
+func postGitHubComment(ctx context.Context, number int, mission, answer string) (string, error) {
+	if os.Getenv("GITHUB_TOKEN") == "" && os.Getenv("GH_TOKEN") == "" {
+		return "", fmt.Errorf("posting to GitHub needs GITHUB_TOKEN or GH_TOKEN")
+	}
+	repo := repoSlug()
+	if repo == "" {
+		return "", fmt.Errorf("no GitHub repository, pass --github-repo owner/name")
+	}
+	body := commentBody(mission, answer)
+	var created struct {
+		HTMLURL string `json:"html_url"`
+	}
+	url := fmt.Sprintf("%s/repos/%s/issues/%d/comments", strings.TrimSuffix(*githubAPI, "/"), repo, number)
+	if err := sendJSON(ctx, "POST", url, githubHeader(), map[string]string{"body": body}, &created); err != nil {
+		return "", err
+	}
+	return created.HTMLURL, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// GitLab gets the same treatment as GitHub: the gitlab_merge_request tool fetches a merge request's description,
+// diffs, and discussion, and --gitlab-comment or /comment post answers back as notes. The instance and project
+// come from an origin remote whose host contains "gitlab", or from the flags for anything else. GITLAB_TOKEN is
+// sent when set.
+var (
+	gitlabProject   = flag.String("gitlab-project", "", "GitLab project path such as group/name for the GitLab tools (default: from the origin remote)")
+	gitlabURL       = flag.String("gitlab-url", "", "GitLab base URL (default: the origin remote's host)")
+	gitlabCommentOn = flag.Int("gitlab-comment", 0, "Post each final answer as a note on this GitLab merge request number (needs GITLAB_TOKEN)")
+)
+
+const gitlabToolDef = `[
+		{"type":"function","function":{"name":"gitlab_merge_request","description":"Fetch a GitLab merge request: title, state, branches, description, the diff of every changed file, and the discussion.","parameters":{"type":"object","properties":{
+			"number":{"type":"integer","description":"Merge request number (the !iid)"},
+			"project":{"type":"string","description":"Optional group/name, defaults to this repository"} },"required":["number"]}}}
+		]`
+
+// maxMRDiffLines bounds each file's diff in a result, so one generated file can't crowd out the rest.
+const maxMRDiffLines = 200
+
+var gitlabTools = registerToolset(&toolset{
+	def:      gitlabToolDef,
+	enabled:  func() bool { _, project := gitlabRemote(); return project != "" },
+	readOnly: true,
+	run: map[string]func(context.Context, string) (string, error){
+		"gitlab_merge_request": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Number  int    `json:"number"`
+				Project string `json:"project"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			return gitlabMergeRequest(ctx, params.Project, params.Number)
+		},
+	},
+})
+
+var gitlabRemotePattern = regexp.MustCompile(`^(?:https?://|ssh://)?(?:[^@/]+@)?([^/:]*gitlab[^/:]*)(?::\d+)?[:/](.+?)(?:\.git)?/?$`)
+
+// gitlabRemote returns the GitLab base URL and project path, or "" for both when this isn't a GitLab repository.
+var gitlabRemote = sync.OnceValues(func() (string, string) {
+	base, project := *gitlabURL, *gitlabProject
+	if base == "" || project == "" {
+		out, err := exec.Command("git", "remote", "get-url", "origin").Output()
+		if m := gitlabRemotePattern.FindStringSubmatch(strings.TrimSpace(string(out))); err == nil && m != nil {
+			if base == "" {
+				base = "https://" + m[1]
+			}
+			if project == "" {
+				project = m[2]
+			}
+		}
+	}
+	if base == "" || project == "" {
+		return "", ""
+	}
+	return strings.TrimSuffix(base, "/"), project
+})
+
+func gitlabHeader() http.Header {
+	h := http.Header{}
+	if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+		h.Set("Private-Token", token)
+	}
+	return h
+}
+
+type gitlabUser struct {
+	Username string `json:"username"`
+}
+
+func gitlabMergeRequest(ctx context.Context, project string, number int) (string, error) {
+	base, defaultProject := gitlabRemote()
+	if project == "" {
+		project = defaultProject
+	}
+	if base == "" || project == "" {
+		return "", fmt.Errorf("Permanent Error: no GitLab project, pass --gitlab-url and --gitlab-project")
+	}
+	api := fmt.Sprintf("%s/api/v4/projects/%s/merge_requests/%d", base, url.PathEscape(project), number)
+
+	var mr struct {
+		Title        string     `json:"title"`
+		State        string     `json:"state"`
+		Author       gitlabUser `json:"author"`
+		Description  string     `json:"description"`
+		WebURL       string     `json:"web_url"`
+		SourceBranch string     `json:"source_branch"`
+		TargetBranch string     `json:"target_branch"`
+		Labels       []string   `json:"labels"`
+		Changes      []struct {
+			OldPath string `json:"old_path"`
+			NewPath string `json:"new_path"`
+			Diff    string `json:"diff"`
+		} `json:"changes"`
+	}
+	if err := getJSON(ctx, api+"/changes", gitlabHeader(), &mr); err != nil {
+		return "", fmt.Errorf("Error fetching %s!%d: %v", project, number, err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "gitlab_merge_request %s!%d results\nMerge request: %s\nState: %s, opened by %s, merges %s into %s\n",
+		project, number, mr.Title, mr.State, mr.Author.Username, mr.SourceBranch, mr.TargetBranch)
+	if len(mr.Labels) > 0 {
+		fmt.Fprintf(&b, "Labels: %s\n", strings.Join(mr.Labels, ", "))
+	}
+	fmt.Fprintf(&b, "URL: %s\n\n%s\n", mr.WebURL, strings.TrimSpace(mr.Description))
+
+	fmt.Fprintf(&b, "\nChanged files (%d):\n", len(mr.Changes))
+	for _, c := range mr.Changes {
+		name := c.NewPath
+		if c.OldPath != c.NewPath {
+			name = c.OldPath + " -> " + c.NewPath
+		}
+		lines := strings.Split(strings.TrimRight(c.Diff, "\n"), "\n")
+		fmt.Fprintf(&b, "\n--- %s\n%s\n", name, strings.Join(lines[:min(len(lines), maxMRDiffLines)], "\n"))
+		if len(lines) > maxMRDiffLines {
+			fmt.Fprintf(&b, "(%d more diff lines)\n", len(lines)-maxMRDiffLines)
+		}
+	}
+
+	var discussions []struct {
+		Notes []struct {
+			Author    gitlabUser `json:"author"`
+			Body      string     `json:"body"`
+			System    bool       `json:"system"`
+			CreatedAt string     `json:"created_at"`
+			Position  *struct {
+				NewPath string `json:"new_path"`
+				NewLine int    `json:"new_line"`
+			} `json:"position"`
+		} `json:"notes"`
+	}
+	if err := getJSON(ctx, api+"/discussions?per_page=100", gitlabHeader(), &discussions); err != nil {
+		fmt.Fprintf(&b, "\n(discussion could not be fetched: %v)\n", err)
+	}
+	for _, d := range discussions {
+		for i, n := range d.Notes {
+			if n.System {
+				continue // "added 1 commit" and the like
+			}
+			where := ""
+			if n.Position != nil && n.Position.NewPath != "" {
+				where = fmt.Sprintf(" at %s:%d", n.Position.NewPath, n.Position.NewLine)
+			}
+			lead := "\n---"
+			if i > 0 {
+				lead = "  reply from" // threads read as one block
+			}
+			fmt.Fprintf(&b, "%s %s on %s%s:\n%s\n", lead, n.Author.Username, n.CreatedAt, where, strings.TrimSpace(n.Body))
+		}
+	}
+	return b.String(), nil
+}
+
+// postGitLabNote publishes answer on merge request number and returns the merge request's URL.
+func postGitLabNote(ctx context.Context, number int, mission, answer string) (string, error) {
+	if os.Getenv("GITLAB_TOKEN") == "" {
+		return "", fmt.Errorf("posting to GitLab needs GITLAB_TOKEN")
+	}
+	base, project := gitlabRemote()
+	if project == "" {
+		return "", fmt.Errorf("no GitLab project, pass --gitlab-url and --gitlab-project")
+	}
+	body := commentBody(mission, answer)
+	api := fmt.Sprintf("%s/api/v4/projects/%s/merge_requests/%d/notes", base, url.PathEscape(project), number)
+	if err := sendJSON(ctx, "POST", api, gitlabHeader(), map[string]string{"body": body}, nil); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s/-/merge_requests/%d", base, project, number), nil
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+)
+
+// In a Go module the agent can ask gopls instead of paging through files: where a symbol is defined, what its
+// signature and docs are, and what implements an interface. Those answers come from the type checker, so they
+// hold across packages and through embedding where a text search guesses. gopls is started on first use and
+// spoken to as a language server over stdin and stdout; the tools are only offered when it is on the PATH.
+var goplsPath = flag.String("gopls", "gopls", "gopls binary used by the Go code intelligence tools")
+
+const goplsToolDef = `[
+		{"type":"function","function":{"name":"go_definition","description":"Find where a Go identifier is defined, using the type checker. Point at a use of it by file, line, and name.","parameters":{"type":"object","properties":{
+			"path":{"type":"string","description":"Go file relative to current working directory"},
+			"line":{"type":"integer","description":"1-based line number on which the identifier appears"},
+			"symbol":{"type":"string","description":"The identifier as written on that line, such as Client or Do"} },"required":["path","line","symbol"]}}},
+		{"type":"function","function":{"name":"go_hover","description":"Show the type, signature, and documentation of a Go identifier, using the type checker.","parameters":{"type":"object","properties":{
+			"path":{"type":"string","description":"Go file relative to current working directory"},
+			"line":{"type":"integer","description":"1-based line number on which the identifier appears"},
+			"symbol":{"type":"string","description":"The identifier as written on that line"} },"required":["path","line","symbol"]}}},
+		{"type":"function","function":{"name":"go_implementations","description":"List the types implementing a Go interface, or the interfaces a type implements, using the type checker.","parameters":{"type":"object","properties":{
+			"path":{"type":"string","description":"Go file relative to current working directory"},
+			"line":{"type":"integer","description":"1-based line number on which the type or interface name appears"},
+			"symbol":{"type":"string","description":"The type or interface name as written on that line"} },"required":["path","line","symbol"]}}}
+		]`
+
+var goplsTools = registerToolset(&toolset{
+	def: goplsToolDef,
+	enabled: func() bool {
+		_, err := exec.LookPath(*goplsPath)
+		_, mod := os.Stat("go.mod")
+		return err == nil && mod == nil && *remote == "" // gopls reads the files from local disk itself
+	},
+	readOnly: true,
+	run: map[string]func(context.Context, string) (string, error){
+		"go_definition":      goplsTool("go_definition", "textDocument/definition"),
+		"go_hover":           goplsTool("go_hover", "textDocument/hover"),
+		"go_implementations": goplsTool("go_implementations", "textDocument/implementation"),
+	},
+})
+
+// lspClient is a JSON-RPC connection to a language server.
+type lspClient struct {
+	in     io.WriteCloser
+	writes sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan lspResponse
+	opened  map[string]lspDocument
+	err     error // why the server stopped, once it has
+}
+
+type lspResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// gopls starts the server on first use; a failure to start is kept and reported by every call.
+var gopls = sync.OnceValues(func() (*lspClient, error) {
+	cmd := exec.Command(*goplsPath, "serve")
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting gopls: %v", err)
+	}
+	c := &lspClient{in: in, pending: map[int]chan lspResponse{}, opened: map[string]lspDocument{}}
+	go c.readLoop(bufio.NewReader(out))
+
+	root, _ := os.Getwd()
+	var initialized json.RawMessage
+	err = c.call(context.Background(), "initialize", map[string]any{
+		"processId":    os.Getpid(),
+		"rootUri":      fileURI(root),
+		"capabilities": map[string]any{"textDocument": map[string]any{"hover": map[string]any{"contentFormat": []string{"plaintext"}}}},
+	}, &initialized)
+	if err != nil {
+		return nil, fmt.Errorf("initializing gopls: %v", err)
+	}
+	c.send(map[string]any{"jsonrpc": "2.0", "method": "initialized", "params": map[string]any{}})
+	return c, nil
+})
+
+func fileURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+func (c *lspClient) send(msg any) error {
+	raw, _ := json.Marshal(msg)
+	c.writes.Lock()
+	defer c.writes.Unlock()
+	_, err := fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(raw), raw)
+	return err
+}
+
+// readLoop hands each response to its caller and answers the server's own requests with an empty result,
+// which gopls accepts for the configuration and progress requests it sends.
+func (c *lspClient) readLoop(r *bufio.Reader) {
+	headers := textproto.NewReader(r)
+	for {
+		header, err := headers.ReadMIMEHeader()
+		var size int
+		if err == nil {
+			size, err = strconv.Atoi(header.Get("Content-Length"))
+		}
+		body := make([]byte, size)
+		if err == nil {
+			_, err = io.ReadFull(r, body)
+		}
+		if err != nil {
+			c.mu.Lock()
+			c.err = fmt.Errorf("gopls stopped: %v", err)
+			for id, ch := range c.pending {
+				close(ch)
+				delete(c.pending, id)
+			}
+			c.mu.Unlock()
+			return
+		}
+		var msg struct {
+			ID     *json.RawMessage `json:"id"`
+			Method string           `json:"method"`
+			lspResponse
+		}
+		if json.Unmarshal(body, &msg) != nil || msg.ID == nil {
+			continue // notifications, like diagnostics
+		}
+		if msg.Method != "" {
+			c.send(map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": nil})
+			continue
+		}
+		id, _ := strconv.Atoi(string(*msg.ID))
+		c.mu.Lock()
+		ch := c.pending[id]
+		delete(c.pending, id)
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- msg.lspResponse
+		}
+	}
+}
+
+// call sends a request and decodes its result into v.
+func (c *lspClient) call(ctx context.Context, method string, params, v any) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id, ch := c.nextID, make(chan lspResponse, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+	if err := c.send(map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params}); err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return ctx.Err()
+	case resp, ok := <-ch:
+		if !ok {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return c.err
+		}
+		if resp.Error != nil {
+			return fmt.Errorf("%s: %s", method, resp.Error.Message)
+		}
+		return json.Unmarshal(resp.Result, v)
+	}
+}
+
+// open tells the server about a file before it is asked about it, as the protocol requires, and about its new
+// content when it has changed on disk since.
+func (c *lspClient) open(uri, text string) error {
+	c.mu.Lock()
+	doc, seen := c.opened[uri]
+	c.opened[uri] = lspDocument{version: doc.version + 1, text: text}
+	c.mu.Unlock()
+	switch {
+	case !seen:
+		return c.send(map[string]any{"jsonrpc": "2.0", "method": "textDocument/didOpen", "params": map[string]any{
+			"textDocument": map[string]any{"uri": uri, "languageId": "go", "version": 1, "text": text},
+		}})
+	case doc.text != text:
+		return c.send(map[string]any{"jsonrpc": "2.0", "method": "textDocument/didChange", "params": map[string]any{
+			"textDocument":   map[string]any{"uri": uri, "version": doc.version + 1},
+			"contentChanges": []map[string]string{{"text": text}},
+		}})
+	}
+	return nil
+}
+
+type lspDocument struct {
+	version int
+	text    string
+}
+
+type lspLocation struct {
+	URI   string `json:"uri"`
+	Range struct {
+		Start struct {
+			Line      int `json:"line"`
+			Character int `json:"character"`
+		} `json:"start"`
+	} `json:"range"`
+}
+
+// goplsTool returns the handler for one query. Models count lines well and columns badly, so the position is
+// given as a line and the identifier on it.
+func goplsTool(tool, method string) func(context.Context, string) (string, error) {
+	return func(ctx context.Context, args string) (string, error) {
+		var params struct {
+			Path   string `json:"path"`
+			Line   int    `json:"line"`
+			Symbol string `json:"symbol"`
+		}
+		json.Unmarshal([]byte(args), &params)
+		if !filepath.IsLocal(params.Path) {
+			return "", fmt.Errorf("Permanent Error: Path %s is outside of current working directory", params.Path)
+		}
+		raw, err := os.ReadFile(params.Path)
+		if err != nil {
+			return "", fmt.Errorf("Error reading file: %v", err)
+		}
+		lines := strings.Split(string(raw), "\n")
+		if params.Line < 1 || params.Line > len(lines) {
+			return "", fmt.Errorf("line %d is outside %s, which has %d lines", params.Line, toolPath(params.Path), len(lines))
+		}
+		text := lines[params.Line-1]
+		at := regexp.MustCompile(`\b` + regexp.QuoteMeta(params.Symbol) + `\b`).FindStringIndex(text)
+		if params.Symbol == "" || at == nil {
+			return "", fmt.Errorf("%q does not appear on line %d of %s, which reads: %s", params.Symbol, params.Line, toolPath(params.Path), strings.TrimSpace(text))
+		}
+
+		c, err := gopls()
+		if err != nil {
+			return "", err
+		}
+		abs, _ := filepath.Abs(params.Path)
+		uri := fileURI(abs)
+		if err := c.open(uri, string(raw)); err != nil {
+			return "", err
+		}
+		position := map[string]any{
+			"textDocument": map[string]string{"uri": uri},
+			"position":     map[string]int{"line": params.Line - 1, "character": len(utf16.Encode([]rune(text[:at[0]])))},
+		}
+		header := fmt.Sprintf("%s %s at %s:%d results\n", tool, params.Symbol, toolPath(params.Path), params.Line)
+
+		if method == "textDocument/hover" {
+			var hover *struct {
+				Contents struct {
+					Value string `json:"value"`
+				} `json:"contents"`
+			}
+			if err := c.call(ctx, method, position, &hover); err != nil {
+				return "", err
+			}
+			if hover == nil || hover.Contents.Value == "" {
+				return header + "Nothing is known about this identifier.", nil
+			}
+			return header + strings.TrimSpace(hover.Contents.Value), nil
+		}
+
+		var found json.RawMessage
+		if err := c.call(ctx, method, position, &found); err != nil {
+			return "", err
+		}
+		var locations []lspLocation
+		if json.Unmarshal(found, &locations) != nil {
+			var one lspLocation // a server may answer with a single location instead of a list
+			json.Unmarshal(found, &one)
+			locations = []lspLocation{one}
+		}
+		if len(locations) == 0 {
+			return header + "No results.", nil
+		}
+		var b strings.Builder
+		b.WriteString(header)
+		for _, loc := range locations {
+			fmt.Fprintf(&b, "- %s\n", describeLocation(loc))
+		}
+		return b.String(), nil
+	}
+}
+
+// describeLocation renders a location as path:line with the source line, relative to the working directory
+// when it is inside it.
+func describeLocation(loc lspLocation) string {
+	u, err := url.Parse(loc.URI)
+	if err != nil {
+		return loc.URI
+	}
+	path := filepath.FromSlash(u.Path)
+	shown := path
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && filepath.IsLocal(rel) {
+			shown = rel
+		}
+	}
+	line := loc.Range.Start.Line + 1
+	if raw, err := os.ReadFile(path); err == nil {
+		if lines := strings.Split(string(raw), "\n"); line <= len(lines) {
+			return fmt.Sprintf("%s:%d: %s", shown, line, clip(strings.TrimSpace(lines[line-1])))
+		}
+	}
+	return fmt.Sprintf("%s:%d", shown, line)
+}
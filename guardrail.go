@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// --policy checks every tool call against a policy before it runs, and blocks the ones that break it; the model
+// is told why, so it can find another way. A policy file has one rule per line. A line like
+//
+//	deny * (^|/)\.git(/|$)
+//	deny run_command \bcurl\b.*-X *(POST|PUT|DELETE)
+//
+// blocks calls to the tools matching the glob when any argument matches the regular expression, checked here for
+// free. Every other line is plain language, such as "never change files under migrations/", and is judged by
+// --policy-model, which can be a much cheaper model than the one doing the work. A call the policy model can't
+// judge is blocked, since a guardrail that fails open guards nothing. Lines starting with # are comments.
+var (
+	policyFile  = flag.String("policy", "", "Check each tool call against the rules in this file before running it, blocking violations")
+	policyModel = flag.String("policy-model", "", "Model judging the plain-language rules of --policy (default: --model)")
+)
+
+const policyPrompt = `You enforce a policy on the tool calls of a software agent. Decide whether the call below breaks any rule of the policy. Only the policy matters, not whether the call is useful. Reply with ALLOW, or with BLOCK: followed by the rule it breaks and why, in one sentence.
+
+The policy:
+%s`
+
+type policyRule struct {
+	tools string
+	args  *regexp.Regexp
+	line  string
+}
+
+type toolPolicy struct {
+	rules []policyRule
+	prose []string // plain-language rules, for the policy model
+}
+
+// policy parses --policy; main reports an invalid one before any mission starts.
+var policy = sync.OnceValues(func() (*toolPolicy, error) {
+	if *policyFile == "" {
+		return nil, nil
+	}
+	raw, err := os.ReadFile(*policyFile)
+	if err != nil {
+		return nil, fmt.Errorf("--policy: %v", err)
+	}
+	p := &toolPolicy{}
+	for i, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if fields[0] != "deny" {
+			p.prose = append(p.prose, line)
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("--policy: line %d: want deny TOOL-GLOB REGEXP", i+1)
+		}
+		if _, err := path.Match(fields[1], ""); err != nil {
+			return nil, fmt.Errorf("--policy: line %d: tool glob %q: %v", i+1, fields[1], err)
+		}
+		pattern := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(line, "deny")), fields[1]))
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("--policy: line %d: %v", i+1, err)
+		}
+		p.rules = append(p.rules, policyRule{fields[1], re, line})
+	}
+	return p, nil
+})
+
+// policyVerdicts remembers the policy model's decisions, since a mission often repeats a call.
+var policyVerdicts sync.Map // name + "\x00" + args -> reason, "" for allowed
+
+// checkPolicy returns why a call is blocked, or "" when the policy allows it or there is none.
+func checkPolicy(ctx context.Context, name, args string) string {
+	p, _ := policy()
+	if p == nil {
+		return ""
+	}
+	values := argStrings(args)
+	for _, rule := range p.rules {
+		if ok, _ := path.Match(rule.tools, name); !ok {
+			continue
+		}
+		for _, v := range values {
+			if rule.args.MatchString(v) {
+				return "it breaks the rule " + rule.line
+			}
+		}
+	}
+	if len(p.prose) == 0 {
+		return ""
+	}
+	key := name + "\x00" + args
+	if reason, ok := policyVerdicts.Load(key); ok {
+		return reason.(string)
+	}
+	judge := *policyModel
+	if judge == "" {
+		judge = *model
+	}
+	verdict, _, err := sendChatRequest(withTemperature(withPurpose(ctx, "policy"), 0), judge, []ChatMessage{
+		{Role: "system", Content: fmt.Sprintf(policyPrompt, "- "+strings.Join(p.prose, "\n- "))},
+		{Role: "user", Content: fmt.Sprintf("The call: %s with arguments %s", name, args)},
+	}, nil)
+	if err != nil {
+		return fmt.Sprintf("the policy check failed (%v), so the call was not run", err)
+	}
+	reply := strings.TrimSpace(verdict.Content)
+	reason := ""
+	switch upper := strings.ToUpper(reply); {
+	case strings.HasPrefix(upper, "BLOCK"):
+		reason = strings.TrimSpace(strings.TrimLeft(reply[len("BLOCK"):], ":- "))
+		if reason == "" {
+			reason = "the policy model judged it against the policy"
+		}
+	case !strings.HasPrefix(upper, "ALLOW"):
+		reason = fmt.Sprintf("the policy model's verdict was unclear (%q), so the call was not run", clip(reply))
+	}
+	policyVerdicts.Store(key, reason)
+	return reason
+}
+
+// argStrings collects every string and number among a call's arguments, however deeply nested, for the rules to
+// match.
+func argStrings(args string) []string {
+	var v any
+	if json.Unmarshal([]byte(args), &v) != nil {
+		return []string{args}
+	}
+	var out []string
+	var walk func(any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case string:
+			out = append(out, v)
+		case float64, bool:
+			out = append(out, fmt.Sprint(v))
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string]any:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(v)
+	return out
+}
+
+// blockedCall reports a call the policy stopped and returns the error the model sees.
+func blockedCall(name, reason string) error {
+	event(slog.LevelWarn, fmt.Sprintf("\033[33m🛡  Blocked %s: %s\033[0m\n", name, reason), "tool call blocked", "tool", name, "reason", reason)
+	return fmt.Errorf("Permanent Error: blocked by policy: %s. Don't retry it; find another way that keeps to the policy, or explain in your answer what the policy prevented", reason)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Code blocks in answers are highlighted with a small tokenizer instead of a full lexer library: comments,
+// strings, numbers, and keywords cover most of what makes a snippet readable at a glance. Diffs get their own
+// line-based coloring since that is what reviewers scan for.
+type syntax struct {
+	comment  string // regexp alternatives for comments
+	keywords map[string]bool
+}
+
+func words(s string) map[string]bool {
+	m := map[string]bool{}
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var (
+	cStyle    = `//[^\n]*|/\*.*?\*/`
+	hashStyle = `#[^\n]*`
+	syntaxes  = map[string]syntax{
+		"go":         {cStyle, words("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false iota error string int bool byte rune any")},
+		"python":     {hashStyle, words("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield None True False self")},
+		"javascript": {cStyle, words("async await break case catch class const continue default delete do else export extends finally for function if import in instanceof let new of return super switch this throw try typeof var void while yield null undefined true false interface type enum implements")},
+		"rust":       {cStyle, words("as async await break const continue crate dyn else enum extern fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait type unsafe use where while true false Some None Ok Err")},
+		"shell":      {hashStyle, words("if then else elif fi for while do done case esac in function return export local echo exit set")},
+		"java":       {cStyle, words("abstract boolean break case catch class const continue default do double else enum extends final finally float for if implements import instanceof int interface long new null package private protected public return static super switch this throw throws try void while true false")},
+		"sql":        {`--[^\n]*`, words("select from where join left right inner outer on group by order having limit insert into values update set delete create table index drop alter and or not null as distinct union all case when then else end SELECT FROM WHERE JOIN LEFT RIGHT INNER OUTER ON GROUP BY ORDER HAVING LIMIT INSERT INTO VALUES UPDATE SET DELETE CREATE TABLE INDEX DROP ALTER AND OR NOT NULL AS DISTINCT UNION ALL CASE WHEN THEN ELSE END")},
+		"json":       {`$^`, words("true false null")},
+		"yaml":       {hashStyle, words("true false null yes no")},
+	}
+	langAliases = map[string]string{
+		"golang": "go", "py": "python", "js": "javascript", "ts": "javascript", "typescript": "javascript",
+		"jsx": "javascript", "tsx": "javascript", "rs": "rust", "sh": "shell", "bash": "shell", "zsh": "shell",
+		"console": "shell", "kotlin": "java", "c": "java", "cpp": "java", "c++": "java", "cs": "java", "csharp": "java",
+		"yml": "yaml", "patch": "diff",
+	}
+	tokenCache = map[string]*regexp.Regexp{}
+)
+
+// guessLanguage covers unlabeled fences, which small models emit more often than labeled ones.
+func guessLanguage(lines []string) string {
+	code := strings.Join(lines, "\n")
+	switch {
+	case strings.Contains(code, "\n@@ ") || strings.HasPrefix(code, "--- ") || strings.HasPrefix(code, "diff "):
+		return "diff"
+	case strings.Contains(code, "func ") || strings.HasPrefix(code, "package "):
+		return "go"
+	case strings.Contains(code, "def ") || strings.Contains(code, "import ") && strings.Contains(code, ":\n"):
+		return "python"
+	case strings.Contains(code, "fn ") && strings.Contains(code, "let "):
+		return "rust"
+	case strings.Contains(code, "function ") || strings.Contains(code, "const ") || strings.Contains(code, "=>"):
+		return "javascript"
+	case strings.HasPrefix(strings.TrimSpace(code), "{") || strings.HasPrefix(strings.TrimSpace(code), "["):
+		return "json"
+	case strings.HasPrefix(code, "$ ") || strings.HasPrefix(code, "#!/"):
+		return "shell"
+	}
+	return ""
+}
+
+// highlight colors one code block, returning its lines unchanged for languages it doesn't know.
+func highlight(lang string, lines []string) []string {
+	lang = strings.ToLower(lang)
+	if alias, ok := langAliases[lang]; ok {
+		lang = alias
+	}
+	if lang == "" {
+		lang = guessLanguage(lines)
+	}
+	if lang == "diff" {
+		return highlightDiff(lines)
+	}
+	sx, ok := syntaxes[lang]
+	if !ok {
+		return lines
+	}
+	tokens, ok := tokenCache[lang]
+	if !ok {
+		tokens = regexp.MustCompile(`(?s)(` + sx.comment + `)|("(?:[^"\\\n]|\\.)*"|'(?:[^'\\\n]|\\.)*'|` + "`[^`]*`" + `)|(\b\d[\d_.xXa-fA-F]*\b)|([A-Za-z_]\w*)`)
+		tokenCache[lang] = tokens
+	}
+
+	// Block comments and raw strings span lines, so the block is tokenized whole and split again afterwards.
+	code := strings.Join(lines, "\n")
+	var b strings.Builder
+	last := 0
+	for _, m := range tokens.FindAllStringSubmatchIndex(code, -1) {
+		b.WriteString(code[last:m[0]])
+		text := code[m[0]:m[1]]
+		switch {
+		case m[2] >= 0:
+			b.WriteString(colorLines("\033[90m", text))
+		case m[4] >= 0:
+			b.WriteString(colorLines("\033[32m", text))
+		case m[6] >= 0:
+			b.WriteString("\033[35m" + text + mdReset)
+		case sx.keywords[text]:
+			b.WriteString("\033[34m" + text + mdReset)
+		default:
+			b.WriteString(text)
+		}
+		last = m[1]
+	}
+	b.WriteString(code[last:])
+	return strings.Split(b.String(), "\n")
+}
+
+// colorLines applies a color to text that may contain newlines, resetting at each line end so the gutter the
+// caller draws in front of every line keeps its own color.
+func colorLines(color, text string) string {
+	return color + strings.ReplaceAll(text, "\n", mdReset+"\n"+color) + mdReset
+}
+
+func highlightDiff(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---"):
+			out[i] = "\033[1m" + line + mdReset
+		case strings.HasPrefix(line, "+"):
+			out[i] = "\033[32m" + line + mdReset
+		case strings.HasPrefix(line, "-"):
+			out[i] = "\033[31m" + line + mdReset
+		case strings.HasPrefix(line, "@@"):
+			out[i] = "\033[36m" + line + mdReset
+		default:
+			out[i] = line
+		}
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Providers reject an oversized prompt with an opaque "400 Bad Request" halfway through a mission. Estimating the
+// prompt before each request lets old tool results be condensed first, while the recent ones stay verbatim.
+var contextTokens = flag.Int("context-tokens", 32000, "Prompt budget in estimated tokens; older tool results are condensed to stay under it (0 disables)")
+
+const (
+	keepRecentMessages = 6   // never condense the latest messages, the model is still working with them
+	condensedKeep      = 300 // characters of an old tool result kept as a reminder of what it said
+	condensedMarker    = "\n[condensed to save context, "
+)
+
+// historyTokens estimates the prompt size of a conversation, including tool-call arguments and the tool schema.
+func historyTokens(messages []ChatMessage, tools string) int {
+	total := estimateTokens(tools)
+	for _, m := range messages {
+		total += 4 + estimateTokens(m.Content) + imageTokens*len(m.Images)
+		for _, tc := range m.ToolCalls {
+			total += estimateTokens(tc.Function.Name + tc.Function.Arguments)
+		}
+	}
+	return total
+}
+
+// trimHistory condenses the oldest tool results in place until the estimate fits budget. Only message contents
+// shrink, so every tool call keeps its matching result and the history stays valid for the API. warned is set
+// once the mission has been told the prompt stays above budget, so it is told only once.
+func trimHistory(messages []ChatMessage, tools string, budget int, warned *bool) {
+	if budget <= 0 {
+		return
+	}
+	before := historyTokens(messages, tools)
+	current, condensed := before, 0
+	for i := range messages[:max(len(messages)-keepRecentMessages, 0)] {
+		if current <= budget {
+			break
+		}
+		m := &messages[i]
+		if len(m.Images) > 0 {
+			// An old screenshot is the costliest thing in the history and the state it shows has moved on.
+			current -= imageTokens * len(m.Images)
+			m.Content += fmt.Sprintf("\n[%d images removed to save context]", len(m.Images))
+			m.Images = nil
+			condensed++
+			continue
+		}
+		if !condensable(*m) {
+			continue
+		}
+		current -= estimateTokens(m.Content)
+		m.Content = condense(m.Content)
+		current += estimateTokens(m.Content)
+		condensed++
+	}
+	if condensed > 0 {
+		event(slog.LevelInfo, fmt.Sprintf("\033[90m✂️  Condensed %d old tool results (~%d → ~%d tokens)\033[0m\n", condensed, before, current),
+			"history trimmed", "condensed", condensed, "tokens_before", before, "tokens_after", current)
+	}
+	if current > budget && !*warned {
+		*warned = true
+		event(slog.LevelWarn, fmt.Sprintf("\033[33mPrompt is still ~%d tokens, above the %d budget\033[0m\n", current, budget),
+			"history over budget", "tokens", current, "budget", budget)
+	}
+}
+
+// recoverOverflow runs once the provider has rejected the prompt as too long, meaning the estimate was off for
+// this model. The budget drops to half of what was sent for the rest of the session, and unlike trimHistory even
+// recent tool results may be condensed. It reports whether the prompt shrank enough to be worth retrying.
+func recoverOverflow(messages []ChatMessage, tools string) bool {
+	before := historyTokens(messages, tools)
+	*contextTokens = before / 2
+	for i := range messages {
+		if historyTokens(messages, tools) <= *contextTokens {
+			break
+		}
+		m := &messages[i]
+		if len(m.Images) > 0 {
+			m.Content += fmt.Sprintf("\n[%d images removed to save context]", len(m.Images))
+			m.Images = nil
+		}
+		if condensable(*m) {
+			m.Content = condense(m.Content)
+		}
+	}
+	after := historyTokens(messages, tools)
+	event(slog.LevelWarn, fmt.Sprintf("\033[33mPrompt exceeded the model's context, shrunk it from ~%d to ~%d tokens and retrying\033[0m\n", before, after),
+		"context overflow", "tokens_before", before, "tokens_after", after, "budget", *contextTokens)
+	return after < before*9/10
+}
+
+// condensable reports whether a message is a long tool result not condensed yet.
+func condensable(m ChatMessage) bool {
+	return m.Role == "tool" && len(m.Content) > 2*condensedKeep && !strings.Contains(m.Content, condensedMarker)
+}
+
+// condense keeps the head of a tool result and notes how much was dropped.
+func condense(content string) string {
+	head := []rune(content)
+	if len(head) > condensedKeep {
+		head = head[:condensedKeep]
+	}
+	return fmt.Sprintf("%s"+condensedMarker+"%d bytes omitted; repeat the tool call if you need the rest]", string(head), len(content)-len(string(head)))
+}
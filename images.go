@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"sync"
+)
+
+// Images reach a vision model as image_url content parts of a user message, the one form every OpenAI-compatible
+// provider accepts; tool results must stay text. A tool that produces an image attaches it to the turn, and the
+// agent loop sends everything attached as one user message after the tool results.
+var vision = flag.Bool("vision", false, "The model accepts images: offers the screenshot tool and allows attaching images")
+
+const (
+	maxImageSide = 1568 // providers downscale anything larger, so sending more only costs upload time
+	imageTokens  = 1500 // rough prompt cost of one image at that size, for the history estimate
+)
+
+// attachedImagesNote introduces the images attached by the tool calls before it.
+const attachedImagesNote = "The images attached by the tool calls above:"
+
+// MarshalJSON sends a message with Images as a list of content parts, and any other as usual.
+func (m ChatMessage) MarshalJSON() ([]byte, error) {
+	type plain ChatMessage
+	if len(m.Images) == 0 {
+		return json.Marshal(plain(m))
+	}
+	parts := []map[string]any{{"type": "text", "text": m.Content}}
+	for _, url := range m.Images {
+		parts = append(parts, map[string]any{"type": "image_url", "image_url": map[string]string{"url": url}})
+	}
+	return json.Marshal(struct {
+		plain
+		Content []map[string]any `json:"content"`
+	}{plain(m), parts})
+}
+
+type imageTrayKey struct{}
+
+// imageTray collects the images attached during one turn; read-only tools run concurrently, hence the lock.
+type imageTray struct {
+	mu     sync.Mutex
+	images []string
+}
+
+func withImageTray(ctx context.Context, tray *imageTray) context.Context {
+	return context.WithValue(ctx, imageTrayKey{}, tray)
+}
+
+// attachImage adds an image, as a data URL, to the current turn. It reports false outside of a mission's turn.
+func attachImage(ctx context.Context, url string) bool {
+	tray, ok := ctx.Value(imageTrayKey{}).(*imageTray)
+	if !ok {
+		return false
+	}
+	tray.mu.Lock()
+	defer tray.mu.Unlock()
+	tray.images = append(tray.images, url)
+	return true
+}
+
+func (t *imageTray) take() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	images := t.images
+	t.images = nil
+	return images
+}
+
+// imageDataURL decodes a PNG or JPEG, shrinks it to fit maxImageSide, and re-encodes it as a JPEG data URL,
+// which is a fraction of a screenshot PNG's size. It also returns the size that was sent.
+func imageDataURL(raw []byte) (url string, width, height int, err error) {
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return "", 0, 0, err
+	}
+	img = shrinkImage(img, maxImageSide)
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, img, &jpeg.Options{Quality: 80}); err != nil {
+		return "", 0, 0, err
+	}
+	size := img.Bounds().Size()
+	return "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(b.Bytes()), size.X, size.Y, nil
+}
+
+// shrinkImage scales img down to fit within side pixels by averaging each block of source pixels, which keeps
+// small text legible where dropping pixels would not.
+func shrinkImage(img image.Image, side int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= side && h <= side {
+		return img
+	}
+	scale := float64(max(w, h)) / float64(side)
+	dw, dh := max(int(float64(w)/scale), 1), max(int(float64(h)/scale), 1)
+	out := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, max((x+1)*w/dw, x*w/dw+1)
+			var r, g, b, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, _ := img.At(bounds.Min.X+sx, bounds.Min.Y+sy).RGBA()
+					r, g, b, n = r+cr, g+cg, b+cb, n+1
+				}
+			}
+			out.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(b / n), 0xffff})
+		}
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// Ctrl-C used to kill the process mid-turn and lose the whole session. Now the first press cancels the running
+// turn, whose in-flight request and tools stop through the turn's context, and returns to the mission prompt.
+// A press with nothing running exits after the cost summary. A second one while the turn winds down exits too,
+// but from the main loop once the turn has stopped, since until then it is still adding to the transcript that
+// exiting saves; a third exits at once, saving nothing.
+type interrupter struct {
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	winding bool // a turn was cancelled and hasn't returned yet
+	quit    bool // exit once it has
+	onExit  func()
+}
+
+func trapInterrupts(onExit func()) *interrupter {
+	in := &interrupter{onExit: onExit}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		for range signals {
+			in.mu.Lock()
+			cancel, winding, quit := in.cancel, in.winding, in.quit
+			in.cancel = nil
+			in.winding = winding || cancel != nil
+			in.quit = winding
+			in.mu.Unlock()
+			switch {
+			case cancel != nil:
+				cancel()
+				event(slog.LevelWarn, "\n\033[33m⏹  Interrupting, press Ctrl-C again to exit\033[0m\n", "interrupted")
+			case winding && !quit:
+				event(slog.LevelWarn, "\n\033[33m⏹  Exiting once the turn has stopped, press Ctrl-C again to exit now\033[0m\n", "exiting")
+			case winding:
+				os.Exit(130)
+			default:
+				in.exit()
+			}
+		}
+	}()
+	return in
+}
+
+// exit ends the session after the cost summary; the main loop calls it when a second Ctrl-C asked to quit.
+func (in *interrupter) exit() {
+	cbreak(false) // Ctrl-C at the mission prompt may land while line editing is on
+	say("\n")
+	in.onExit()
+	os.Exit(130)
+}
+
+// quitting reports whether a Ctrl-C asked to exit while the last turn wound down.
+func (in *interrupter) quitting() bool {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.quit
+}
+
+// begin returns a context for one mission that the next Ctrl-C cancels, replacing the previous one's.
+func (in *interrupter) begin(ctx context.Context) context.Context {
+	ctx, cancel := context.WithCancel(ctx)
+	in.mu.Lock()
+	if in.cancel != nil {
+		in.cancel()
+	}
+	in.cancel, in.winding, in.quit = cancel, false, false
+	in.mu.Unlock()
+	return ctx
+}
+
+// disarm is called once nothing is running, so the next Ctrl-C exits.
+func (in *interrupter) disarm() {
+	in.mu.Lock()
+	if in.cancel != nil {
+		in.cancel()
+	}
+	in.cancel, in.winding = nil, false
+	in.mu.Unlock()
+}
+
+// interrupted saves the transcript after a cancelled turn and reports what the session has cost so far.
+func interrupted(messages []ChatMessage) {
+	if path, err := saveTranscript(messages, ""); err != nil {
+		event(slog.LevelWarn, fmt.Sprintf("\033[33mCould not save transcript: %v\033[0m\n", err), "transcript save failed", "err", err)
+	} else {
+		event(slog.LevelInfo, fmt.Sprintf("\033[90mMission interrupted, transcript saved to %s\033[0m\n", path), "mission interrupted", "transcript", path)
+	}
+	report("Session cost", costTable())
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// The Jira tools anchor a mission to the ticket it is about: jira_issue reads one with its comments, and with
+// --allow-writes jira_create_issue and jira_comment file findings back, each shown for approval first. JIRA_URL
+// is the site, and JIRA_TOKEN is an API token sent with JIRA_EMAIL on Jira Cloud, or a personal access token
+// on its own for Jira Server and Data Center.
+const jiraToolDef = `[
+		{"type":"function","function":{"name":"jira_issue","description":"Fetch a Jira issue: summary, type, status, priority, people, labels, description, and comments.","parameters":{"type":"object","properties":{
+			"key":{"type":"string","description":"Issue key such as PROJ-123"} },"required":["key"]}}}
+		]`
+
+const jiraWriteToolDef = `[
+		{"type":"function","function":{"name":"jira_create_issue","description":"Create a Jira issue. The user approves it before it is filed.","parameters":{"type":"object","properties":{
+			"project":{"type":"string","description":"Project key such as PROJ"},
+			"type":{"type":"string","default":"Task","description":"Issue type, such as Task or Bug"},
+			"summary":{"type":"string","description":"One-line title"},
+			"description":{"type":"string","description":"The body of the issue"} },"required":["project","summary","description"]}}},
+		{"type":"function","function":{"name":"jira_comment","description":"Add a comment to a Jira issue. The user approves it before it is posted.","parameters":{"type":"object","properties":{
+			"key":{"type":"string","description":"Issue key such as PROJ-123"},
+			"body":{"type":"string","description":"The comment"} },"required":["key","body"]}}}
+		]`
+
+type jiraParams struct {
+	Key         string `json:"key"`
+	Project     string `json:"project"`
+	Type        string `json:"type"`
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+	Body        string `json:"body"`
+}
+
+func jiraConfigured() bool {
+	return os.Getenv("JIRA_URL") != "" && os.Getenv("JIRA_TOKEN") != ""
+}
+
+var jiraTools = registerToolset(&toolset{
+	def:      jiraToolDef,
+	enabled:  jiraConfigured,
+	readOnly: true,
+	run: map[string]func(context.Context, string) (string, error){
+		"jira_issue": func(ctx context.Context, args string) (string, error) {
+			var params jiraParams
+			json.Unmarshal([]byte(args), &params)
+			return jiraIssue(ctx, params.Key)
+		},
+	},
+})
+
+var jiraWriteTools = registerToolset(&toolset{
+	def:     jiraWriteToolDef,
+	enabled: func() bool { return jiraConfigured() && *allowWrites },
+	writes:  true,
+	run: map[string]func(context.Context, string) (string, error){
+		"jira_create_issue": func(ctx context.Context, args string) (string, error) {
+			var params jiraParams
+			json.Unmarshal([]byte(args), &params)
+			return jiraCreateIssue(ctx, params)
+		},
+		"jira_comment": func(ctx context.Context, args string) (string, error) {
+			var params jiraParams
+			json.Unmarshal([]byte(args), &params)
+			return jiraComment(ctx, params.Key, params.Body)
+		},
+	},
+})
+
+func jiraAPI(path string) string {
+	return strings.TrimSuffix(os.Getenv("JIRA_URL"), "/") + "/rest/api/2" + path
+}
+
+func jiraHeader() http.Header {
+	h := http.Header{"Accept": {"application/json"}}
+	if email := os.Getenv("JIRA_EMAIL"); email != "" {
+		h.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(email+":"+os.Getenv("JIRA_TOKEN"))))
+	} else {
+		h.Set("Authorization", "Bearer "+os.Getenv("JIRA_TOKEN"))
+	}
+	return h
+}
+
+type jiraUser struct {
+	DisplayName string `json:"displayName"`
+}
+
+func (u *jiraUser) String() string {
+	if u == nil {
+		return "nobody"
+	}
+	return u.DisplayName
+}
+
+func jiraIssue(ctx context.Context, key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("Permanent Error: key is required, such as PROJ-123")
+	}
+	var issue struct {
+		Key    string `json:"key"`
+		Fields struct {
+			Summary     string    `json:"summary"`
+			Description string    `json:"description"`
+			Labels      []string  `json:"labels"`
+			Created     string    `json:"created"`
+			Updated     string    `json:"updated"`
+			Assignee    *jiraUser `json:"assignee"`
+			Reporter    *jiraUser `json:"reporter"`
+			Type        struct {
+				Name string `json:"name"`
+			} `json:"issuetype"`
+			Status struct {
+				Name string `json:"name"`
+			} `json:"status"`
+			Priority *struct {
+				Name string `json:"name"`
+			} `json:"priority"`
+			Comment struct {
+				Comments []struct {
+					Author  jiraUser `json:"author"`
+					Body    string   `json:"body"`
+					Created string   `json:"created"`
+				} `json:"comments"`
+			} `json:"comment"`
+		} `json:"fields"`
+	}
+	fields := "summary,description,labels,created,updated,assignee,reporter,issuetype,status,priority,comment"
+	if err := getJSON(ctx, jiraAPI("/issue/"+url.PathEscape(key)+"?fields="+fields), jiraHeader(), &issue); err != nil {
+		return "", fmt.Errorf("Error fetching %s: %v", key, err)
+	}
+
+	f := issue.Fields
+	var b strings.Builder
+	fmt.Fprintf(&b, "jira_issue %s results\n%s: %s\nStatus: %s", issue.Key, f.Type.Name, f.Summary, f.Status.Name)
+	if f.Priority != nil {
+		fmt.Fprintf(&b, ", priority %s", f.Priority.Name)
+	}
+	fmt.Fprintf(&b, "\nReported by %s on %s, assigned to %s, updated %s\n", f.Reporter, f.Created, f.Assignee, f.Updated)
+	if len(f.Labels) > 0 {
+		fmt.Fprintf(&b, "Labels: %s\n", strings.Join(f.Labels, ", "))
+	}
+	fmt.Fprintf(&b, "URL: %s/browse/%s\n\n%s\n", strings.TrimSuffix(os.Getenv("JIRA_URL"), "/"), issue.Key, strings.TrimSpace(f.Description))
+	for _, c := range f.Comment.Comments {
+		fmt.Fprintf(&b, "\n--- %s on %s:\n%s\n", c.Author.DisplayName, c.Created, strings.TrimSpace(c.Body))
+	}
+	return b.String(), nil
+}
+
+// approveJira shows what is about to be filed and asks for it like a file change; the reply comes back as the
+// tool result when it is anything but yes.
+func approveJira(ctx context.Context, question, detail string) (bool, string) {
+	event(slog.LevelInfo, fmt.Sprintf("\n\033[90m📝 %s\033[0m\n%s\n", question, detail), "proposed jira change", "detail", detail)
+	answer := approve(ctx, question, detail)
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true, ""
+	case "", "n", "no":
+		return false, "The user rejected this; nothing was sent to Jira."
+	default:
+		return false, "The user rejected this and said: " + answer
+	}
+}
+
+func jiraCreateIssue(ctx context.Context, p jiraParams) (string, error) {
+	if p.Project == "" || p.Summary == "" {
+		return "", fmt.Errorf("Permanent Error: project and summary are required")
+	}
+	if p.Type == "" {
+		p.Type = "Task"
+	}
+	detail := fmt.Sprintf("%s in %s: %s\n\n%s", p.Type, p.Project, p.Summary, p.Description)
+	if ok, refusal := approveJira(ctx, "File this Jira issue?", detail); !ok {
+		return refusal, nil
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	body := map[string]any{"fields": map[string]any{
+		"project":     map[string]string{"key": p.Project},
+		"issuetype":   map[string]string{"name": p.Type},
+		"summary":     p.Summary,
+		"description": p.Description,
+	}}
+	if err := sendJSON(ctx, "POST", jiraAPI("/issue"), jiraHeader(), body, &created); err != nil {
+		return "", fmt.Errorf("Error creating the issue: %v", err)
+	}
+	return fmt.Sprintf("Created %s: %s/browse/%s", created.Key, strings.TrimSuffix(os.Getenv("JIRA_URL"), "/"), created.Key), nil
+}
+
+func jiraComment(ctx context.Context, key, comment string) (string, error) {
+	if key == "" || strings.TrimSpace(comment) == "" {
+		return "", fmt.Errorf("Permanent Error: key and body are required")
+	}
+	if ok, refusal := approveJira(ctx, fmt.Sprintf("Comment on %s?", key), comment); !ok {
+		return refusal, nil
+	}
+	if err := sendJSON(ctx, "POST", jiraAPI("/issue/"+url.PathEscape(key)+"/comment"), jiraHeader(), map[string]string{"body": comment}, nil); err != nil {
+		return "", fmt.Errorf("Error commenting on %s: %v", key, err)
+	}
+	return fmt.Sprintf("Commented on %s", key), nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// The kube tools let an ops mission ("why is the api deployment crashlooping?") look at the cluster: list
+// resources, describe one with its events, and read a container's logs. They run kubectl with the user's
+// kubeconfig, limited to get, describe, and logs, so nothing in the cluster changes. --kube-context and
+// --kube-namespace pin where they look; the model can't point them anywhere else.
+var (
+	kubeContext   = flag.String("kube-context", "", "kubeconfig context for the Kubernetes tools (default: the current context)")
+	kubeNamespace = flag.String("kube-namespace", "", "Namespace for the Kubernetes tools (default: the context's namespace)")
+)
+
+const kubeToolDef = `[
+		{"type":"function","function":{"name":"kube_get","description":"List Kubernetes resources of a kind, with status, age, and node. Use kind events to see recent warnings.","parameters":{"type":"object","properties":{
+			"kind":{"type":"string","description":"Resource kind, such as pods, deployments, services, events, or nodes"},
+			"name":{"type":"string","description":"Optional name of one resource"},
+			"selector":{"type":"string","description":"Optional label selector, such as app=api"} },"required":["kind"]}}},
+		{"type":"function","function":{"name":"kube_describe","description":"Describe one Kubernetes resource in detail: spec, status, conditions, and its recent events.","parameters":{"type":"object","properties":{
+			"kind":{"type":"string","description":"Resource kind, such as pod or deployment"},
+			"name":{"type":"string","description":"Resource name"} },"required":["kind","name"]}}},
+		{"type":"function","function":{"name":"kube_logs","description":"Read the last lines of a pod's logs, or of the previous container after a crash.","parameters":{"type":"object","properties":{
+			"pod":{"type":"string","description":"Pod name, or kind/name such as deployment/api"},
+			"container":{"type":"string","description":"Container name, needed when the pod has several"},
+			"previous":{"type":"boolean","default":false,"description":"Read the logs of the container's previous run, the one that crashed"},
+			"tail":{"type":"integer","default":200,"description":"How many lines from the end, at most 1000"} },"required":["pod"]}}}
+		]`
+
+// maxKubeOutput bounds one result; a describe of a busy node or a chatty log runs far past what helps.
+const (
+	maxKubeOutput = 32 << 10
+	maxKubeTail   = 1000
+)
+
+// kubeName accepts resource kinds, names, selectors, and containers; anything else, such as a value starting
+// with a dash, would be read by kubectl as a flag.
+var kubeName = regexp.MustCompile(`^[A-Za-z0-9][\w.,=!/:-]*$`)
+
+// kubeReady reports whether kubectl is installed and has a context to use, without contacting the cluster.
+var kubeReady = sync.OnceValue(func() bool {
+	if *kubeContext != "" {
+		_, err := exec.LookPath("kubectl")
+		return err == nil
+	}
+	return exec.Command("kubectl", "config", "current-context").Run() == nil
+})
+
+var kubeTools = registerToolset(&toolset{
+	def:      kubeToolDef,
+	enabled:  kubeReady,
+	readOnly: true,
+	run: map[string]func(context.Context, string) (string, error){
+		"kube_get": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Kind     string `json:"kind"`
+				Name     string `json:"name"`
+				Selector string `json:"selector"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			if err := checkKubeNames(params.Kind, params.Name, params.Selector); err != nil {
+				return "", err
+			}
+			argv := []string{"get", params.Kind}
+			if params.Name != "" {
+				argv = append(argv, params.Name)
+			}
+			if params.Selector != "" {
+				argv = append(argv, "--selector", params.Selector)
+			}
+			if params.Kind == "events" || params.Kind == "event" || params.Kind == "ev" {
+				argv = append(argv, "--sort-by", ".lastTimestamp")
+			}
+			return kubectl(ctx, false, append(argv, "--output", "wide")...)
+		},
+		"kube_describe": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Kind string `json:"kind"`
+				Name string `json:"name"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			if err := checkKubeNames(params.Kind, params.Name); err != nil {
+				return "", err
+			}
+			return kubectl(ctx, false, "describe", params.Kind, params.Name)
+		},
+		"kube_logs": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Pod       string `json:"pod"`
+				Container string `json:"container"`
+				Previous  bool   `json:"previous"`
+				Tail      int    `json:"tail"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			if err := checkKubeNames(params.Pod, params.Container); err != nil {
+				return "", err
+			}
+			argv := []string{"logs", params.Pod, fmt.Sprintf("--tail=%d", min(max(params.Tail, 1), maxKubeTail)), "--timestamps"}
+			if params.Container != "" {
+				argv = append(argv, "--container", params.Container)
+			}
+			if params.Previous {
+				argv = append(argv, "--previous")
+			}
+			return kubectl(ctx, true, argv...)
+		},
+	},
+})
+
+func checkKubeNames(values ...string) error {
+	for _, v := range values {
+		if v != "" && !kubeName.MatchString(v) {
+			return fmt.Errorf("Permanent Error: %q is not a valid Kubernetes name", v)
+		}
+	}
+	return nil
+}
+
+// kubectl runs a read-only kubectl command in the configured context and namespace. A long result keeps its
+// start, or its end for logs, where the newest lines are.
+func kubectl(ctx context.Context, keepEnd bool, args ...string) (string, error) {
+	argv := []string{args[0]}
+	if *kubeContext != "" {
+		argv = append(argv, "--context", *kubeContext)
+	}
+	if *kubeNamespace != "" {
+		argv = append(argv, "--namespace", *kubeNamespace)
+	}
+	argv = append(argv, args[1:]...)
+	cmd := exec.CommandContext(ctx, "kubectl", argv...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("kubectl %s failed: %s", args[0], clip(strings.TrimSpace(stderr.String())))
+	}
+	return fmt.Sprintf("kubectl %s results\n%s", strings.Join(args, " "), clipOutput(string(out), maxKubeOutput, keepEnd)), nil
+}
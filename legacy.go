@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"regexp"
+	"sync/atomic"
+)
+
+// Older OpenAI-compatible servers know only the deprecated functions and function_call fields, from before
+// tools. In that mode the tool schema is sent as functions, calls come back one at a time as function_call, and
+// results go back as function messages. The history keeps its usual form and is translated per request. The mode
+// is switched on by --legacy-functions, or for the rest of the session when a server rejects the tools field.
+var legacyFunctionsFlag = flag.Bool("legacy-functions", false, "Call tools through the deprecated functions/function_call fields, for older servers (switched to automatically when tools are rejected)")
+
+var legacyFunctions atomic.Bool
+
+// toolsRejected recognizes a server refusing the tools field, rather than any other bad request.
+var toolsRejected = regexp.MustCompile(`(?i)(unrecognized|unsupported|not supported|unknown|extra|invalid)[^.]*\btools\b|\btools\b[^.]*(unrecognized|unsupported|not supported|not permitted|unknown)`)
+
+// legacyCallCount numbers the calls made through function_call, which come without an ID of their own.
+var legacyCallCount atomic.Int64
+
+type functionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// legacyFunctionDefs unwraps the tool schema, [{"type":"function","function":{...}}], into bare functions.
+func legacyFunctionDefs(tools []byte) []json.RawMessage {
+	var defs []struct {
+		Function json.RawMessage `json:"function"`
+	}
+	json.Unmarshal(tools, &defs)
+	functions := make([]json.RawMessage, len(defs))
+	for i, d := range defs {
+		functions[i] = d.Function
+	}
+	return functions
+}
+
+// legacyMessages translates a conversation for a functions-only server. A message with several tool calls
+// becomes one function_call message per call, each followed by its result, since that is all the format allows.
+func legacyMessages(messages []ChatMessage) []any {
+	results := map[string]ChatMessage{}
+	for _, m := range messages {
+		if m.Role == "tool" {
+			results[m.ToolCallID] = m
+		}
+	}
+	var out []any
+	for _, m := range messages {
+		switch {
+		case m.Role == "tool":
+			// Sent right after its call, below.
+		case m.Role == "assistant" && len(m.ToolCalls) > 0:
+			for i, tc := range m.ToolCalls {
+				call := map[string]any{"role": "assistant", "content": nil, "function_call": functionCall{tc.Function.Name, tc.Function.Arguments}}
+				if i == 0 && m.Content != "" {
+					call["content"] = m.Content
+				}
+				out = append(out, call)
+				if res, ok := results[tc.ID]; ok {
+					out = append(out, map[string]any{"role": "function", "name": tc.Function.Name, "content": res.Content})
+				}
+			}
+		default:
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// adoptFunctionCall turns a reply's function_call into the tool call the rest of the agent expects.
+func adoptFunctionCall(msg *ChatMessage, call *functionCall) {
+	if call == nil || call.Name == "" || len(msg.ToolCalls) > 0 {
+		return
+	}
+	tc := ToolCall{ID: fmt.Sprintf("call_legacy_%d", legacyCallCount.Add(1)), Type: "function"}
+	tc.Function.Name, tc.Function.Arguments = call.Name, call.Arguments
+	msg.ToolCalls = []ToolCall{tc}
+}
+
+// setPayload puts the conversation and tool schema into a chat request, in whichever form the server and
+// model take: native tools, legacy functions, or ReAct text.
+func setPayload(req map[string]any, messages []ChatMessage, tools []byte) {
+	if *reactMode && len(tools) > 0 {
+		delete(req, "tools")
+		req["messages"] = reactMessages(messages, tools)
+		req["stop"] = []string{"\nObservation"}
+		return
+	}
+	if legacyFunctions.Load() && len(tools) > 0 {
+		delete(req, "tools")
+		req["functions"] = legacyFunctionDefs(tools)
+		req["messages"] = legacyMessages(messages)
+		return
+	}
+	req["messages"] = messages
+	req["tools"] = json.RawMessage(tools)
+}
@@ -0,0 +1,509 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The log tools look into log files far too big to page through with study_file_contents, reading them as a
+// stream so a file of hundreds of megabytes costs time but not memory: log_tail shows the newest lines, log_grep
+// the lines matching a pattern with some context, and log_stats condenses a whole file, or the lines matching a
+// pattern, into counts per level and per time bucket with the most frequent error and warning messages.
+// Rotated logs ending in .gz are read decompressed.
+const logToolDef = `[
+		{"type":"function","function":{"name":"log_tail","description":"Show the last lines of a log file, however large.","parameters":{"type":"object","properties":{
+			"path":{"type":"string","description":"Log file relative to current working directory"},
+			"lines":{"type":"integer","default":100,"description":"How many lines from the end, at most 1000"} },"required":["path"]}}},
+		{"type":"function","function":{"name":"log_grep","description":"Search a large log file for lines matching a regular expression, with line numbers and optional context.","parameters":{"type":"object","properties":{
+			"path":{"type":"string","description":"Log file relative to current working directory"},
+			"pattern":{"type":"string","description":"RE2 regular expression, such as (?i)timeout|refused"},
+			"context":{"type":"integer","default":0,"description":"Lines shown before and after each match, at most 5"},
+			"limit":{"type":"integer","default":50,"description":"Most matches shown, at most 200; all are counted"},
+			"newest":{"type":"boolean","default":false,"description":"Show the last matches instead of the first"} },"required":["path","pattern"]}}},
+		{"type":"function","function":{"name":"log_stats","description":"Summarize a large log file: line counts by level, a histogram over time, and the most frequent error and warning messages.","parameters":{"type":"object","properties":{
+			"path":{"type":"string","description":"Log file relative to current working directory"},
+			"pattern":{"type":"string","description":"Optional RE2 regular expression; only matching lines are counted"},
+			"bucket":{"type":"string","description":"Optional histogram bucket, as a duration like 5m or 1h, defaults to one that gives a few dozen rows"} },"required":["path"]}}}
+		]`
+
+const (
+	maxLogTail     = 1000
+	maxLogMatches  = 200
+	maxLogContext  = 5
+	maxLogLine     = 400     // bytes of one line shown; the rest of a long line is cut
+	maxLogTailRead = 8 << 20 // bytes read back from the end for log_tail, however long its lines
+	maxLogOutput   = 32 << 10
+	maxLogBuckets  = 200
+	maxLogShapes   = 10000 // distinct messages tracked for the most frequent, so a noisy file can't grow the map
+	logTopShapes   = 10
+	logScanWindow  = 120 // the leading bytes of a line searched for its timestamp and level
+)
+
+var logLevels = []string{"error", "warn", "info", "debug", "other"}
+
+var (
+	logNumbers = regexp.MustCompile(`0x[0-9a-fA-F]+|[0-9a-fA-F]{8}-[0-9a-fA-F-]{27}|\d+`)
+)
+
+// logStamps are the timestamp formats recognized, each a pattern and the layout of its first group; the fraction
+// and zone around it only matter for telling messages apart.
+var logStamps = []struct {
+	re     *regexp.Regexp
+	layout string
+}{
+	{regexp.MustCompile(`(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2})[.,]?\d*(?:Z|[+-]\d{2}:?\d{2})?`), "2006-01-02T15:04:05"},
+	{regexp.MustCompile(`(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})[.,]?\d*(?: ?(?:Z|UTC|[+-]\d{2}:?\d{2}))?`), "2006-01-02 15:04:05"},
+	{regexp.MustCompile(`(\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2})(?: [+-]\d{4})?`), "02/Jan/2006:15:04:05"},
+	{regexp.MustCompile(`([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2})`), "Jan _2 15:04:05"},
+}
+
+var logTools = registerToolset(&toolset{
+	def:      logToolDef,
+	enabled:  func() bool { return true },
+	readOnly: true,
+	run: map[string]func(context.Context, string) (string, error){
+		"log_tail": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Path  string `json:"path"`
+				Lines int    `json:"lines"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			if params.Lines == 0 {
+				params.Lines = 100
+			}
+			return logTail(ctx, params.Path, min(max(params.Lines, 1), maxLogTail))
+		},
+		"log_grep": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Path    string `json:"path"`
+				Pattern string `json:"pattern"`
+				Context int    `json:"context"`
+				Limit   int    `json:"limit"`
+				Newest  bool   `json:"newest"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			if params.Limit == 0 {
+				params.Limit = 50
+			}
+			return logGrep(ctx, params.Path, params.Pattern, min(max(params.Context, 0), maxLogContext), min(max(params.Limit, 1), maxLogMatches), params.Newest)
+		},
+		"log_stats": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Path    string `json:"path"`
+				Pattern string `json:"pattern"`
+				Bucket  string `json:"bucket"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			return logStats(ctx, params.Path, params.Pattern, params.Bucket)
+		},
+	},
+})
+
+// openLog opens a log file from the workspace, decompressing it when it is gzipped.
+func openLog(path string) (io.Reader, func() error, error) {
+	if !filepath.IsLocal(path) {
+		return nil, nil, fmt.Errorf("Permanent Error: Path %s is outside of current working directory", path)
+	}
+	file, err := workspace.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error opening file: %v", err)
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return file, file.Close, nil
+	}
+	unzipped, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("Error reading %s: %v", toolPath(path), err)
+	}
+	return unzipped, file.Close, nil
+}
+
+// scanLog calls each with every line of a log file and its number.
+func scanLog(ctx context.Context, path string, each func(n int, line []byte) bool) error {
+	file, closeFile, err := openLog(path)
+	if err != nil {
+		return err
+	}
+	defer closeFile()
+	return scanLines(ctx, path, file, each)
+}
+
+// scanLines calls each with every line read and its number, stopping early when each returns false or ctx is
+// done. The line is only valid during the call, and a line longer than the read buffer is cut.
+func scanLines(ctx context.Context, path string, file io.Reader, each func(n int, line []byte) bool) error {
+	r := bufio.NewReaderSize(file, 64<<10)
+	for n := 1; ; n++ {
+		if n%100000 == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line, more, err := r.ReadLine()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("Error reading %s: %v", toolPath(path), err)
+		}
+		if !each(n, line) {
+			return nil
+		}
+		for more && err == nil {
+			_, more, err = r.ReadLine()
+		}
+	}
+}
+
+func logLine(line []byte) string {
+	if len(line) > maxLogLine {
+		return strings.ToValidUTF8(string(line[:maxLogLine]), "") + " […]"
+	}
+	return strings.ToValidUTF8(string(line), "")
+}
+
+func logTail(ctx context.Context, path string, count int) (string, error) {
+	file, closeFile, err := openLog(path)
+	if err != nil {
+		return "", err
+	}
+	defer closeFile()
+
+	// A plain file is read back from its end, so the tail of a huge log costs no more than that of a small one.
+	// A compressed one can only be read through, keeping the last lines as it goes.
+	var lines []string
+	if f, ok := file.(interface {
+		io.ReaderAt
+		Stat() (fs.FileInfo, error)
+	}); ok {
+		info, err := f.Stat()
+		if err != nil {
+			return "", fmt.Errorf("Error reading file: %v", err)
+		}
+		if lines, err = tailLines(f, info.Size(), count); err != nil {
+			return "", fmt.Errorf("Error reading %s: %v", toolPath(path), err)
+		}
+	} else {
+		err := scanLines(ctx, path, file, func(n int, line []byte) bool {
+			lines = append(lines, logLine(line))
+			if len(lines) > count {
+				lines = lines[1:]
+			}
+			return true
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("log_tail %s results (last %d lines)\n%s", toolPath(path), len(lines), clipOutput(strings.Join(lines, "\n")+"\n", maxLogOutput, true)), nil
+}
+
+// tailLines reads back from the end of a file in chunks until it holds count whole lines, or the start, or
+// maxLogTailRead bytes.
+func tailLines(r io.ReaderAt, size int64, count int) ([]string, error) {
+	const chunk = 64 << 10
+	var tail []byte
+	for offset := size; offset > 0 && int64(len(tail)) < maxLogTailRead; {
+		n := min(offset, chunk)
+		offset -= n
+		buf := make([]byte, n)
+		if _, err := r.ReadAt(buf, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		tail = append(buf, tail...)
+		if bytes.Count(bytes.TrimSuffix(tail, []byte("\n")), []byte("\n")) >= count {
+			break
+		}
+	}
+	all := strings.Split(strings.TrimSuffix(string(tail), "\n"), "\n")
+	if len(all) == 1 && all[0] == "" {
+		return nil, nil
+	}
+	all = all[max(len(all)-count, 0):]
+	lines := make([]string, len(all))
+	for i, line := range all {
+		lines[i] = logLine([]byte(strings.TrimSuffix(line, "\r")))
+	}
+	return lines, nil
+}
+
+func logGrep(ctx context.Context, path, pattern string, context, limit int, newest bool) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil || pattern == "" {
+		return "", fmt.Errorf("Permanent Error: pattern %q is not a regular expression: %v", pattern, err)
+	}
+	// Matches near each other share one block, as with grep -C; before holds the lines that may lead the next.
+	type block struct {
+		lines   []string
+		matches int
+	}
+	var blocks []block
+	var before []string
+	total, kept, after := 0, 0, 0
+	err = scanLog(ctx, path, func(n int, line []byte) bool {
+		text := logLine(line)
+		switch {
+		case re.Match(line):
+			total++
+			if !newest && kept == limit {
+				break // only counted from here on
+			}
+			if after == 0 || len(blocks) == 0 || blocks[len(blocks)-1].matches == limit {
+				blocks = append(blocks, block{lines: append([]string{}, before...)})
+			}
+			b := &blocks[len(blocks)-1]
+			b.lines = append(b.lines, fmt.Sprintf("%d: %s", n, text))
+			b.matches++
+			kept++
+			for newest && kept > limit {
+				kept -= blocks[0].matches
+				blocks = blocks[1:]
+			}
+			after = context
+			before = before[:0]
+			return true
+		case after > 0:
+			blocks[len(blocks)-1].lines = append(blocks[len(blocks)-1].lines, fmt.Sprintf("%d- %s", n, text))
+			after--
+			return true
+		}
+		if context > 0 {
+			before = append(before, fmt.Sprintf("%d- %s", n, text))
+			if len(before) > context {
+				before = before[1:]
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+	which := "first"
+	if newest {
+		which = "last"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "log_grep %s %q results (%d matching lines", toolPath(path), pattern, total)
+	if kept < total {
+		fmt.Fprintf(&b, ", showing the %s %d", which, kept)
+	}
+	b.WriteString(")\n")
+	for i, blk := range blocks {
+		if i > 0 && context > 0 {
+			b.WriteString("--\n")
+		}
+		b.WriteString(strings.Join(blk.lines, "\n") + "\n")
+	}
+	return clipOutput(b.String(), maxLogOutput, newest), nil
+}
+
+// logClock finds the timestamp near the start of each line. Lines of one log put it in the same place, so the
+// format and offset last found are tried first, which saves running the patterns on millions of lines.
+type logClock struct {
+	format, offset int
+	found          bool
+}
+
+func (c *logClock) stamp(head []byte) (time.Time, bool) {
+	if c.found {
+		layout := logStamps[c.format].layout
+		if end := c.offset + len(layout); end <= len(head) {
+			if t, err := time.Parse(layout, string(head[c.offset:end])); err == nil {
+				return withYear(t), true
+			}
+		}
+	}
+	for i, s := range logStamps {
+		m := s.re.FindSubmatchIndex(head)
+		if m == nil {
+			continue
+		}
+		t, err := time.Parse(s.layout, string(head[m[2]:m[3]]))
+		if err != nil {
+			continue
+		}
+		c.format, c.offset, c.found = i, m[2], true
+		return withYear(t), true
+	}
+	return time.Time{}, false
+}
+
+// withYear dates a syslog timestamp, which carries no year, in the current one.
+func withYear(t time.Time) time.Time {
+	if t.Year() == 0 {
+		return t.AddDate(time.Now().Year(), 0, 0)
+	}
+	return t
+}
+
+// levelIndex places a line in logLevels by the first level word near its start. It splits words by hand; a
+// case-insensitive pattern is several times slower, which shows over a large file.
+func levelIndex(head []byte) int {
+	for _, word := range bytes.FieldsFunc(head, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		if len(word) > 8 {
+			continue
+		}
+		switch strings.ToLower(string(word)) {
+		case "error", "err", "fatal", "crit", "critical", "panic", "emerg":
+			return 0
+		case "warn", "warning":
+			return 1
+		case "info", "notice":
+			return 2
+		case "debug", "trace":
+			return 3
+		}
+	}
+	return 4
+}
+
+// logShape reduces a message to its constant text, without the timestamp and with every number, hex value, and
+// UUID replaced by #, so repeats of one error count together.
+func logShape(line []byte) string {
+	text := logLine(line)
+	for _, s := range logStamps {
+		if loc := s.re.FindStringIndex(text); loc != nil && loc[0] < logScanWindow {
+			text = text[:loc[0]] + text[loc[1]:]
+			break
+		}
+	}
+	return strings.Join(strings.Fields(logNumbers.ReplaceAllString(text, "#")), " ")
+}
+
+func logStats(ctx context.Context, path, pattern, bucket string) (string, error) {
+	var re *regexp.Regexp
+	if pattern != "" {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			return "", fmt.Errorf("Permanent Error: pattern %q is not a regular expression: %v", pattern, err)
+		}
+	}
+	var width time.Duration
+	if bucket != "" {
+		var err error
+		if width, err = time.ParseDuration(bucket); err != nil || width < time.Minute {
+			return "", fmt.Errorf("Permanent Error: bucket %q is not a duration of a minute or more, like 5m or 1h", bucket)
+		}
+	}
+
+	// Counts are kept per minute and merged into buckets at the end, once the file's time span is known.
+	var levels [5]int
+	minutes := map[int64]*[5]int{}
+	shapes := map[string]int{}
+	lines, stamped := 0, 0
+	var first, last time.Time
+	var clock logClock
+	err := scanLog(ctx, path, func(n int, line []byte) bool {
+		if re != nil && !re.Match(line) {
+			return true
+		}
+		lines++
+		head := line[:min(len(line), logScanWindow)]
+		level := levelIndex(head)
+		levels[level]++
+		if t, ok := clock.stamp(head); ok {
+			stamped++
+			if first.IsZero() || t.Before(first) {
+				first = t
+			}
+			if t.After(last) {
+				last = t
+			}
+			minute := t.Unix() / 60
+			if minutes[minute] == nil {
+				minutes[minute] = &[5]int{}
+			}
+			minutes[minute][level]++
+		}
+		if level <= 1 {
+			shape := logShape(line)
+			if _, ok := shapes[shape]; ok || len(shapes) < maxLogShapes {
+				shapes[shape]++
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "log_stats %s results\n", toolPath(path))
+	if re != nil {
+		fmt.Fprintf(&b, "Lines matching %q: %d", pattern, lines)
+	} else {
+		fmt.Fprintf(&b, "Lines: %d", lines)
+	}
+	fmt.Fprintf(&b, ", %d with a timestamp", stamped)
+	if stamped > 0 {
+		fmt.Fprintf(&b, ", from %s to %s", first.Format(time.DateTime), last.Format(time.DateTime))
+	}
+	b.WriteString("\nBy level:")
+	for i, name := range logLevels {
+		fmt.Fprintf(&b, " %s %d", name, levels[i])
+	}
+	b.WriteString("\n")
+
+	if stamped > 0 {
+		if width == 0 {
+			width = 7 * 24 * time.Hour
+			for _, w := range []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour, 6 * time.Hour, 24 * time.Hour} {
+				if last.Sub(first)/w < 48 {
+					width = w
+					break
+				}
+			}
+		}
+		buckets := map[int64]*[5]int{}
+		for minute, counts := range minutes {
+			start := time.Unix(minute*60, 0).UTC().Truncate(width).Unix()
+			if buckets[start] == nil {
+				buckets[start] = &[5]int{}
+			}
+			for i, c := range counts {
+				buckets[start][i] += c
+			}
+		}
+		starts := make([]int64, 0, len(buckets))
+		for start := range buckets {
+			starts = append(starts, start)
+		}
+		sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+		fmt.Fprintf(&b, "\nPer %s (start, total, error, warn, info, debug, other):\n", width)
+		for i, start := range starts {
+			if i == maxLogBuckets {
+				fmt.Fprintf(&b, "(%d more buckets, pass a wider bucket)\n", len(starts)-i)
+				break
+			}
+			c := buckets[start]
+			fmt.Fprintf(&b, "%s %d %d %d %d %d %d\n", time.Unix(start, 0).UTC().Format("2006-01-02 15:04"), c[0]+c[1]+c[2]+c[3]+c[4], c[0], c[1], c[2], c[3], c[4])
+		}
+	}
+
+	if len(shapes) > 0 {
+		top := make([]string, 0, len(shapes))
+		for shape := range shapes {
+			top = append(top, shape)
+		}
+		sort.Slice(top, func(i, j int) bool {
+			if shapes[top[i]] != shapes[top[j]] {
+				return shapes[top[i]] > shapes[top[j]]
+			}
+			return top[i] < top[j]
+		})
+		b.WriteString("\nMost frequent error and warning messages (numbers shown as #):\n")
+		for _, shape := range top[:min(len(top), logTopShapes)] {
+			fmt.Fprintf(&b, "%d× %s\n", shapes[shape], shape)
+		}
+	}
+	return clipOutput(b.String(), maxLogOutput, false), nil
+}
@@ -1,121 +1,362 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
-	"unicode/utf8"
 )
 
 // Template URL/model logic handles 4 cases depending on environment variables and platform.
 // This simplifies switching between local and cloud models without manual reconfiguration.
-var template = map[[2]bool][2]string{
-	{false, true}:  {"http://localhost:1234/v1/chat/completions", "lmstudio-community/Qwen3-4B-MLX-8bit"},
-	{false, false}: {"http://localhost:1234/v1/chat/completions", "qwen/qwen3-4b"},
-	{true, false}:  {"https://api.openai.com/v1/chat/completions", "gpt-4.1-mini"},
-	{true, true}:   {"https://api.openai.com/v1/chat/completions", "gpt-4.1-mini"},
-}[[2]bool{os.Getenv("OPENAI_API_KEY") != "", runtime.GOOS == "darwin"}]
+var template = func() [2]string {
+	t := map[[2]bool][2]string{
+		{false, true}:  {"http://localhost:1234/v1/chat/completions", "lmstudio-community/Qwen3-4B-MLX-8bit"},
+		{false, false}: {"http://localhost:1234/v1/chat/completions", "qwen/qwen3-4b"},
+		{true, false}:  {"https://api.openai.com/v1/chat/completions", "gpt-4.1-mini"},
+		{true, true}:   {"https://api.openai.com/v1/chat/completions", "gpt-4.1-mini"},
+	}[[2]bool{os.Getenv("OPENAI_API_KEY") != "", runtime.GOOS == "darwin"}]
+	// Windows resolves localhost to ::1 first while LM Studio listens on IPv4 only.
+	if runtime.GOOS == "windows" {
+		t[0] = strings.Replace(t[0], "//localhost:", "//127.0.0.1:", 1)
+	}
+	return t
+}()
 
 var (
 	// 'mission' encapsulates user intent and is reused across turns if not explicitly cleared.
 	// This supports multi-step planning without forcing repeated input.
-	mission = flag.String("mission", "", "Mission to complete")
+	mission     = flag.String("mission", "", "Mission to complete")
+	missionFile = flag.String("mission-file", "", "Read the mission from a Markdown or text file, for specs and bug reports written in an editor")
 
 	apiURL = flag.String("url", template[0], "API URL")
 	model  = flag.String("model", template[1], "Model to use (e.g., gpt-4.1-mini)")
+
+	toolWorkers   = flag.Int("tool-workers", 4, "Maximum read-only tool calls to run concurrently")
+	verbose       = flag.Bool("verbose", false, "Print the raw JSON of every API request and response")
+	gzipRequests  = flag.Bool("gzip-requests", false, "Gzip request bodies (falls back automatically if the provider rejects them)")
+	confirmTokens = flag.Int("confirm-tokens", 8000, "Ask before sending a tool result or file page above this many estimated tokens (0 disables)")
 )
 
 func main() {
+	applyDefaults()
+	if len(os.Args) > 1 && os.Args[1] == "completion" {
+		os.Exit(runCompletion(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "recipes" {
+		os.Exit(listRecipes())
+	}
+	if len(os.Args) > 1 && os.Args[1] == "commit-msg" {
+		os.Exit(runCommitMsg(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "gen-tests" {
+		os.Exit(runGenTests(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "eval" {
+		os.Exit(runEval(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfig(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "review" && isStructuredReview(os.Args[2:]) {
+		os.Exit(runReview(os.Args[2:]))
+	}
 	flag.Parse()
-
-	// Initial LLM warm-up query ensures that the model is online and responsive before continuing,
-	// avoiding long feedback loops later in the interactive loop.
-	fmt.Printf("\033[37m=== Warming up \033[35m%s\033[37m... ", *model)
-	res, _, err := sendChatRequest(*model, []ChatMessage{{Role: "user", Content: "Be concise, are you ready to work?"}}, nil)
-	if err != nil {
-		fmt.Printf("\033[31mError: %v\n", err)
-		os.Exit(1)
+	if *exportFinetune != "" {
+		os.Exit(runExportFinetune(flag.Args()))
+	}
+	// Flags may come before the recipe name as well as after it, so the arguments left over are parsed again.
+	if flag.NArg() > 0 {
+		rest, err := runRecipe(flag.Args())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		flag.CommandLine.Parse(rest)
+	}
+	if *showVersion {
+		fmt.Println(versionString())
+		return
+	}
+	if *dryRun {
+		*allowWrites = true
+	}
+	if err := setupUI(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := connectRemote(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
-	fmt.Printf("\033[90mLLM says: \033[34m%s\033[0m\n", strings.TrimSpace(res.Content))
+	if err := useRoots(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	serveMetrics()
+	gzipAccepted.Store(*gzipRequests)
+	legacyFunctions.Store(*legacyFunctionsFlag)
+
+	ctx := context.Background()
+	awaitWarmUp = warmUp(ctx)
 
-	scanner := bufio.NewScanner(os.Stdin)
+	tools := activeTools()
 	messages := []ChatMessage{{Role: "system", Content: agentPrompt}}
+	if *missionFile != "" {
+		raw, err := os.ReadFile(*missionFile)
+		if err != nil {
+			event(slog.LevelError, fmt.Sprintf("\033[31mError: %v\n", err), "mission file unreadable", "err", err)
+			os.Exit(2)
+		}
+		*mission = strings.TrimSpace(string(raw))
+	}
+	if _, err := toolLimits(); err != nil {
+		event(slog.LevelError, fmt.Sprintf("\033[31mError: %v\n", err), "invalid tool limits", "err", err)
+		os.Exit(2)
+	}
+	if err := checkBestOfTurns(); err != nil {
+		event(slog.LevelError, fmt.Sprintf("\033[31mError: %v\n", err), "invalid best-of turns", "err", err)
+		os.Exit(2)
+	}
+	if _, err := policy(); err != nil {
+		event(slog.LevelError, fmt.Sprintf("\033[31mError: %v\n", err), "invalid policy", "err", err)
+		os.Exit(2)
+	}
+	if err := loadAttachments(); err != nil {
+		event(slog.LevelError, fmt.Sprintf("\033[31mError: %v\n", err), "attachment unreadable", "err", err)
+		os.Exit(2)
+	}
+	if *useEditor && *mission == "" {
+		edited, err := editMission("")
+		if err != nil {
+			event(slog.LevelError, fmt.Sprintf("\033[31mError: %v\n", err), "editor failed", "err", err)
+			os.Exit(2)
+		}
+		*mission = edited
+	}
+	// The bots and the schedule take over the session, running missions that come from elsewhere.
+	if serve := map[bool]func(context.Context, string) error{*slackMode: runSlack, *discordMode: runDiscord, *telegramMode: runTelegram, *every > 0: runSchedule, *ciMode: runCI, *serveAddr != "": runServe}[true]; serve != nil {
+		if err := serve(ctx, tools); err != nil {
+			event(slog.LevelError, fmt.Sprintf("\033[31mError: %v\n", err), "session failed", "err", err)
+			os.Exit(2)
+		}
+		return
+	}
+	// A mission given up front skips the prompt, so its user message is added here.
+	if *mission != "" {
+		recordMission(*mission)
+		beginMission()
+		messages = append(messages, missionMessage(*mission))
+	}
+	interrupts := trapInterrupts(func() {
+		if len(messages) > 1 {
+			saveTranscript(messages, "")
+		}
+		exportReport(messages)
+		if !*quiet {
+			report("Session cost", costTable())
+		}
+	})
 
 	for {
 		if *mission == "" {
-			fmt.Printf("\033[34mEnter new mission\033[90m (blank to exit) > \033[0m")
-			if !scanner.Scan() || strings.TrimSpace(scanner.Text()) == "" {
+			if interrupts.quitting() {
+				interrupts.exit()
+			}
+			interrupts.disarm()
+			watchInput(false)
+			input, ok := "", true
+			if *voice {
+				input = dictate()
+			}
+			if input == "" {
+				input, ok = askMultiline("\033[34mEnter new mission\033[90m (blank to exit, /help for commands) > \033[0m", missionHistory())
+			}
+			if !ok || strings.TrimSpace(input) == "" {
 				break
 			}
-			*mission = scanner.Text()
-			messages = append(messages, ChatMessage{Role: "user", Content: fmt.Sprintf(userPromptFormat, *mission)})
+			if recalled, ok, err := recallMission(input); err != nil {
+				event(slog.LevelError, fmt.Sprintf("\033[31mError: %v\033[0m\n", err), "recall failed", "err", err)
+				continue
+			} else if ok {
+				event(slog.LevelInfo, fmt.Sprintf("\033[90m↻ %s\033[0m\n", recalled), "mission recalled", "mission", recalled)
+				input = recalled
+			}
+			if !runSlashCommand(&messages, input) {
+				*mission = input
+			} else if *mission == "" {
+				continue // commands that start a mission, like /edit, set it themselves
+			}
+			recordMission(*mission)
+			beginMission()
+			messages = append(messages, missionMessage(*mission))
 		}
 
-		fmt.Printf("\033[34m🤔 Planning... \033[0m")
-		msg, _, err := sendChatRequest(*model, messages, []byte(toolDef))
-		if err != nil {
-			fmt.Printf("\033[31mError: %v\n", err)
+		watchInput(true)
+		missionCtx := interrupts.begin(ctx)
+		answer, err := runMission(missionCtx, *mission, &messages, tools)
+		switch {
+		case missionCtx.Err() != nil:
+			if !interrupts.quitting() {
+				interrupted(messages) // otherwise the session exits next, saving it then
+			}
+		case errors.Is(err, errEmptyReply) || errors.Is(err, errRepeatedCalls) || errors.Is(err, errTurnBudget) || errors.Is(err, errCostDeclined):
+			// already reported; the session goes on with the next mission
+		case err != nil:
 			return
+		default:
+			result(answer)
+			if *copyResult {
+				copyAnswer(answer)
+			}
+			if *speak {
+				speakAnswer(answer)
+			}
+			appendOutput(*mission, answer)
+			if *githubCommentOn > 0 {
+				shareAnswer(postGitHubComment, *githubCommentOn, *mission, answer)
+			}
+			if *gitlabCommentOn > 0 {
+				shareAnswer(postGitLabNote, *gitlabCommentOn, *mission, answer)
+			}
 		}
+		*mission = ""
+	}
 
-		messages = append(messages, *msg)
-
-		for _, tc := range msg.ToolCalls {
-			res, err := runTool(tc.Function.Name, tc.Function.Arguments)
-			if err != nil {
-				fmt.Printf("\033[31mError: %v\n", err)
-				res = fmt.Sprintf("Error: %v", err)
-			}
+	exportReport(messages)
+	if *dryRun {
+		report("Dry run, not executed", dryRunTable())
+	}
+	if !*quiet {
+		report("Session cost", costTable())
+		report("Provider stats", statsTable())
+	}
+}
 
-			// Tool results are appended to the message history using 'tool' role and associated ToolCallID,
-			// enabling the model to incorporate execution feedback into further reasoning.
-			messages = append(messages, ChatMessage{
-				Role:       "tool",
-				Content:    res,
-				ToolCallID: tc.ID,
-			})
+// runToolCalls executes one message's tool calls and returns their results in call order. Read-only tools run
+// concurrently on a bounded pool, since each study_file_contents call waits on its own LLM round-trip; any other
+// tool acts as a barrier and runs alone, so side effects still happen in the order the model asked for them.
+func runToolCalls(ctx context.Context, calls []ToolCall, status *statusLine) []string {
+	results := make([]string, len(calls))
+	sem := make(chan struct{}, max(*toolWorkers, 1))
+	var wg sync.WaitGroup
+	for i, tc := range calls {
+		if !readOnlyTools[tc.Function.Name] {
+			wg.Wait()
+			status.set("🔧 Running " + tc.Function.Name)
+			results[i] = runToolCall(ctx, tc)
+			continue
 		}
+		sem <- struct{}{}
+		status.set("🔧 Running " + tc.Function.Name)
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			results[i] = runToolCall(ctx, tc)
+		}()
+	}
+	wg.Wait()
+	return results
+}
 
-		// Display final answer if any
-		if msg.Content != "" {
-			fmt.Printf("\033[90m=== \033[34mResult\033[90m ===\n\033[32m%s\033[90m\n==============\033[0m\n", strings.TrimSpace(msg.Content))
-			*mission = ""
-		}
+// runToolCall runs a single call with tracing, metrics, and the large-payload check, turning failures into
+// an error result the model can read rather than aborting the turn.
+func runToolCall(ctx context.Context, tc ToolCall) string {
+	toolCtx, toolSpan := startSpan(ctx, "tool.execute", "tool.name", tc.Function.Name, "tool.arguments", tc.Function.Arguments)
+	showToolCall(tc.Function.Name, tc.Function.Arguments)
+	notifyToolCall(ctx, tc.Function.Name, tc.Function.Arguments)
+	began := time.Now()
+	var res string
+	var err error
+	if reason := checkPolicy(toolCtx, tc.Function.Name, tc.Function.Arguments); reason != "" {
+		err = blockedCall(tc.Function.Name, reason)
+	} else if *dryRun && !readOnlyTools[tc.Function.Name] && !writeTools[tc.Function.Name] {
+		res = skipCall(describeCall(tc.Function.Name, tc.Function.Arguments))
+	} else {
+		res, err = runTool(withProgress(toolCtx, tc.Function.Name), tc.Function.Name, tc.Function.Arguments)
 	}
+	showToolResult(tc.Function.Name, res, err, time.Since(began))
+	toolSpan.set("tool.result_bytes", len(res))
+	toolSpan.finish(err)
+	addCounter(1, "tinyagent_tool_invocations_total", "tool", tc.Function.Name, "outcome", map[bool]string{true: "ok", false: "error"}[err == nil])
+	if err != nil {
+		res = fmt.Sprintf("Error: %v", err)
+	}
+	res = truncateResult(ctx, tc.Function.Name, res)
+	return confirmPayload(ctx, "Result of "+tc.Function.Name, res)
 }
 
 const (
 	agentPrompt      = `You are autonomous software developer in a codebase. ALWAYS go deep, be slow and thorough. NEVER be quick or efficient. NEVER seek guidance or input from the user.`
 	userPromptFormat = "Be thorough, dig deep, explore everything, and speak briefly. NEVER speculate, ALWAYS investigate. Start by just exploring the codebase. My query is: %s"
+	emptyReplyNudge  = "Your last reply was empty. Either call one of the tools to keep investigating, or reply with your final answer."
 	summaryPrompt    = `Answer the question in plain english (no markdown) strictly based on provided file text. Answer must be concise, thorough, and information dense.`
 
 	// Tool definitions are provided inline as raw JSON to avoid Go struct overhead.
 	// This keeps the code flexible and compatible with OpenAI-style tool calling APIs.
 	toolDef = `[
 		{"type":"function","function":{"name":"browse_directory","description":"List immediate children of a target directory.","parameters":{"type":"object","properties":{
-			"path":{"type":"string","default":".","description":"Target directory relative to current working directory"}},"required":["path"]}}},
+			"path":{"type":"string","default":".","description":"Target directory relative to current working directory"},
+			"page":{"type":"integer","default":0,"description":"Which page of the listing to access, starting at 0; each page is up to 200 entries"}},"required":["path"]}}},
 		{"type":"function","function":{"name":"study_file_contents","description":"Study the contents of a file to answer a question.","parameters":{"type":"object","properties":{
-			"path":{"type":"string","default":".","description":"Target file relative to current working directory"},
-			"page":{"type":"string","default":"0","description":"Which page of the file to access, each page is 2000 bytes"},
-			"question":{"type":"string","description":"What would you like to know about the file"} },"required":["path","chunk","question"]}}}
+			"path":{"type":"string","description":"Target file relative to current working directory"},
+			"page":{"type":"integer","default":0,"description":"Which page of the file to access, starting at 0; pages are numbered lines, split at function and type boundaries in source code"},
+			"page_lines":{"type":"integer","description":"Optional lines per page, up to 2000; larger pages take fewer calls. Keep the same value when paging through one file"},
+			"question":{"type":"string","description":"What would you like to know about the file"} },"required":["path","question"]}}},
+		{"type":"function","function":{"name":"study_files","description":"Ask one question about the first page of many files at once, studied in parallel. Use for cross-cutting questions.","parameters":{"type":"object","properties":{
+			"paths":{"type":"array","items":{"type":"string"},"description":"Target files relative to current working directory"},
+			"glob":{"type":"string","description":"Alternatively, a glob such as internal/**/*.go selecting the files"},
+			"question":{"type":"string","description":"What would you like to know about each file"} },"required":["question"]}}}
 		]`
 )
 
+var errEmptyReply = errors.New("empty reply")
+
+// readOnlyTools may run concurrently with each other because they never change anything on disk.
+var readOnlyTools = map[string]bool{"browse_directory": true, "study_file_contents": true, "study_files": true}
+
+// toolNames lists the tools offered this session, in declaration order.
+func toolNames() []string {
+	var defs []struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	json.Unmarshal([]byte(activeTools()), &defs)
+	names := make([]string, 0, len(defs))
+	for _, d := range defs {
+		names = append(names, d.Function.Name)
+	}
+	return names
+}
+
 // Minimal required API types
 type ChatMessage struct {
 	Role       string     `json:"role"`
 	Content    string     `json:"content,omitempty"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
+
+	// Thoughts is the reasoning split off the reply, kept for the exported report and never sent back.
+	Thoughts string `json:"-"`
+	// Images are data URLs sent with the content as image parts, see MarshalJSON.
+	Images []string `json:"-"`
 }
 
 type ToolCall struct {
@@ -127,43 +368,280 @@ type ToolCall struct {
 	} `json:"function"`
 }
 
+// warmUp sends the initial LLM query in the background, so a slow local model loads while the user is still
+// typing their mission. The returned func blocks until the model has answered, and exits if it never came online;
+// only the first call waits, later ones return immediately.
+func warmUp(ctx context.Context) func() {
+	event(slog.LevelInfo, fmt.Sprintf("\033[37m=== Warming up \033[35m%s\033[37m in the background\033[0m\n", *model), "warming up", "model", *model, "url", *apiURL)
+	type reply struct {
+		msg *ChatMessage
+		err error
+	}
+	done := make(chan reply, 1)
+	name, endpoint := *model, *apiURL // /model may switch them before the warm-up request goes out
+	go func() {
+		msg, _, err := sendChatRequest(withEndpoint(withPurpose(ctx, "warm-up"), endpoint), name, []ChatMessage{{Role: "user", Content: "Be concise, are you ready to work?"}}, nil)
+		done <- reply{msg, err}
+	}()
+	return sync.OnceFunc(func() {
+		status := startStatus("🔥 Waiting for warm-up")
+		res := <-done
+		status.stop()
+		if res.err != nil {
+			event(slog.LevelError, fmt.Sprintf("\033[31mWarm-up failed: %v\n", res.err), "warm-up failed", "err", res.err)
+			os.Exit(1)
+		}
+		reply := strings.TrimSpace(res.msg.Content)
+		event(slog.LevelInfo, fmt.Sprintf("\033[90mLLM says: \033[34m%s\033[0m\n", reply), "model ready", "reply", reply)
+	})
+}
+
+// httpClient is shared by every outgoing call so planning and concurrent summarization requests reuse warm
+// connections instead of paying a fresh TCP/TLS handshake each time. There is deliberately no overall timeout:
+// local models can legitimately take minutes to produce a long completion.
+var httpClient = &http.Client{Transport: &http.Transport{
+	Proxy:                 http.ProxyFromEnvironment,
+	DialContext:           (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ExpectContinueTimeout: time.Second,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          64,
+	MaxIdleConnsPerHost:   16,
+	IdleConnTimeout:       90 * time.Second,
+}}
+
+// gzipAccepted tracks whether request bodies are still being compressed; it starts at --gzip-requests and is
+// switched off if the provider answers 415. Response compression needs no flag: the transport always advertises
+// Accept-Encoding: gzip and transparently decodes, as long as we never set that header ourselves.
+var gzipAccepted atomic.Bool
+
+// Replies cut off at max_tokens are continued rather than shown half-finished: text is stitched from follow-up
+// requests, while a truncated tool call is retried with a larger token limit since its JSON can't be resumed.
+const (
+	maxContinuations = 3
+	continuePrompt   = "Your reply was cut off. Continue exactly where you stopped, without repeating anything."
+)
+
+// A restarting local server or a flaky gateway used to abort the whole mission and lose its context. Gateway
+// errors, rate limits, and dropped connections are retried with exponential backoff before giving up.
+var (
+	maxRetries     = flag.Int("max-retries", 5, "Retries of a failed or rate-limited LLM request before giving up")
+	retryBaseDelay = flag.Duration("retry-base-delay", time.Second, "Wait before the first retry, doubling with each one after")
+	retryMaxDelay  = flag.Duration("retry-max-delay", 30*time.Second, "Longest wait between retries")
+)
+
+var retryableStatus = map[int]bool{
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// backoff waits before retry attempt n (--retry-base-delay, then twice that, ...), or as long as the provider
+// asked via Retry-After, which is allowed at least a minute since retrying sooner would only be refused again.
+func backoff(ctx context.Context, attempt int, retryAfter time.Duration, reason string) error {
+	// Doubling up to the cap, rather than shifting by the attempt, can't overflow into a negative wait.
+	wait := *retryBaseDelay
+	for i := 1; i < attempt && wait < *retryMaxDelay; i++ {
+		wait *= 2
+	}
+	wait = min(wait, *retryMaxDelay)
+	if retryAfter > 0 {
+		wait = min(retryAfter, max(*retryMaxDelay, time.Minute))
+	}
+	event(slog.LevelWarn, fmt.Sprintf("\033[33m%s, retrying in %s (%d/%d)\033[0m\n", reason, wait, attempt, *maxRetries),
+		"retrying request", "reason", reason, "wait", wait.String(), "attempt", attempt)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
+
+// apiError keeps the provider's own explanation, which is the only place a context overflow is distinguishable
+// from any other 400.
+type apiError struct {
+	status, code, message string
+}
+
+func (e *apiError) Error() string {
+	if e.message == "" {
+		return "API error: " + e.status
+	}
+	return fmt.Sprintf("API error: %s: %s", e.status, e.message)
+}
+
+// newAPIError reads OpenAI-style {"error":{"message","code"}}, plain {"error":"..."}, or a raw text body.
+func newAPIError(status string, body []byte) error {
+	var parsed struct {
+		Error json.RawMessage `json:"error"`
+	}
+	var detail struct {
+		Message string `json:"message"`
+		Code    any    `json:"code"`
+	}
+	e := &apiError{status: status}
+	switch {
+	case json.Unmarshal(body, &parsed) == nil && json.Unmarshal(parsed.Error, &detail) == nil && detail.Message != "":
+		e.message = detail.Message
+		if detail.Code != nil {
+			e.code = fmt.Sprint(detail.Code)
+		}
+	case len(parsed.Error) > 0 && json.Unmarshal(parsed.Error, &e.message) == nil:
+	default:
+		e.message = strings.TrimSpace(string(body[:min(len(body), 300)]))
+	}
+	return e
+}
+
+var overflowMessage = regexp.MustCompile(`(?i)context[ _-]?(length|window|size)|maximum context|too many tokens|prompt is too long|exceeds? (the )?(max|model)`)
+
+// isContextOverflow recognizes the many ways providers say the prompt no longer fits the model.
+func isContextOverflow(err error) bool {
+	var e *apiError
+	return errors.As(err, &e) && (e.code == "context_length_exceeded" || overflowMessage.MatchString(e.message))
+}
+
+// newAPIRequest builds a fresh request for every attempt, since a retried request can't reuse a drained body.
+func newAPIRequest(ctx context.Context, endpoint string, body []byte) *http.Request {
+	var payload bytes.Buffer
+	compressed := gzipAccepted.Load()
+	if compressed {
+		zw := gzip.NewWriter(&payload)
+		zw.Write(body)
+		zw.Close()
+	} else {
+		payload.Write(body)
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", endpoint, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("OPENAI_API_KEY"))
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	return req
+}
+
+// discardBody drains and closes a response that is retried, so its connection goes back to the pool instead of
+// staying open until the request gives up.
+func discardBody(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+}
+
+type endpointKey struct{}
+
+// withEndpoint sends the LLM requests made under ctx to endpoint, fixed when ctx is made, rather than to --url
+// as it is when each one goes out; /model may change it meanwhile.
+func withEndpoint(ctx context.Context, endpoint string) context.Context {
+	return context.WithValue(ctx, endpointKey{}, endpoint)
+}
+
+func endpointOf(ctx context.Context) string {
+	if e, ok := ctx.Value(endpointKey{}).(string); ok {
+		return e
+	}
+	return *apiURL
+}
+
 // sendChatRequest includes retry logic for rate limits (HTTP 429), preventing fragile runs.
 // This enables long-running sessions without manual retry intervention.
-func sendChatRequest(model string, messages []ChatMessage, tools []byte) (*ChatMessage, string, error) {
+func sendChatRequest(ctx context.Context, model string, messages []ChatMessage, tools []byte) (msg *ChatMessage, thoughts string, err error) {
+	endpoint := endpointOf(ctx)
+	_, sp := startSpan(ctx, "llm.request", "gen_ai.request.model", model, "messages", len(messages))
+	start, retries := time.Now(), 0
+	defer func() {
+		sp.finish(err)
+		recordStats(endpoint, model, time.Since(start).Seconds(), retries, err)
+		if err != nil {
+			recordLLMRequest(model, time.Since(start).Seconds(), 0, 0, 0, err)
+		}
+	}()
+
 	// Build request with raw JSON for smaller code footprint
 	reqMap := map[string]interface{}{
 		"model":       model,
 		"max_tokens":  4096,
-		"temperature": 0.3,
-		"messages":    messages,
-		"tools":       json.RawMessage(tools),
+		"temperature": temperatureOf(ctx),
 	}
+	setPayload(reqMap, messages, tools)
 
 	reqBody, _ := json.Marshal(reqMap)
-	req, _ := http.NewRequest("POST", *apiURL, strings.NewReader(string(reqBody)))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+os.Getenv("OPENAI_API_KEY"))
+	if *verbose {
+		dumpJSON(fmt.Sprintf("POST %s (Authorization: Bearer [redacted])", endpoint), reqBody)
+	}
+	if err := confirmRequestCost(ctx, reqBody, reqMap["max_tokens"].(int)); err != nil {
+		return nil, "", err
+	}
 
-	start := time.Now()
+	transient, continued, partial := 0, 0, ""
 	for {
-		resp, err := http.DefaultClient.Do(req)
+		resp, err := httpClient.Do(newAPIRequest(ctx, endpoint, reqBody))
 		if err != nil {
+			if ctx.Err() == nil && transient < *maxRetries {
+				transient, retries = transient+1, retries+1
+				if err := backoff(ctx, transient, 0, err.Error()); err != nil {
+					return nil, "", err
+				}
+				continue
+			}
 			return nil, "", err
 		}
-		defer resp.Body.Close()
 
-		if resp.StatusCode == http.StatusTooManyRequests {
-			time.Sleep(time.Second)
+		// A provider that rejects compressed bodies is remembered for the rest of the session.
+		if resp.StatusCode == http.StatusUnsupportedMediaType && gzipAccepted.Swap(false) {
+			discardBody(resp)
+			event(slog.LevelWarn, "\033[33mProvider rejected gzip request bodies, sending uncompressed\033[0m\n", "gzip rejected", "url", endpoint)
+			continue
+		}
+
+		if (resp.StatusCode == http.StatusTooManyRequests || retryableStatus[resp.StatusCode]) && transient < *maxRetries {
+			transient, retries = transient+1, retries+1
+			wait, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+			discardBody(resp)
+			if err := backoff(ctx, transient, time.Duration(wait)*time.Second, resp.Status); err != nil {
+				return nil, "", err
+			}
 			continue
 		}
 
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			if ctx.Err() == nil && transient < *maxRetries {
+				transient, retries = transient+1, retries+1
+				if err := backoff(ctx, transient, 0, err.Error()); err != nil {
+					return nil, "", err
+				}
+				continue
+			}
+			return nil, "", fmt.Errorf("failed to read response: %v", err)
+		}
+		if *verbose {
+			dumpJSON("Response "+resp.Status, body)
+		}
+
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && len(tools) > 0 && toolsRejected.Match(body) && !legacyFunctions.Swap(true) {
+			event(slog.LevelWarn, "\033[33mProvider rejected tools, switching to legacy function calling\033[0m\n", "tools rejected", "url", endpoint)
+			setPayload(reqMap, messages, tools)
+			reqBody, _ = json.Marshal(reqMap)
+			continue
+		}
 		if resp.StatusCode != http.StatusOK {
-			return nil, "", fmt.Errorf("API error: %s", resp.Status)
+			return nil, "", newAPIError(resp.Status, body)
 		}
 
 		var result struct {
 			Choices []struct {
-				Message ChatMessage `json:"message"`
+				Message struct {
+					ChatMessage
+					FunctionCall     *functionCall `json:"function_call"`
+					ReasoningContent string        `json:"reasoning_content"`
+					Reasoning        string        `json:"reasoning"`
+				} `json:"message"`
+				FinishReason string `json:"finish_reason"`
 			}
 			Usage struct {
 				PromptTokens     int `json:"prompt_tokens"`
@@ -171,112 +649,231 @@ func sendChatRequest(model string, messages []ChatMessage, tools []byte) (*ChatM
 			}
 		}
 
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		if err := json.Unmarshal(body, &result); err != nil {
 			return nil, "", fmt.Errorf("failed to decode response: %v", err)
 		}
 		if len(result.Choices) == 0 {
 			return nil, "", fmt.Errorf("no response")
 		}
 
-		cost := float64(result.Usage.PromptTokens)*(0.10/1_000_000) + float64(result.Usage.CompletionTokens)*(0.40/1_000_000)
-		fmt.Printf("\033[90mDone in %.1fs for \033[35m%.2fc\033[90m (%d/%d tokens)\033[0m\n", time.Since(start).Seconds(), cost*100, result.Usage.PromptTokens, result.Usage.CompletionTokens) // keep purple
-
-		msg := result.Choices[0].Message
-
-		// Thoughts are parsed and separated from final content using a custom `</think>` marker.
-		// This allows optional introspection/debugging of the model's reasoning phase.
-		if i := strings.LastIndex(msg.Content, `</think>`); i != -1 {
-			thoughts := msg.Content[:i+7]
-			msg.Content = msg.Content[i+8:]
-			return &msg, strings.TrimSpace(thoughts), nil
+		cost := requestCost(result.Usage.PromptTokens, result.Usage.CompletionTokens)
+		sp.set("gen_ai.usage.input_tokens", result.Usage.PromptTokens)
+		sp.set("gen_ai.usage.output_tokens", result.Usage.CompletionTokens)
+		sp.set("tinyagent.cost_usd", cost)
+		elapsed := time.Since(start).Seconds()
+		recordLLMRequest(model, elapsed, result.Usage.PromptTokens, result.Usage.CompletionTokens, cost, nil)
+		recordUsage(ctx, model, usage{1, result.Usage.PromptTokens, result.Usage.CompletionTokens, cost})
+		event(slog.LevelInfo, fmt.Sprintf("\033[90mDone in %.1fs for \033[35m%.2fc\033[90m (%d/%d tokens)\033[0m\n", elapsed, cost*100, result.Usage.PromptTokens, result.Usage.CompletionTokens), // keep purple
+			"llm request", "model", model, "seconds", elapsed, "cost_cents", cost*100, "prompt_tokens", result.Usage.PromptTokens, "completion_tokens", result.Usage.CompletionTokens)
+
+		reply := result.Choices[0].Message
+		if result.Choices[0].FinishReason == "length" && continued < maxContinuations {
+			continued++
+			if len(reply.ToolCalls) > 0 {
+				// Half a tool call can't be stitched: its arguments are cut-off JSON. Ask again with more room.
+				reqMap["max_tokens"] = reqMap["max_tokens"].(int) * 2
+				event(slog.LevelWarn, fmt.Sprintf("\033[33mTool call cut off at the token limit, retrying with max_tokens=%d\033[0m\n", reqMap["max_tokens"]),
+					"reply truncated", "kind", "tool_call", "max_tokens", reqMap["max_tokens"])
+			} else {
+				partial += reply.Content
+				event(slog.LevelWarn, "\033[33mReply cut off at the token limit, asking the model to continue\033[0m\n", "reply truncated", "kind", "content", "continuation", continued)
+				setPayload(reqMap, append(messages[:len(messages):len(messages)],
+					ChatMessage{Role: "assistant", Content: partial}, ChatMessage{Role: "user", Content: continuePrompt}), tools)
+			}
+			reqBody, _ = json.Marshal(reqMap)
+			if *verbose {
+				dumpJSON(fmt.Sprintf("POST %s (Authorization: Bearer [redacted])", endpoint), reqBody)
+			}
+			continue
 		}
-
-		return &msg, "This model provided no thoughts.", nil
+		reply.Content = partial + reply.Content
+		msg := &reply.ChatMessage
+		adoptFunctionCall(msg, reply.FunctionCall)
+		msg.Content, thoughts = splitThoughts(msg.Content, reply.ReasoningContent, reply.Reasoning)
+		if *reactMode && len(tools) > 0 && len(msg.ToolCalls) == 0 {
+			var reasoning string
+			msg.Content, msg.ToolCalls, reasoning = parseReAct(msg.Content)
+			_, thoughts = splitThoughts("", thoughts, reasoning)
+		}
+		return msg, thoughts, nil
 	}
 }
 
-// fileType uses UTF-8 validity as a fast heuristic to distinguish text from binary files.
-// This avoids incorrect LLM inputs from non-text content, which could break prompt context.
-func fileType(path string) string {
-	file, err := os.Open(path)
-	if err != nil {
-		return fmt.Sprintf("Error opening file: %v", err)
+// dumpJSON pretty-prints a raw API payload, which is the quickest way to see why a model won't call tools.
+func dumpJSON(label string, raw []byte) {
+	var pretty bytes.Buffer
+	if json.Indent(&pretty, raw, "", "  ") != nil {
+		pretty.Reset()
+		pretty.Write(raw)
 	}
-	defer file.Close()
+	event(slog.LevelInfo, fmt.Sprintf("\n\033[90m--- %s ---\n%s\n---\033[0m\n", label, pretty.String()), "raw payload", "label", label, "json", string(raw))
+}
 
-	header := make([]byte, 512)
-	n, err := file.Read(header)
+// runTool executes any tool the LLM requests. It loosely prevents escaping the current working directory.
+func runTool(ctx context.Context, name, args string) (string, error) {
+	args, err := validateArgs(name, args)
 	if err != nil {
-		return fmt.Sprintf("Error reading file header: %v", err)
+		return "", err
 	}
-	header = header[:n]
-	if utf8.Valid(header) {
-		return "text"
+	// Arguments are validated and defaulted by now, so one struct covers every tool's parameters.
+	var params struct {
+		Path     string   `json:"path"`
+		Page     int      `json:"page"`
+		PageSize int      `json:"page_lines"`
+		Question string   `json:"question"`
+		Paths    []string `json:"paths"`
+		Glob     string   `json:"glob"`
+		Content  string   `json:"content"`
+		OldText  string   `json:"old_text"`
+		NewText  string   `json:"new_text"`
 	}
-	return "binary"
-}
-
-// runTool executes any tool the LLM requests. It loosely prevents escaping the current working directory.
-func runTool(name, args string) (string, error) {
-	params := map[string]string{}
 	json.Unmarshal([]byte(args), &params)
 
-	// Handle directory
-	if name == "browse_directory" {
-		fmt.Printf("\033[90m🔍 Analyzing directory `\033[35m%s\033[90m`...\n", params["path"])
-		if !filepath.IsLocal(params["path"]) {
-			return "", fmt.Errorf("Permanent Error: Path %s is outside of current working directory", params["path"])
-		}
-		entries, err := os.ReadDir(params["path"])
-		if err != nil {
-			return "", fmt.Errorf("Error reading directory: %v", err)
+	switch name {
+	case "browse_directory":
+		if !filepath.IsLocal(params.Path) {
+			return "", fmt.Errorf("Permanent Error: Path %s is outside of current working directory", params.Path)
 		}
+		return browseDirectory(params.Path, params.Page)
 
-		filesByType := make(map[string][]string)
-		for _, entry := range entries {
-			fullPath := filepath.Join(params["path"], entry.Name())
-			if typ := fileType(fullPath); !entry.IsDir() {
-				filesByType[typ+" files"] = append(filesByType[typ+" files"], "`"+fullPath+"`")
-			} else {
-				filesByType["subdirectories"] = append(filesByType["subdirectories"], "`"+fullPath+"`")
-			}
-		}
+	case "study_files":
+		return studyFiles(ctx, params.Paths, params.Glob, params.Question)
 
-		parts := make([]string, 0)
-		for typ, files := range filesByType {
-			parts = append(parts, fmt.Sprintf("- %s: %s", typ, files))
+	case "study_file_contents":
+		return studyFile(ctx, params.Path, params.Page, params.PageSize, params.Question)
+	}
+
+	if writeTools[name] && !*allowWrites {
+		return "", fmt.Errorf("Permanent Error: %s is disabled, this session is read-only", name)
+	}
+	switch name {
+	case "write_file":
+		return writeFile(ctx, params.Path, params.Content)
+	case "edit_file":
+		return editFile(ctx, params.Path, params.OldText, params.NewText)
+	}
+	if ts := toolsetFor(name); ts != nil {
+		if !ts.enabled() {
+			return "", fmt.Errorf("Permanent Error: %s is not available in this session", name)
 		}
-		return fmt.Sprintf("analyze_path `%s` results:\n%s", params["path"], strings.Join(parts, "\n")), nil
+		return ts.run[name](ctx, args)
 	}
 
-	start, _ := strconv.Atoi(params["page"])
-	fmt.Printf("\033[90m🧠 Look at `\033[35m%v page %d\033[90m`. %s ", params["path"], start, params["question"])
-	if !filepath.IsLocal(params["path"]) {
-		return "", fmt.Errorf("Permanent Error: Path %s is outside of current working directory", params["path"])
+	// Small models invent tools like read_file or list_dir; naming the real ones lets them correct course.
+	return "", fmt.Errorf("Unknown tool %q, available tools are: %s", name, strings.Join(toolNames(), ", "))
+}
+
+// studyFile answers a question about one page of a file through a separate summarization request,
+// so only the short answer, never the raw page, enters the main conversation.
+func studyFile(ctx context.Context, path string, start, size int, question string) (string, error) {
+	size = pageSize(size)
+	if !filepath.IsLocal(path) {
+		return "", fmt.Errorf("Permanent Error: Path %s is outside of current working directory", path)
 	}
-	if contentType := fileType(params["path"]); contentType != "text" {
+	contentType := fileType(path)
+	if contentType != kindText && contentType != kindUTF16 {
 		return "", fmt.Errorf("Not a text file (detected: %s)", contentType)
 	}
 
-	file, err := os.Open(params["path"])
+	file, err := workspace.Open(path)
 	if err != nil {
 		return "", fmt.Errorf("Error opening file: %v", err)
 	}
 	defer file.Close()
+	pages, ok := file.(io.ReaderAt)
+	if !ok {
+		return "", fmt.Errorf("Error reading file: %s does not support paging", toolPath(path))
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("Error reading file: %v", err)
+	}
+	key, err := summaryKey(pages, info.Size(), start, size, question, *model)
+	if err != nil {
+		return "", fmt.Errorf("Error reading file: %v", err)
+	}
+
+	// Pages are whole numbered lines, cut along declarations for source files, so every page fits the prompt and
+	// holds complete units of code. Line numbers let the model cite and revisit exact locations.
+	text, err := openText(pages, info.Size(), contentType)
+	if err != nil {
+		return "", fmt.Errorf("Error reading file: %v", err)
+	}
+	pg, err := readPage(text, path, start, size)
+	if err != nil {
+		return "", fmt.Errorf("Error reading file: %v", err)
+	}
+	if pg.Total == 0 {
+		return fmt.Sprintf("study_file_contents %v results\n(the file is empty)", toolPath(path)), nil
+	}
+	if pg.First == 0 {
+		return "", fmt.Errorf("Page %d does not exist, %s has %d pages (0-%d)", start, toolPath(path), pg.Total, pg.Total-1)
+	}
+	header := fmt.Sprintf("study_file_contents %v page %d of %d (lines %d-%d) results", toolPath(path), start, pg.Total, pg.First, pg.Last)
 
-	// file.Read is paginated using fixed byte chunks (2000 bytes per page) to safely handle large files.
-	// This prevents memory exhaustion and fits prompt size constraints for LLM input.
-	content, _ := io.ReadAll(io.NewSectionReader(file, int64(start*2000), 2000))
+	if answer, ok := cachedSummary(key); ok {
+		progress(ctx, fmt.Sprintf("cached answer for %s page %d", toolPath(path), start))
+		addCounter(1, "tinyagent_summary_cache_hits_total")
+		return fmt.Sprintf("%s\nQuestion: %s\nAnswer: %s", header, question, answer), nil
+	}
+	content := confirmPayload(ctx, fmt.Sprintf("Page %d of %s", start, path), pg.Text)
 
 	// Simple request for analysis
-	msg, _, err := sendChatRequest(*model, []ChatMessage{
+	msg, _, err := sendChatRequest(withPurpose(ctx, "summarization"), *model, []ChatMessage{
 		{Role: "system", Content: summaryPrompt},
-		{Role: "user", Content: string(content) + "\nThe question: " + params["question"]},
+		{Role: "user", Content: content + "\nThe question: " + question},
 	}, nil)
 
 	if err != nil {
 		return "", fmt.Errorf("Error analyzing file: %v", err)
 	}
+	storeSummary(key, msg.Content)
+
+	return fmt.Sprintf("%s\nQuestion: %s\nAnswer: %s", header, question, msg.Content), nil
+}
+
+// estimateTokens uses the common ~4 bytes per token rule of thumb, good enough for a safety prompt.
+func estimateTokens(s string) int {
+	return len(s) / 4
+}
+
+// confirmPayload pauses before an unusually large payload joins a request, since a single huge file or
+// listing can silently cost more than the rest of the session. The user may send, truncate, or summarize it.
+func confirmPayload(ctx context.Context, label, content string) string {
+	tokens := estimateTokens(content)
+	if *confirmTokens <= 0 || tokens <= *confirmTokens {
+		return content
+	}
 
-	return fmt.Sprintf("study_file_contents %v results\nQuestion: %s\nAnswer: %s", params["path"], params["question"], msg.Content), nil
+	answer, ok := ask(fmt.Sprintf("\n\033[33m⚠️  %s is ~%d tokens (threshold %d). \033[34m[s]end, [t]runcate, s[u]mmarize first?\033[90m > \033[0m", label, tokens, *confirmTokens))
+	if !ok {
+		return content
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "t", "truncate":
+		cut := strings.ToValidUTF8(content[:*confirmTokens*4], "")
+		return cut + fmt.Sprintf("\n[truncated %d of %d bytes]", len(content)-len(cut), len(content))
+	case "u", "summarize":
+		summary, err := summarizePayload(ctx, content)
+		if err != nil {
+			event(slog.LevelWarn, fmt.Sprintf("\033[31mError summarizing, sending as-is: %v\n", err), "summarize failed", "err", err)
+			return content
+		}
+		return summary
+	}
+	return content
+}
+
+// summarizePayload has the model condense a payload too large to send as is.
+func summarizePayload(ctx context.Context, content string) (string, error) {
+	msg, _, err := sendChatRequest(withPurpose(ctx, "summarization"), *model, []ChatMessage{
+		{Role: "system", Content: summaryPrompt},
+		{Role: "user", Content: content + "\nThe question: Summarize this text, keeping every detail a developer would need."},
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	return "[summarized] " + msg.Content, nil
 }
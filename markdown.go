@@ -0,0 +1,112 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Final answers are almost always Markdown. In color mode they are rendered for the terminal, covering what models
+// actually write: headings, lists, quotes, rules, fenced code, and inline emphasis, code, and links. Anything else
+// passes through untouched, and without color the raw Markdown is kept since it reads (and pastes) fine as is.
+var (
+	mdHeading = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	mdBullet  = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	mdNumber  = regexp.MustCompile(`^(\s*)(\d+[.)])\s+(.*)$`)
+	mdRule    = regexp.MustCompile(`^\s*(-\s*){3,}$|^\s*(\*\s*){3,}$|^\s*(_\s*){3,}$`)
+	mdFence   = regexp.MustCompile("^\\s*(```|~~~)\\s*([\\w+#.-]*)")
+
+	mdCode   = regexp.MustCompile("`([^`]+)`")
+	mdBold   = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	mdItalic = regexp.MustCompile(`(^|[^*\w])\*([^*\s][^*]*)\*|(^|[^_\w])_([^_\s][^_]*)_`)
+	mdLink   = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+)
+
+const mdReset = "\033[0m"
+
+func renderMarkdown(md string) string {
+	var out []string
+	var fence, lang string
+	var code []string
+	for _, line := range strings.Split(md, "\n") {
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				out = append(out, renderCode(lang, code)...)
+				fence, code = "", nil
+				continue
+			}
+			code = append(code, line)
+			continue
+		}
+		if m := mdFence.FindStringSubmatch(line); m != nil {
+			fence, lang = m[1], m[2]
+			continue
+		}
+
+		switch {
+		case mdHeading.MatchString(line):
+			m := mdHeading.FindStringSubmatch(line)
+			style := "\033[1;34m"
+			if len(m[1]) == 1 {
+				style = "\033[1;4;34m"
+			}
+			out = append(out, style+stripInline(m[2])+mdReset)
+		case mdRule.MatchString(line):
+			out = append(out, "\033[90m"+strings.Repeat("─", 40)+mdReset)
+		case mdBullet.MatchString(line):
+			m := mdBullet.FindStringSubmatch(line)
+			out = append(out, m[1]+"\033[36m•\033[0m "+renderInline(m[2]))
+		case mdNumber.MatchString(line):
+			m := mdNumber.FindStringSubmatch(line)
+			out = append(out, m[1]+"\033[36m"+m[2]+"\033[0m "+renderInline(m[3]))
+		case strings.HasPrefix(strings.TrimSpace(line), ">"):
+			quoted := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(line), ">"), " ")
+			out = append(out, "\033[90m│\033[0m \033[3m"+renderInline(quoted)+mdReset)
+		default:
+			out = append(out, renderInline(line))
+		}
+	}
+	if fence != "" { // an unterminated fence still renders as code
+		out = append(out, renderCode(lang, code)...)
+	}
+	return strings.Join(out, "\n")
+}
+
+// renderCode draws a fenced block with a gutter, so it stands apart from prose without boxes that break on resize.
+func renderCode(lang string, lines []string) []string {
+	out := make([]string, 0, len(lines)+1)
+	if lang != "" {
+		out = append(out, "\033[90m┌ "+lang+mdReset)
+	}
+	for _, line := range highlight(lang, lines) {
+		out = append(out, "\033[90m│\033[0m "+line+mdReset)
+	}
+	return out
+}
+
+// renderInline styles code spans first and keeps their contents literal, so `a*b*c` is not read as emphasis.
+func renderInline(s string) string {
+	parts := mdCode.Split(s, -1)
+	spans := mdCode.FindAllStringSubmatch(s, -1)
+	var b strings.Builder
+	for i, part := range parts {
+		b.WriteString(renderEmphasis(part))
+		if i < len(spans) {
+			b.WriteString("\033[33m" + spans[i][1] + mdReset)
+		}
+	}
+	return b.String()
+}
+
+func renderEmphasis(s string) string {
+	s = mdLink.ReplaceAllString(s, "\033[4;34m$1\033[0m \033[90m($2)\033[0m")
+	s = mdBold.ReplaceAllString(s, "\033[1m$1$2\033[0m")
+	return mdItalic.ReplaceAllString(s, "$1$3\033[3m$2$4\033[0m")
+}
+
+// stripInline removes inline markers from text that is styled as a whole, like headings.
+func stripInline(s string) string {
+	s = mdCode.ReplaceAllString(s, "$1")
+	s = mdLink.ReplaceAllString(s, "$1")
+	s = mdBold.ReplaceAllString(s, "$1$2")
+	return mdItalic.ReplaceAllString(s, "$1$3$2$4")
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Metrics are kept as plain maps and rendered in the Prometheus text format by hand, which covers
+// counters and one histogram without the weight of the official client library.
+var metricsAddr = flag.String("metrics-addr", "", "Serve Prometheus metrics on this address, e.g. :9090 (empty disables)")
+
+var latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	total  uint64
+}
+
+var (
+	metricsMu  sync.Mutex
+	counters   = map[string]float64{} // full series name with labels -> value
+	histograms = map[string]*histogram{}
+)
+
+var metricHelp = map[string]string{
+	"tinyagent_llm_requests_total":           "counter LLM API requests by model and outcome.",
+	"tinyagent_tokens_total":                 "counter Tokens consumed by model and kind.",
+	"tinyagent_cost_dollars_total":           "counter Estimated spend in US dollars by model.",
+	"tinyagent_tool_invocations_total":       "counter Tool executions by tool name and outcome.",
+	"tinyagent_summary_cache_hits_total":     "counter study_file_contents answers served from cache.",
+	"tinyagent_llm_request_duration_seconds": "histogram LLM API request latency by model.",
+}
+
+// series builds a Prometheus series name such as name{model="x"} from alternating label keys and values.
+func series(name string, labels ...string) string {
+	parts := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		parts = append(parts, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+	}
+	return name + "{" + strings.Join(parts, ",") + "}"
+}
+
+func addCounter(value float64, name string, labels ...string) {
+	metricsMu.Lock()
+	counters[series(name, labels...)] += value
+	metricsMu.Unlock()
+}
+
+func observe(seconds float64, name string, labels ...string) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	key := series(name, labels...)
+	h := histograms[key]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		histograms[key] = h
+	}
+	for i, le := range latencyBuckets {
+		if seconds <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.total++
+}
+
+// recordLLMRequest updates all per-request series in one place so call sites stay a single line.
+func recordLLMRequest(model string, seconds float64, promptTokens, completionTokens int, cost float64, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	addCounter(1, "tinyagent_llm_requests_total", "model", model, "outcome", outcome)
+	observe(seconds, "tinyagent_llm_request_duration_seconds", "model", model)
+	if err == nil {
+		addCounter(float64(promptTokens), "tinyagent_tokens_total", "model", model, "kind", "prompt")
+		addCounter(float64(completionTokens), "tinyagent_tokens_total", "model", model, "kind", "completion")
+		addCounter(cost, "tinyagent_cost_dollars_total", "model", model)
+	}
+}
+
+// serveMetrics starts the /metrics endpoint in the background if --metrics-addr is set.
+func serveMetrics() {
+	if *metricsAddr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", writeMetrics)
+	go func() {
+		if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+			event(slog.LevelError, fmt.Sprintf("\033[31mMetrics server stopped: %v\033[0m\n", err), "metrics server stopped", "err", err)
+		}
+	}()
+}
+
+func writeMetrics(w http.ResponseWriter, _ *http.Request) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	lines := map[string][]string{}
+	for key, v := range counters {
+		name := key[:strings.IndexByte(key, '{')]
+		lines[name] = append(lines[name], fmt.Sprintf("%s %g", key, v))
+	}
+	for key, h := range histograms {
+		name, labels := key[:strings.IndexByte(key, '{')], strings.Trim(key[strings.IndexByte(key, '{'):], "{}")
+		for i, le := range latencyBuckets {
+			lines[name] = append(lines[name], fmt.Sprintf("%s_bucket{%s,le=\"%g\"} %d", name, labels, le, h.counts[i]))
+		}
+		lines[name] = append(lines[name],
+			fmt.Sprintf("%s_bucket{%s,le=\"+Inf\"} %d", name, labels, h.total),
+			fmt.Sprintf("%s_sum{%s} %g", name, labels, h.sum),
+			fmt.Sprintf("%s_count{%s} %d", name, labels, h.total))
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	names := make([]string, 0, len(lines))
+	for name := range lines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		typ, help, _ := strings.Cut(metricHelp[name], " ")
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		if typ == "counter" {
+			sort.Strings(lines[name])
+		}
+		fmt.Fprintln(w, strings.Join(lines[name], "\n"))
+	}
+}
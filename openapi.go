@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The openapi tools answer questions about an HTTP API from its OpenAPI or Swagger document without paging
+// through thousands of lines of YAML: one lists the operations, the other gives a single operation with every
+// $ref resolved in place. The document is --openapi, a file or URL, or else the first of the usual file names
+// found in the workspace. A call can name another document in the workspace, which is loaded once per session;
+// only the user may point the tools at a URL, through --openapi, since the model could be steered to fetch any.
+var openapiSpec = flag.String("openapi", "", "OpenAPI or Swagger document for the openapi tools, a file or URL (default: openapi.yaml or swagger.json and similar, when present)")
+
+const openapiToolDef = `[
+		{"type":"function","function":{"name":"openapi_operations","description":"List the operations of an HTTP API from its OpenAPI document: method, path, operationId, and summary.","parameters":{"type":"object","properties":{
+			"filter":{"type":"string","description":"Optional text to match in the method, path, operationId, summary, or tags"},
+			"spec":{"type":"string","description":"Optional OpenAPI file in the workspace, defaults to this project's"} }}}},
+		{"type":"function","function":{"name":"openapi_operation","description":"Show one API operation in full: parameters, request body, and responses with their schemas resolved.","parameters":{"type":"object","properties":{
+			"method":{"type":"string","description":"HTTP method, such as GET"},
+			"path":{"type":"string","description":"Path as listed, such as /pets/{petId}, or a concrete one like /pets/42"},
+			"spec":{"type":"string","description":"Optional OpenAPI file in the workspace, defaults to this project's"} },"required":["method","path"]}}}
+		]`
+
+const (
+	maxOpenAPIOperations = 300
+	maxOpenAPIOutput     = 32 << 10
+	maxRefDepth          = 8 // how deep nested schemas are resolved before a $ref is left as it is
+)
+
+var openapiFiles = []string{"openapi.yaml", "openapi.yml", "openapi.json", "swagger.yaml", "swagger.yml", "swagger.json",
+	"api/openapi.yaml", "api/openapi.json", "docs/openapi.yaml", "docs/openapi.json"}
+
+var openapiMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// defaultSpec returns --openapi or the first usual file present, or "".
+var defaultSpec = sync.OnceValue(func() string {
+	if *openapiSpec != "" {
+		return *openapiSpec
+	}
+	for _, name := range openapiFiles {
+		if _, err := workspace.Stat(name); err == nil {
+			return name
+		}
+	}
+	return ""
+})
+
+var openapiTools = registerToolset(&toolset{
+	def:      openapiToolDef,
+	enabled:  func() bool { return defaultSpec() != "" },
+	readOnly: true,
+	run: map[string]func(context.Context, string) (string, error){
+		"openapi_operations": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Filter string `json:"filter"`
+				Spec   string `json:"spec"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			return openapiOperations(ctx, params.Spec, params.Filter)
+		},
+		"openapi_operation": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Method string `json:"method"`
+				Path   string `json:"path"`
+				Spec   string `json:"spec"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			return openapiOperation(ctx, params.Spec, params.Method, params.Path)
+		},
+	},
+})
+
+var openapiDocs struct {
+	sync.Mutex
+	loaded map[string]map[string]any
+}
+
+// loadSpec reads and parses a document, from the workspace or, for --openapi, over HTTP, keeping it for later
+// calls. The lock is not held while reading, so a slow fetch doesn't hold up calls on documents already loaded.
+func loadSpec(ctx context.Context, spec string) (string, map[string]any, error) {
+	if spec == "" {
+		spec = defaultSpec()
+	}
+	openapiDocs.Lock()
+	doc, ok := openapiDocs.loaded[spec]
+	openapiDocs.Unlock()
+	if ok {
+		return spec, doc, nil
+	}
+	var raw []byte
+	var err error
+	switch {
+	case spec == *openapiSpec && (strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://")):
+		raw, err = fetchSpec(ctx, spec)
+	case strings.Contains(spec, "://"):
+		return "", nil, fmt.Errorf("Permanent Error: only the user can give a URL, with --openapi; name a file in the workspace")
+	case spec == *openapiSpec:
+		raw, err = os.ReadFile(spec) // given by the user, so it may be anywhere
+	case !filepath.IsLocal(spec):
+		return "", nil, fmt.Errorf("Permanent Error: Path %s is outside of current working directory", spec)
+	default:
+		raw, err = workspace.ReadFile(spec)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("Error reading %s: %v", spec, err)
+	}
+	parsed, err := parseYAML(raw)
+	if err != nil {
+		return "", nil, fmt.Errorf("Error parsing %s: %v", spec, err)
+	}
+	doc, ok = parsed.(map[string]any)
+	if _, hasPaths := doc["paths"]; !ok || !hasPaths {
+		return "", nil, fmt.Errorf("Permanent Error: %s is not an OpenAPI or Swagger document, it has no paths", spec)
+	}
+	openapiDocs.Lock()
+	defer openapiDocs.Unlock()
+	if openapiDocs.loaded == nil {
+		openapiDocs.loaded = map[string]map[string]any{}
+	}
+	openapiDocs.loaded[spec] = doc
+	return spec, doc, nil
+}
+
+func fetchSpec(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+}
+
+func openapiOperations(ctx context.Context, spec, filter string) (string, error) {
+	spec, doc, err := loadSpec(ctx, spec)
+	if err != nil {
+		return "", err
+	}
+	paths, _ := doc["paths"].(map[string]any)
+	names := make([]string, 0, len(paths))
+	for path := range paths {
+		names = append(names, path)
+	}
+	sort.Strings(names)
+	var lines []string
+	for _, path := range names {
+		item, _ := paths[path].(map[string]any)
+		for _, method := range openapiMethods {
+			op, ok := item[method].(map[string]any)
+			if !ok {
+				continue
+			}
+			line := fmt.Sprintf("%s %s", strings.ToUpper(method), path)
+			if id, _ := op["operationId"].(string); id != "" {
+				line += "  " + id
+			}
+			if summary, _ := op["summary"].(string); summary != "" {
+				line += ": " + strings.TrimSpace(summary)
+			}
+			if tags, _ := op["tags"].([]any); len(tags) > 0 {
+				line += fmt.Sprintf(" %v", tags)
+			}
+			if op["deprecated"] == true {
+				line += " (deprecated)"
+			}
+			if filter == "" || strings.Contains(strings.ToLower(line), strings.ToLower(filter)) {
+				lines = append(lines, line)
+			}
+		}
+	}
+	title := spec
+	if info, ok := doc["info"].(map[string]any); ok {
+		title = fmt.Sprintf("%v %v (%s)", info["title"], info["version"], spec)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "openapi_operations %s results (%d operations)\n", title, len(lines))
+	for i, line := range lines {
+		if i == maxOpenAPIOperations {
+			fmt.Fprintf(&b, "(%d more operations, pass a filter to narrow the list)\n", len(lines)-i)
+			break
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String(), nil
+}
+
+func openapiOperation(ctx context.Context, spec, method, path string) (string, error) {
+	spec, doc, err := loadSpec(ctx, spec)
+	if err != nil {
+		return "", err
+	}
+	method = strings.ToLower(method)
+	paths, _ := doc["paths"].(map[string]any)
+	template, item := matchAPIPath(paths, path)
+	if item == nil {
+		return "", fmt.Errorf("No path %s in %s; list the operations to find it", path, spec)
+	}
+	op, ok := item[method].(map[string]any)
+	if !ok {
+		var methods []string
+		for _, m := range openapiMethods {
+			if _, ok := item[m]; ok {
+				methods = append(methods, strings.ToUpper(m))
+			}
+		}
+		return "", fmt.Errorf("%s has no %s operation, only %s", template, strings.ToUpper(method), strings.Join(methods, ", "))
+	}
+	// Parameters shared by every method of the path come first, as the spec merges them.
+	detail := map[string]any{}
+	for k, v := range op {
+		detail[k] = v
+	}
+	if shared, ok := item["parameters"].([]any); ok {
+		own, _ := op["parameters"].([]any)
+		detail["parameters"] = append(append([]any{}, shared...), own...)
+	}
+	resolved := resolveRefs(doc, detail, nil)
+	raw, err := json.MarshalIndent(resolved, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("openapi_operation %s %s results\n%s", strings.ToUpper(method), template, clipOutput(string(raw), maxOpenAPIOutput, false)), nil
+}
+
+// matchAPIPath finds path among the spec's paths, as written or as a concrete path matching a template.
+func matchAPIPath(paths map[string]any, path string) (string, map[string]any) {
+	if item, ok := paths[path].(map[string]any); ok {
+		return path, item
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for template, v := range paths {
+		tparts := strings.Split(strings.Trim(template, "/"), "/")
+		if len(tparts) != len(parts) {
+			continue
+		}
+		match := true
+		for i, t := range tparts {
+			if t != parts[i] && !(strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}")) {
+				match = false
+				break
+			}
+		}
+		if item, ok := v.(map[string]any); ok && match {
+			return template, item
+		}
+	}
+	return "", nil
+}
+
+// resolveRefs replaces local $refs in v with what they point to. A schema that refers to itself, directly or
+// through others, keeps its $ref the second time, as do refs nested deeper than maxRefDepth.
+func resolveRefs(doc map[string]any, v any, seen []string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		if ref, ok := v["$ref"].(string); ok && strings.HasPrefix(ref, "#/") {
+			for _, s := range seen {
+				if s == ref {
+					return map[string]any{"$ref": ref, "note": "recursive"}
+				}
+			}
+			if len(seen) >= maxRefDepth {
+				return v
+			}
+			target, ok := jsonPointer(doc, ref[1:])
+			if !ok {
+				return v
+			}
+			return resolveRefs(doc, target, append(seen, ref))
+		}
+		out := make(map[string]any, len(v))
+		for k, child := range v {
+			out[k] = resolveRefs(doc, child, seen)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, child := range v {
+			out[i] = resolveRefs(doc, child, seen)
+		}
+		return out
+	}
+	return v
+}
+
+// jsonPointer looks up an RFC 6901 pointer such as /components/schemas/Pet.
+func jsonPointer(doc any, pointer string) (any, bool) {
+	v := doc
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[token]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
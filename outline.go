@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// outline_file gives the shape of a file in one call: its imports and every class, function, and method with
+// the line it starts on, nested as in the source. The agent uses it to decide which pages are worth studying,
+// which matters most in languages the Go tools can't see into. Go is read with go/parser; Python, JavaScript,
+// TypeScript, and Rust are matched line by line, since a real grammar for each (tree-sitter) would mean cgo and
+// a dependency per language, and declarations in these languages start lines in recognizable ways.
+const outlineToolDef = `[
+		{"type":"function","function":{"name":"outline_file","description":"Outline a source file (Go, Python, JavaScript, TypeScript, Rust): imports, plus every class, function, and method with its line number, nested as in the file.","parameters":{"type":"object","properties":{
+			"path":{"type":"string","description":"Target file relative to current working directory"} },"required":["path"]}}}
+		]`
+
+// maxOutlineEntries keeps the outline of a generated file from being as long as the file.
+const maxOutlineEntries = 400
+
+var outlineTools = registerToolset(&toolset{
+	def:      outlineToolDef,
+	enabled:  func() bool { return true },
+	readOnly: true,
+	run: map[string]func(context.Context, string) (string, error){
+		"outline_file": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Path string `json:"path"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			return outlineFile(params.Path)
+		},
+	},
+})
+
+// outlineSyntax is how declarations and imports look in one family of languages. A declaration's nesting is
+// its indentation.
+type outlineSyntax struct {
+	decls   []*regexp.Regexp
+	imports *regexp.Regexp // the first group is what is imported
+}
+
+var (
+	jsSyntax = outlineSyntax{
+		decls: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*(export\s+)?(default\s+)?(declare\s+)?(abstract\s+)?(async\s+)?(function\*?|class|interface|type|enum|namespace)\s+[\w$]+`),
+			regexp.MustCompile(`^\s*(export\s+)?(const|let|var)\s+[\w$]+\s*(:[^=]+)?=\s*(async\s+)?(function\b|\([^)]*\)\s*(:[^=]+)?=>|[\w$]+\s*=>)`),
+			// Methods: a name and parameters opening a block, on an indented line.
+			regexp.MustCompile(`^\s+((public|private|protected|static|async|readonly|override|get|set)\s+)*\*?#?[\w$]+\s*(<[^>]*>)?\([^;]*\)\s*(:\s*[^{;=]+)?\{\s*$`),
+		},
+		imports: regexp.MustCompile(`^\s*(?:import\s[^'"]*?(?:from\s+)?|(?:const|let|var)\s.*=\s*require\()['"]([^'"]+)['"]`),
+	}
+	outlineSyntaxes = map[string]outlineSyntax{
+		".py": {
+			decls:   []*regexp.Regexp{regexp.MustCompile(`^\s*(async\s+def|def|class)\s+\w+`)},
+			imports: regexp.MustCompile(`^(?:from\s+(\S+)\s+import|import\s+(.+))`),
+		},
+		".rs": {
+			decls:   []*regexp.Regexp{regexp.MustCompile(`^\s*(pub(\([\w:]+\))?\s+)?(default\s+)?(async\s+|unsafe\s+|const\s+|extern\s+"\w+"\s+)*(fn|struct|enum|trait|impl|mod|type|union|macro_rules!)[\s<!]`)},
+			imports: regexp.MustCompile(`^\s*(?:pub(?:\([\w:]+\))?\s+)?use\s+([^;]+);`),
+		},
+		".js": jsSyntax, ".jsx": jsSyntax, ".mjs": jsSyntax, ".cjs": jsSyntax, ".ts": jsSyntax, ".tsx": jsSyntax, ".mts": jsSyntax,
+	}
+	// controlFlow rules out blocks that look like methods to the pattern above.
+	controlFlow = regexp.MustCompile(`^\s*(if|for|while|switch|catch|with|return|function)\b`)
+)
+
+func outlineFile(path string) (string, error) {
+	if !filepath.IsLocal(path) {
+		return "", fmt.Errorf("Permanent Error: Path %s is outside of current working directory", path)
+	}
+	src, err := workspace.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Error reading file: %v", err)
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	var imports, entries []string
+	if ext == ".go" {
+		imports, entries, err = outlineGo(path, src)
+		if err != nil {
+			return "", fmt.Errorf("Error parsing %s: %v", toolPath(path), err)
+		}
+	} else if syntax, ok := outlineSyntaxes[ext]; ok {
+		imports, entries = outlineLines(syntax, string(src))
+	} else {
+		return "", fmt.Errorf("Permanent Error: outline_file supports Go, Python, JavaScript, TypeScript, and Rust, not %s; use study_file_contents", toolPath(path))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "outline_file %s results (%d lines)\n", toolPath(path), strings.Count(string(src), "\n")+1)
+	if len(imports) > 0 {
+		fmt.Fprintf(&b, "Imports: %s\n", strings.Join(imports, ", "))
+	}
+	if len(entries) == 0 {
+		b.WriteString("No declarations found.\n")
+	}
+	for _, e := range entries[:min(len(entries), maxOutlineEntries)] {
+		b.WriteString(e + "\n")
+	}
+	if len(entries) > maxOutlineEntries {
+		fmt.Fprintf(&b, "(%d more declarations)\n", len(entries)-maxOutlineEntries)
+	}
+	return b.String(), nil
+}
+
+// outlineLines matches the declarations of a file line by line, indenting each by its nesting in the source.
+func outlineLines(syntax outlineSyntax, src string) (imports, entries []string) {
+	lines := strings.Split(src, "\n")
+	indents := []int{} // indentation of the enclosing declarations
+	for i, line := range lines {
+		if m := syntax.imports.FindStringSubmatch(line); m != nil {
+			for _, group := range m[1:] {
+				if group != "" {
+					imports = append(imports, strings.TrimSpace(group))
+					break
+				}
+			}
+			continue
+		}
+		matched := false
+		for _, decl := range syntax.decls {
+			matched = matched || decl.MatchString(line)
+		}
+		if !matched || controlFlow.MatchString(line) {
+			continue
+		}
+		indent := len(strings.ReplaceAll(line, "\t", "    ")) - len(strings.TrimLeft(strings.ReplaceAll(line, "\t", "    "), " "))
+		for len(indents) > 0 && indents[len(indents)-1] >= indent {
+			indents = indents[:len(indents)-1]
+		}
+		signature := strings.TrimRight(strings.TrimSpace(line), "{: ")
+		entries = append(entries, fmt.Sprintf("%5d  %s%s", i+1, strings.Repeat("  ", len(indents)), clip(signature)))
+		indents = append(indents, indent)
+	}
+	return imports, entries
+}
+
+// outlineGo lists a Go file's imports, types with their methods listed after them, functions, and the names in
+// its const and var blocks.
+func outlineGo(path string, src []byte) (imports, entries []string, err error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, spec := range f.Imports {
+		imports = append(imports, strings.Trim(spec.Path.Value, `"`))
+	}
+	line := func(n ast.Node) int { return fset.Position(n.Pos()).Line }
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			signature := &ast.FuncDecl{Recv: d.Recv, Name: d.Name, Type: d.Type} // without the body
+			var b strings.Builder
+			printer.Fprint(&b, fset, signature)
+			entries = append(entries, fmt.Sprintf("%5d  %s", line(d), clip(b.String())))
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					kind := "type"
+					switch s.Type.(type) {
+					case *ast.StructType:
+						kind = "struct"
+					case *ast.InterfaceType:
+						kind = "interface"
+					}
+					entries = append(entries, fmt.Sprintf("%5d  %s %s", line(s), kind, s.Name.Name))
+				case *ast.ValueSpec:
+					var names []string
+					for _, n := range s.Names {
+						names = append(names, n.Name)
+					}
+					entries = append(entries, fmt.Sprintf("%5d  %s %s", line(s), d.Tok, strings.Join(names, ", ")))
+				}
+			}
+		}
+	}
+	return imports, entries, nil
+}
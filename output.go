@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// --output keeps every final answer in a Markdown file as well, so results outlive the terminal's scrollback.
+// Each mission appends a section with the mission text, when it finished, and what it cost.
+var outputFile = flag.String("output", "", "Append each mission's final answer, with its mission, time, and cost, to this Markdown file")
+
+// missionStart is when the current mission began and what the session had cost by then.
+var missionStart struct {
+	at   time.Time
+	cost float64
+}
+
+// beginMission marks the start of a mission for its --output entry.
+func beginMission() {
+	missionStart.at, missionStart.cost = time.Now(), sessionTotal().Cost
+}
+
+// appendOutput adds one mission's answer to the output file; failing to write is reported but never ends the session.
+func appendOutput(mission, answer string) {
+	if *outputFile == "" {
+		return
+	}
+	now := time.Now()
+	title, _, _ := strings.Cut(mission, "\n")
+	entry := fmt.Sprintf("## %s\n\n> %s\n\n%s\n\n_%s · %s · %.1fs · %.2fc_\n\n",
+		clip(title), quoteLines(mission), answer, now.Format("2006-01-02 15:04:05"), *model,
+		now.Sub(missionStart.at).Seconds(), (sessionTotal().Cost-missionStart.cost)*100)
+	file, err := os.OpenFile(*outputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err == nil {
+		_, err = file.WriteString(entry)
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		event(slog.LevelWarn, fmt.Sprintf("\033[33mCould not write %s: %v\033[0m\n", *outputFile, err), "output write failed", "path", *outputFile, "err", err)
+		return
+	}
+	event(slog.LevelDebug, fmt.Sprintf("\033[90mAnswer appended to %s\033[0m\n", *outputFile), "output written", "path", *outputFile)
+}
+
+// quoteLines continues a Markdown blockquote across every line of a multi-line mission.
+func quoteLines(s string) string {
+	return strings.ReplaceAll(s, "\n", "\n> ")
+}
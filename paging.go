@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// pageLines sizes study_file_contents pages. Whole lines keep statements and UTF-8 runes intact, which
+// byte-sized pages used to cut in half and confuse the summarizer with. Large-context models take far bigger
+// pages comfortably, halving the round trips, so the size is a flag and the model may pick one per call.
+var pageLines = flag.Int("page-lines", 80, "Lines per study_file_contents page; a call's page_lines overrides it")
+
+// maxPageLines bounds a page whatever was asked for, since the whole page goes into one summarization prompt.
+const maxPageLines = 2000
+
+// pageSize resolves the lines per page for a call, where 0 means the --page-lines default.
+func pageSize(requested int) int {
+	if requested <= 0 {
+		requested = *pageLines
+	}
+	return min(max(requested, 10), maxPageLines)
+}
+
+// maxLineBytes caps a single line, so one minified bundle can't fill the whole prompt on its own.
+const maxLineBytes = 2000
+
+// maxChunkedSize bounds the files loaded whole for syntax-aware chunking; bigger files stream in fixed pages.
+const maxChunkedSize = 4 << 20
+
+// page is one line-numbered slice of a file.
+type page struct {
+	Text        string
+	First, Last int // 1-based line numbers covered
+	Total       int // total pages in the file
+}
+
+// readPage returns the n-th (0-based) page of r, size lines long, with each line prefixed by its number. Recognized source files
+// are paged along declaration boundaries so a question about "the retry logic" sees whole functions; anything
+// else is streamed in fixed-size pages.
+func readPage(r io.Reader, path string, n, size int) (page, error) {
+	src, err := io.ReadAll(io.LimitReader(r, maxChunkedSize+1))
+	if err != nil {
+		return page{}, err
+	}
+	if len(src) > maxChunkedSize {
+		return readFixedPage(io.MultiReader(bytes.NewReader(src), r), n, size)
+	}
+	lines := strings.SplitAfter(string(src), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	starts := declStarts(path, src, lines)
+	if starts == nil {
+		return readFixedPage(bytes.NewReader(src), n, size)
+	}
+
+	pages := packPages(starts, len(lines), size)
+	p := page{Total: len(pages)}
+	if n < 0 || n >= len(pages) {
+		return p, nil
+	}
+	p.First, p.Last = pages[n], len(lines)
+	if n+1 < len(pages) {
+		p.Last = pages[n+1] - 1
+	}
+	var b strings.Builder
+	for i := p.First; i <= p.Last; i++ {
+		writeLine(&b, i, lines[i-1])
+	}
+	p.Text = b.String()
+	return p, nil
+}
+
+// readFixedPage streams r and returns the n-th page of size lines, counting the remaining lines so the
+// model learns how many pages exist.
+func readFixedPage(r io.Reader, n, size int) (page, error) {
+	var b strings.Builder
+	p := page{}
+	br := bufio.NewReader(r)
+	line := 0
+	for {
+		text, err := br.ReadString('\n')
+		if text != "" {
+			line++
+			if (line-1)/size == n {
+				if p.First == 0 {
+					p.First = line
+				}
+				p.Last = line
+				writeLine(&b, line, text)
+			}
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return p, err
+		}
+	}
+	p.Text = b.String()
+	p.Total = (line + size - 1) / size
+	return p, nil
+}
+
+// writeLine appends one numbered line, always as valid UTF-8: the type check only samples a file's header, so a
+// Latin-1 byte deep inside becomes U+FFFD, and a minified line too long for any page is cut on a rune boundary.
+func writeLine(b *strings.Builder, n int, text string) {
+	text = strings.ToValidUTF8(strings.TrimSuffix(text, "\n"), "\uFFFD")
+	if len(text) > maxLineBytes {
+		cut := maxLineBytes
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = fmt.Sprintf("%s… [%d more bytes on this line]", text[:cut], len(text)-cut)
+	}
+	fmt.Fprintf(b, "%5d| %s\n", n, text)
+}
+
+// packPages greedily groups the segments between declaration starts into pages of at most size lines,
+// only cutting inside a declaration when it alone is longer than a page. It returns each page's first line.
+func packPages(starts []int, total, size int) []int {
+	starts = append(starts, total+1)
+	slices.Sort(starts)
+	starts = slices.Compact(starts)
+	var pages []int
+	first := 1
+	for i := 0; i < len(starts); i++ {
+		end := starts[i] // exclusive end of the segment that starts after `first`
+		if end <= first {
+			continue
+		}
+		if end-first > size {
+			// The pending page plus this segment overflow: close the pending page at the previous boundary if
+			// there is one, then split any oversized remainder at fixed intervals.
+			if i > 0 && starts[i-1] > first {
+				pages = append(pages, first)
+				first = starts[i-1]
+			}
+			for end-first > size {
+				pages = append(pages, first)
+				first += size
+			}
+		}
+	}
+	if first <= total {
+		pages = append(pages, first)
+	}
+	return pages
+}
+
+// declPatterns recognize top-level declarations for languages without a parser in the standard library.
+var declPatterns = map[string]*regexp.Regexp{
+	".py":  regexp.MustCompile(`^(async\s+def|def|class)\s`),
+	".rb":  regexp.MustCompile(`^(def|class|module)\s`),
+	".rs":  regexp.MustCompile(`^(pub(\([\w:]+\))?\s+)?(async\s+|unsafe\s+|const\s+)*(fn|struct|enum|trait|impl|mod|type|const|static|union|macro_rules!)[\s<!]`),
+	".js":  regexp.MustCompile(`^(export\s+)?(default\s+)?(async\s+)?(function\*?|class|const|let|var)\s`),
+	".ts":  regexp.MustCompile(`^(export\s+)?(default\s+)?(declare\s+)?(abstract\s+)?(async\s+)?(function\*?|class|interface|type|enum|namespace|const|let|var)\s`),
+	".php": regexp.MustCompile(`^(abstract\s+|final\s+)?(function|class|interface|trait|enum)\s`),
+}
+
+// docLine matches comment and annotation lines that belong to the declaration below them.
+var docLine = regexp.MustCompile(`^\s*(//|#|/\*|\*|@|--|"""|''')`)
+
+// declStarts returns the 1-based lines where top-level declarations begin, including their doc comments,
+// or nil when the file's language isn't recognized.
+func declStarts(path string, src []byte, lines []string) []int {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".go":
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+		if err != nil {
+			return nil
+		}
+		starts := []int{1}
+		for _, d := range f.Decls {
+			pos := d.Pos()
+			switch d := d.(type) {
+			case *ast.FuncDecl:
+				if d.Doc != nil {
+					pos = d.Doc.Pos()
+				}
+			case *ast.GenDecl:
+				if d.Doc != nil {
+					pos = d.Doc.Pos()
+				}
+			}
+			starts = append(starts, fset.Position(pos).Line)
+		}
+		return starts
+	case ".jsx", ".mjs", ".cjs":
+		ext = ".js"
+	case ".tsx", ".mts":
+		ext = ".ts"
+	}
+
+	pattern := declPatterns[ext]
+	if pattern == nil {
+		return nil
+	}
+	starts := []int{1}
+	for i, line := range lines {
+		if !pattern.MatchString(line) {
+			continue
+		}
+		start := i
+		for start > 0 && strings.TrimSpace(lines[start-1]) != "" && docLine.MatchString(lines[start-1]) {
+			start--
+		}
+		starts = append(starts, start+1)
+	}
+	return starts
+}
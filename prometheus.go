@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The prometheus tools ground a performance investigation in the real metrics: an instant query for the value
+// now, a range query for how it moved, and the metric names to query. Results are compacted for the model, one
+// line per series, and a range series becomes its minimum, mean, maximum, and last value with a few samples
+// rather than every point. PROMETHEUS_TOKEN, when set, is sent as a bearer token.
+var prometheusURL = flag.String("prometheus", os.Getenv("PROMETHEUS_URL"), "Prometheus base URL for the prom tools, e.g. http://localhost:9090")
+
+const promToolDef = `[
+		{"type":"function","function":{"name":"prom_query","description":"Run a PromQL instant query and list each series with its current value.","parameters":{"type":"object","properties":{
+			"query":{"type":"string","description":"PromQL expression, such as rate(http_requests_total[5m])"},
+			"time":{"type":"string","description":"Optional evaluation time, RFC 3339 or Unix seconds, defaults to now"} },"required":["query"]}}},
+		{"type":"function","function":{"name":"prom_query_range","description":"Run a PromQL range query and summarize each series over the window: min, mean, max, last, and a few samples.","parameters":{"type":"object","properties":{
+			"query":{"type":"string","description":"PromQL expression"},
+			"range":{"type":"string","default":"1h","description":"How far back from end, as a duration like 30m or 24h"},
+			"end":{"type":"string","description":"Optional end of the window, RFC 3339 or Unix seconds, defaults to now"},
+			"step":{"type":"string","description":"Optional resolution, as a duration like 15s, defaults to a sixtieth of the range"} },"required":["query"]}}},
+		{"type":"function","function":{"name":"prom_metrics","description":"List the metric names Prometheus knows, to find what to query.","parameters":{"type":"object","properties":{
+			"filter":{"type":"string","description":"Optional text the names must contain, such as http_"} }}}}
+		]`
+
+const (
+	maxPromSeries  = 50
+	maxPromMetrics = 500
+	promSamples    = 8 // samples shown per range series, spread evenly across it
+)
+
+type promSeries struct {
+	Metric map[string]string `json:"metric"`
+	Value  [2]any            `json:"value"`
+	Values [][2]any          `json:"values"`
+}
+
+var promTools = registerToolset(&toolset{
+	def:      promToolDef,
+	enabled:  func() bool { return *prometheusURL != "" },
+	readOnly: true,
+	run: map[string]func(context.Context, string) (string, error){
+		"prom_query": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Query string `json:"query"`
+				Time  string `json:"time"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			return promQuery(ctx, params.Query, params.Time)
+		},
+		"prom_query_range": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Query string `json:"query"`
+				Range string `json:"range"`
+				End   string `json:"end"`
+				Step  string `json:"step"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			return promQueryRange(ctx, params.Query, params.Range, params.End, params.Step)
+		},
+		"prom_metrics": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Filter string `json:"filter"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			return promMetrics(ctx, params.Filter)
+		},
+	},
+})
+
+// promGet calls a Prometheus HTTP API endpoint and decodes its data field into v.
+func promGet(ctx context.Context, path string, query url.Values, v any) error {
+	header := http.Header{"Accept": {"application/json"}}
+	if token := os.Getenv("PROMETHEUS_TOKEN"); token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	var resp struct {
+		Status   string          `json:"status"`
+		Error    string          `json:"error"`
+		Data     json.RawMessage `json:"data"`
+		Warnings []string        `json:"warnings"`
+	}
+	if err := getJSON(ctx, strings.TrimSuffix(*prometheusURL, "/")+path+"?"+query.Encode(), header, &resp); err != nil {
+		return err
+	}
+	if resp.Status != "success" {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	return json.Unmarshal(resp.Data, v)
+}
+
+// promTime accepts what the Prometheus API does, RFC 3339 or Unix seconds, and "" for now.
+func promTime(s string) (time.Time, error) {
+	if s == "" || s == "now" {
+		return time.Now(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	secs, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Permanent Error: time %q is neither RFC 3339 nor Unix seconds", s)
+	}
+	return time.Unix(0, int64(secs*1e9)), nil
+}
+
+func promQuery(ctx context.Context, query, at string) (string, error) {
+	if strings.TrimSpace(query) == "" {
+		return "", fmt.Errorf("Permanent Error: query is required")
+	}
+	t, err := promTime(at)
+	if err != nil {
+		return "", err
+	}
+	var data struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	}
+	if err := promGet(ctx, "/api/v1/query", url.Values{"query": {query}, "time": {promUnix(t)}}, &data); err != nil {
+		return "", fmt.Errorf("Error querying Prometheus: %v", err)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "prom_query %s results\n", query)
+	switch data.ResultType {
+	case "scalar", "string":
+		var value [2]any
+		json.Unmarshal(data.Result, &value)
+		fmt.Fprintf(&b, "%s: %v\n", data.ResultType, value[1])
+		return b.String(), nil
+	}
+	var series []promSeries
+	json.Unmarshal(data.Result, &series)
+	if len(series) == 0 {
+		return b.String() + "(no series)\n", nil
+	}
+	// Highest first, since an investigation usually wants the worst offenders.
+	sort.SliceStable(series, func(i, j int) bool { return promValue(series[i].Value) > promValue(series[j].Value) })
+	for i, s := range series {
+		if i == maxPromSeries {
+			fmt.Fprintf(&b, "(%d more series, aggregate with sum by or topk to narrow them)\n", len(series)-i)
+			break
+		}
+		fmt.Fprintf(&b, "%s %s\n", promLabels(s.Metric), promFormat(promValue(s.Value)))
+	}
+	return b.String(), nil
+}
+
+func promQueryRange(ctx context.Context, query, window, at, step string) (string, error) {
+	if strings.TrimSpace(query) == "" {
+		return "", fmt.Errorf("Permanent Error: query is required")
+	}
+	if window == "" {
+		window = "1h"
+	}
+	span, err := time.ParseDuration(window)
+	if err != nil || span <= 0 {
+		return "", fmt.Errorf("Permanent Error: range %q is not a duration like 30m or 24h", window)
+	}
+	end, err := promTime(at)
+	if err != nil {
+		return "", err
+	}
+	resolution := max(span/60, time.Second)
+	if step != "" {
+		if resolution, err = time.ParseDuration(step); err != nil || resolution <= 0 {
+			return "", fmt.Errorf("Permanent Error: step %q is not a duration like 15s", step)
+		}
+	}
+	// Prometheus refuses more than 11,000 points per series, and far fewer are enough for a summary.
+	resolution = max(resolution, span/1000)
+	var data struct {
+		Result []promSeries `json:"result"`
+	}
+	values := url.Values{"query": {query}, "start": {promUnix(end.Add(-span))}, "end": {promUnix(end)}, "step": {strconv.FormatFloat(resolution.Seconds(), 'f', -1, 64)}}
+	if err := promGet(ctx, "/api/v1/query_range", values, &data); err != nil {
+		return "", fmt.Errorf("Error querying Prometheus: %v", err)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "prom_query_range %s results, %s to %s every %s\n", query, end.Add(-span).UTC().Format(time.DateTime), end.UTC().Format(time.DateTime), resolution)
+	if len(data.Result) == 0 {
+		return b.String() + "(no series)\n", nil
+	}
+	for i, s := range data.Result {
+		if i == maxPromSeries {
+			fmt.Fprintf(&b, "(%d more series, aggregate with sum by or topk to narrow them)\n", len(data.Result)-i)
+			break
+		}
+		b.WriteString(promSummary(s))
+	}
+	return b.String(), nil
+}
+
+// promSummary condenses one range series to its statistics and promSamples points spread across it.
+func promSummary(s promSeries) string {
+	lo, hi, sum, n := math.Inf(1), math.Inf(-1), 0.0, 0
+	for _, point := range s.Values {
+		if v := promValue(point); !math.IsNaN(v) {
+			lo, hi, sum, n = min(lo, v), max(hi, v), sum+v, n+1
+		}
+	}
+	if n == 0 {
+		return fmt.Sprintf("%s: %d points, none numeric\n", promLabels(s.Metric), len(s.Values))
+	}
+	var samples []string
+	for i, last := 0, -1; i < promSamples; i++ {
+		at := i * (len(s.Values) - 1) / (promSamples - 1)
+		if at == last {
+			continue
+		}
+		last = at
+		point := s.Values[at]
+		ts, _ := point[0].(float64)
+		samples = append(samples, time.Unix(int64(ts), 0).UTC().Format("15:04")+"="+promFormat(promValue(point)))
+	}
+	return fmt.Sprintf("%s: min %s, mean %s, max %s, last %s over %d points\n  %s\n", promLabels(s.Metric),
+		promFormat(lo), promFormat(sum/float64(n)), promFormat(hi), promFormat(promValue(s.Values[len(s.Values)-1])), len(s.Values), strings.Join(samples, " "))
+}
+
+func promMetrics(ctx context.Context, filter string) (string, error) {
+	var names []string
+	if err := promGet(ctx, "/api/v1/label/__name__/values", url.Values{}, &names); err != nil {
+		return "", fmt.Errorf("Error listing metrics: %v", err)
+	}
+	var matched []string
+	for _, name := range names {
+		if strings.Contains(name, filter) {
+			matched = append(matched, name)
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "prom_metrics results (%d metrics)\n", len(matched))
+	for i, name := range matched {
+		if i == maxPromMetrics {
+			fmt.Fprintf(&b, "(%d more metrics, pass a filter to narrow the list)\n", len(matched)-i)
+			break
+		}
+		b.WriteString(name + "\n")
+	}
+	return b.String(), nil
+}
+
+// promValue reads a sample, a [timestamp, "value"] pair; values come as strings so NaN and Inf survive JSON.
+func promValue(point [2]any) float64 {
+	s, _ := point[1].(string)
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return v
+}
+
+func promFormat(v float64) string {
+	return strconv.FormatFloat(v, 'g', 4, 64)
+}
+
+func promUnix(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', -1, 64)
+}
+
+// promLabels writes a series as PromQL would select it, name first and labels sorted.
+func promLabels(metric map[string]string) string {
+	var labels []string
+	for k, v := range metric {
+		if k != "__name__" {
+			labels = append(labels, fmt.Sprintf("%s=%q", k, v))
+		}
+	}
+	sort.Strings(labels)
+	if len(labels) == 0 && metric["__name__"] != "" {
+		return metric["__name__"]
+	}
+	return metric["__name__"] + "{" + strings.Join(labels, ", ") + "}"
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Many small local models never emit tool_calls, however the tools are offered. With --react the tools are
+// described in the system prompt instead, and the model asks for one in plain text, ReAct style:
+//
+//	Thought: I should look at the entry point
+//	Action: study_file_contents
+//	Action Input: {"path": "main.go", "question": "what does main do?"}
+//
+// The reply is parsed into ordinary tool calls, and each result goes back as an Observation. Like the legacy
+// function format, it is a translation at the request boundary, so the rest of the agent is unchanged.
+var reactMode = flag.Bool("react", false, "Describe tools in the prompt and parse Action/Action Input replies, for models without native tool calling")
+
+const reactInstructions = `
+
+You can use these tools:
+%s
+To use a tool, reply in exactly this format, then stop and wait for the result:
+Thought: what you need to find out next
+Action: the tool name
+Action Input: the input as a JSON object
+
+The result comes back as:
+Observation: the tool result
+
+Use as many tools as you need, one after another. When you know the answer, reply:
+Thought: I know the answer
+Final Answer: your answer`
+
+var (
+	reactAction = regexp.MustCompile(`(?m)^[ \t*]*Action[ \t*]*:[ \t*]*` + "`?" + `([\w.-]+)` + "`?" + `[ \t*]*\n[ \t*]*Action[ \t]*Input[ \t*]*:[ \t]*`)
+	reactFinal  = regexp.MustCompile(`(?m)^[ \t*]*Final[ \t]*Answer[ \t*]*:[ \t]*`)
+	reactLabel  = regexp.MustCompile(`(?m)^[ \t*]*Thought[ \t*]*:[ \t]*`)
+	reactFence  = regexp.MustCompile("^```\\w*\\s*|\\s*```$")
+)
+
+// reactCallCount numbers the calls parsed from text, which have no ID of their own.
+var reactCallCount atomic.Int64
+
+// reactToolList describes each tool in the words a small model follows more reliably than JSON schema.
+func reactToolList(tools []byte) string {
+	var defs []struct {
+		Function struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+			Parameters  struct {
+				Properties map[string]struct {
+					Type        string `json:"type"`
+					Description string `json:"description"`
+					Default     any    `json:"default"`
+				} `json:"properties"`
+				Required []string `json:"required"`
+			} `json:"parameters"`
+		} `json:"function"`
+	}
+	json.Unmarshal(tools, &defs)
+	var b strings.Builder
+	for _, d := range defs {
+		fmt.Fprintf(&b, "\n%s: %s\n", d.Function.Name, d.Function.Description)
+		var names []string
+		for name := range d.Function.Parameters.Properties {
+			names = append(names, name)
+		}
+		required := func(name string) bool { return slices.Contains(d.Function.Parameters.Required, name) }
+		sort.Slice(names, func(i, j int) bool {
+			if required(names[i]) != required(names[j]) {
+				return required(names[i])
+			}
+			return names[i] < names[j]
+		})
+		for _, name := range names {
+			p := d.Function.Parameters.Properties[name]
+			note := p.Type
+			if required(name) {
+				note += ", required"
+			} else if p.Default != nil {
+				note += fmt.Sprintf(", default %v", p.Default)
+			}
+			fmt.Fprintf(&b, "  - %s (%s): %s\n", name, note, p.Description)
+		}
+	}
+	return b.String()
+}
+
+// reactMessages translates a conversation for a model working in text: the tools join the system prompt, calls
+// become Action blocks, and the results of one turn become one user message of Observations.
+func reactMessages(messages []ChatMessage, tools []byte) []ChatMessage {
+	prompt := fmt.Sprintf(reactInstructions, reactToolList(tools))
+	out := make([]ChatMessage, 0, len(messages)+1)
+	if len(messages) == 0 || messages[0].Role != "system" {
+		out = append(out, ChatMessage{Role: "system", Content: strings.TrimSpace(prompt)})
+	}
+	names := map[string]string{}
+	for i, m := range messages {
+		switch {
+		case i == 0 && m.Role == "system":
+			m.Content += prompt
+			out = append(out, m)
+		case m.Role == "assistant" && len(m.ToolCalls) > 0:
+			var b strings.Builder
+			b.WriteString(m.Content)
+			for _, tc := range m.ToolCalls {
+				names[tc.ID] = tc.Function.Name
+				fmt.Fprintf(&b, "\nAction: %s\nAction Input: %s\n", tc.Function.Name, tc.Function.Arguments)
+			}
+			out = append(out, ChatMessage{Role: "assistant", Content: strings.TrimSpace(b.String())})
+		case m.Role == "tool":
+			observation := fmt.Sprintf("Observation from %s: %s", names[m.ToolCallID], m.Content)
+			if last := len(out) - 1; i > 0 && messages[i-1].Role == "tool" {
+				out[last].Content += "\n\n" + observation
+				continue
+			}
+			out = append(out, ChatMessage{Role: "user", Content: observation})
+		default:
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// parseReAct reads a text reply: its actions become tool calls, a Final Answer becomes the answer, and the
+// reasoning around either is returned as thoughts. A reply in neither format is taken as the answer.
+func parseReAct(content string) (answer string, calls []ToolCall, thoughts string) {
+	// A model that runs on past its action imagines the result; only the real one counts.
+	if cut := strings.Index(content, "\nObservation"); cut >= 0 {
+		content = content[:cut]
+	}
+	actions := reactAction.FindAllStringSubmatchIndex(content, -1)
+	final := reactFinal.FindStringIndex(content)
+	if len(actions) == 0 || (final != nil && final[0] < actions[0][0]) {
+		if final == nil {
+			return strings.TrimSpace(content), nil, ""
+		}
+		return strings.TrimSpace(content[final[1]:]), nil, strings.TrimSpace(reactLabel.ReplaceAllString(content[:final[0]], ""))
+	}
+	for i, at := range actions {
+		end := len(content)
+		if i+1 < len(actions) {
+			end = actions[i+1][0]
+		} else if final != nil && final[0] > at[1] {
+			end = final[0]
+		}
+		tc := ToolCall{ID: fmt.Sprintf("call_react_%d", reactCallCount.Add(1)), Type: "function"}
+		tc.Function.Name, tc.Function.Arguments = content[at[2]:at[3]], reactInput(content[at[1]:end])
+		calls = append(calls, tc)
+	}
+	return "", calls, strings.TrimSpace(reactLabel.ReplaceAllString(content[:actions[0][0]], ""))
+}
+
+// reactInput extracts the JSON object of an Action Input, ignoring a code fence or any text after it. Input that
+// isn't JSON is passed on as is, for argument validation to explain to the model.
+func reactInput(text string) string {
+	text = strings.TrimSpace(reactFence.ReplaceAllString(strings.TrimSpace(text), ""))
+	var raw json.RawMessage
+	if json.NewDecoder(strings.NewReader(text)).Decode(&raw) == nil {
+		return string(raw)
+	}
+	return text
+}
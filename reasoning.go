@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"regexp"
+	"strings"
+)
+
+// Reasoning models disagree on where their thinking goes: Qwen wraps it in <think>, some fine-tunes use
+// <thinking> or <reasoning>, chat templates often drop the opening tag, tool-calling turns interleave several
+// blocks, and DeepSeek-style APIs put it in a separate field. All of it is separated from the content here, so
+// the stored history only ever holds answers and the model is never fed its old reasoning back. With
+// --show-thoughts the reasoning is shown dimmed as each reply arrives, and kept, tagged as such, in saved
+// transcripts and the exported report for debugging a mission, though still never sent.
+var showThoughts = flag.Bool("show-thoughts", false, "Print the model's reasoning before each reply, and keep it in saved transcripts and reports")
+
+var (
+	thoughtBlock = regexp.MustCompile(`(?is)<(think|thinking|reasoning)>(.*?)</(?:think|thinking|reasoning)>`)
+	thoughtOpen  = regexp.MustCompile(`(?is)<(?:think|thinking|reasoning)>`)
+	thoughtClose = regexp.MustCompile(`(?is)</(?:think|thinking|reasoning)>`)
+)
+
+// splitThoughts removes every reasoning block from content and returns the cleaned content and the reasoning,
+// starting with any reasoning the provider returned in a separate field.
+func splitThoughts(content string, fields ...string) (string, string) {
+	var thoughts []string
+	for _, f := range fields {
+		if f = strings.TrimSpace(f); f != "" {
+			thoughts = append(thoughts, f)
+		}
+	}
+
+	// A close tag with no opening tag means the template injected <think> into the prompt: everything before it
+	// is reasoning.
+	if loc := thoughtClose.FindStringIndex(content); loc != nil && !thoughtOpen.MatchString(content[:loc[0]]) {
+		thoughts = append(thoughts, strings.TrimSpace(content[:loc[0]]))
+		content = content[loc[1]:]
+	}
+	content = thoughtBlock.ReplaceAllStringFunc(content, func(block string) string {
+		thoughts = append(thoughts, strings.TrimSpace(thoughtBlock.FindStringSubmatch(block)[2]))
+		return ""
+	})
+	// An opening tag that never closes means the reply was cut off mid-thought.
+	if loc := thoughtOpen.FindStringIndex(content); loc != nil {
+		thoughts = append(thoughts, strings.TrimSpace(content[loc[1]:]))
+		content = content[:loc[0]]
+	}
+	return strings.TrimSpace(content), strings.TrimSpace(strings.Join(thoughts, "\n\n"))
+}
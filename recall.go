@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Missions are kept across sessions like shell history: up and down arrows at the mission prompt step through
+// them, and !! (the last), !N (as numbered by /history), !-N (N back), or !text (the latest starting with text)
+// runs one again without retyping it.
+var historyFile = flag.String("history-file", filepath.Join(".tinyagent", "history.jsonl"), "File that keeps past missions for recall at the prompt (empty disables)")
+
+// maxHistory bounds what is kept; older missions drop off the front when the file is rewritten.
+const maxHistory = 500
+
+var (
+	historyOnce  sync.Once
+	pastMissions []string
+)
+
+// missionHistory returns past missions, oldest first, loading them from disk on first use.
+func missionHistory() []string {
+	historyOnce.Do(func() {
+		if *historyFile == "" {
+			return
+		}
+		file, err := os.Open(*historyFile)
+		if err != nil {
+			return
+		}
+		defer file.Close()
+		s := bufio.NewScanner(file)
+		s.Buffer(make([]byte, 0, 64<<10), maxInputLine)
+		for s.Scan() {
+			var m string
+			if json.Unmarshal(s.Bytes(), &m) == nil && m != "" {
+				pastMissions = append(pastMissions, m)
+			}
+		}
+	})
+	return pastMissions
+}
+
+// recordMission adds a mission to the history, skipping an immediate repeat. Each entry is one JSON string per
+// line, so multi-line missions survive intact.
+func recordMission(m string) {
+	past := missionHistory()
+	if m == "" || (len(past) > 0 && past[len(past)-1] == m) {
+		return
+	}
+	pastMissions = append(past, m)
+	if *historyFile == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(*historyFile), 0o755); err != nil {
+		return
+	}
+	// Missions can quote secrets or internal details, so the file stays out of git like the sessions do.
+	ignore := filepath.Join(filepath.Dir(*historyFile), ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		os.WriteFile(ignore, []byte(filepath.Base(*historyFile)+"\n"), 0o644)
+	}
+	raw, _ := json.Marshal(m)
+	// Trimming well below the limit leaves room to append for a while before the file is rewritten again.
+	if len(pastMissions) > maxHistory {
+		pastMissions = pastMissions[len(pastMissions)-maxHistory*3/4:]
+		var b strings.Builder
+		for _, m := range pastMissions {
+			line, _ := json.Marshal(m)
+			b.Write(append(line, '\n'))
+		}
+		os.WriteFile(*historyFile, []byte(b.String()), 0o600)
+		return
+	}
+	file, err := os.OpenFile(*historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	file.Write(append(raw, '\n'))
+}
+
+// recallMission expands a history reference typed at the prompt. ok is false when input isn't one.
+func recallMission(input string) (mission string, ok bool, err error) {
+	ref := strings.TrimSpace(input)
+	if len(ref) < 2 || ref[0] != '!' || strings.Contains(ref, "\n") {
+		return "", false, nil
+	}
+	past := missionHistory()
+	ref = ref[1:]
+	index := -1
+	switch n, convErr := strconv.Atoi(ref); {
+	case ref == "!":
+		index = len(past) - 1
+	case convErr == nil && n < 0:
+		index = len(past) + n
+	case convErr == nil:
+		index = n - 1
+	default:
+		for i := len(past) - 1; i >= 0; i-- {
+			if strings.HasPrefix(past[i], ref) {
+				return past[i], true, nil
+			}
+		}
+		return "", true, fmt.Errorf("no past mission starts with %q", ref)
+	}
+	if index < 0 || index >= len(past) {
+		return "", true, fmt.Errorf("no mission !%s in history, /history lists them", ref)
+	}
+	return past[index], true, nil
+}
+
+// askLine is ask with shell-style editing and up/down through history, for a terminal that allows switching
+// off line buffering. Everywhere else it is plain ask, and !N recall still works.
+func askLine(pretty string, history []string) (string, bool) {
+	if len(history) == 0 || !colorOn || !isTerminal(os.Stdin) || !cbreak(true) {
+		return ask(pretty)
+	}
+	defer cbreak(false)
+	askMu.Lock()
+	defer askMu.Unlock()
+	termMu.Lock()
+	paused = true
+	termMu.Unlock()
+	defer func() {
+		termMu.Lock()
+		paused, lineStart = false, true
+		termMu.Unlock()
+	}()
+
+	var line []rune
+	pos, index := 0, len(history)
+	draft := ""
+	redraw := func() {
+		termMu.Lock()
+		fmt.Print("\r\033[K" + paint(pretty) + string(line))
+		if back := len(line) - pos; back > 0 {
+			fmt.Printf("\033[%dD", back)
+		}
+		termMu.Unlock()
+	}
+	recall := func(to int) {
+		if to < 0 || to > len(history) {
+			return
+		}
+		if index == len(history) {
+			draft = string(line)
+		}
+		index = to
+		entry := draft
+		if to < len(history) {
+			entry = strings.ReplaceAll(history[to], "\n", " ")
+		}
+		line = []rune(entry)
+		pos = len(line)
+	}
+	redraw()
+
+	for {
+		r, err := readKey()
+		if err != nil {
+			fmt.Print("\n")
+			return string(line), len(line) > 0
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Print("\n")
+			if index < len(history) && string(line) == strings.ReplaceAll(history[index], "\n", " ") {
+				return history[index], true // recalled unchanged, so keep its line breaks
+			}
+			return string(line), true
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Print("\n")
+				return "", false
+			}
+		case 127, 8:
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(line)
+		case 21: // Ctrl-U
+			line, pos = line[pos:], 0
+		case keyUp:
+			recall(index - 1)
+		case keyDown:
+			recall(index + 1)
+		case keyLeft:
+			pos = max(pos-1, 0)
+		case keyRight:
+			pos = min(pos+1, len(line))
+		case keyHome:
+			pos = 0
+		case keyEnd:
+			pos = len(line)
+		case keyUnknown:
+			continue
+		default:
+			if r < ' ' || r == utf8.RuneError {
+				continue
+			}
+			line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+			pos++
+		}
+		redraw()
+	}
+}
+
+// Arrow and navigation keys arrive as escape sequences and are mapped to private-use runes here.
+const (
+	keyUp rune = 0xE000 + iota
+	keyDown
+	keyRight
+	keyLeft
+	keyHome
+	keyEnd
+	keyUnknown
+)
+
+// readByte reads stdin one byte at a time, so nothing typed past Enter is taken from the line scanner.
+func readByte() (byte, error) {
+	var b [1]byte
+	_, err := stdinReader{}.Read(b[:])
+	return b[0], err
+}
+
+func readKey() (rune, error) {
+	b, err := readByte()
+	if err != nil {
+		return 0, err
+	}
+	if b >= utf8.RuneSelf {
+		buf := []byte{b}
+		for !utf8.FullRune(buf) {
+			if b, err = readByte(); err != nil {
+				return 0, err
+			}
+			buf = append(buf, b)
+		}
+		r, _ := utf8.DecodeRune(buf)
+		return r, nil
+	}
+	if b != 0x1b {
+		return rune(b), nil
+	}
+	if b, err = readByte(); err != nil || (b != '[' && b != 'O') {
+		return keyUnknown, err
+	}
+	// The final byte of a CSI sequence is a letter or ~; parameters such as the 1 in ESC[1~ come before it.
+	var params []byte
+	for {
+		if b, err = readByte(); err != nil {
+			return keyUnknown, err
+		}
+		if b < 0x40 || b > 0x7e {
+			params = append(params, b)
+			continue
+		}
+		switch p := string(params); {
+		case b == 'A':
+			return keyUp, nil
+		case b == 'B':
+			return keyDown, nil
+		case b == 'C':
+			return keyRight, nil
+		case b == 'D':
+			return keyLeft, nil
+		case b == 'H' || (b == '~' && (p == "1" || p == "7")):
+			return keyHome, nil
+		case b == 'F' || (b == '~' && (p == "4" || p == "8")):
+			return keyEnd, nil
+		}
+		return keyUnknown, nil
+	}
+}
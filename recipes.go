@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Recipes are named missions with parameters, run as `tinyagent review --target internal/llm`, so common
+// workflows don't mean retyping a long prompt. A few ship built in; .tinyagent/recipes.json in the project or
+// tinyagent/recipes.json in the user config directory adds more or overrides them, the project file winning:
+//
+//	{"triage": {"description": "Explain a failing test", "mission": "Find why {test} fails", "params": {"test": ""}}}
+//
+// A {name} placeholder takes its value from --name, or from params when the flag is left out; an empty default
+// makes the flag required.
+type recipe struct {
+	Description string            `json:"description"`
+	Mission     string            `json:"mission"`
+	Params      map[string]string `json:"params"`
+}
+
+var builtinRecipes = map[string]recipe{
+	"review": {
+		Description: "Review code for bugs, risky patterns, and unclear naming",
+		Mission:     "Review the code in {target}. Report real bugs first with file and line, then risky patterns, then naming or structure that would confuse a new reader. Skip style nits.",
+		Params:      map[string]string{"target": "."},
+	},
+	"explain": {
+		Description: "Explain how a part of the codebase works",
+		Mission:     "Explain how {target} works: its purpose, the main types and functions, how data flows through it, and how it connects to the rest of the codebase.",
+		Params:      map[string]string{"target": "."},
+	},
+	"find-dead-code": {
+		Description: "List functions, types, and files that nothing uses",
+		Mission:     "Find dead code in {target}: functions, types, constants, and files that nothing references. For each, show where it is defined and how you checked it is unused.",
+		Params:      map[string]string{"target": "."},
+	},
+}
+
+var recipePlaceholder = regexp.MustCompile(`\{([\w-]+)\}`)
+
+// loadRecipes merges the built-in recipes with both config files. A file that exists but doesn't parse is an
+// error rather than silently ignored, since the user just ran one of its recipes.
+func loadRecipes() (map[string]recipe, error) {
+	recipes := map[string]recipe{}
+	for name, r := range builtinRecipes {
+		recipes[name] = r
+	}
+	var paths []string
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "tinyagent", "recipes.json"))
+	}
+	paths = append(paths, filepath.Join(".tinyagent", "recipes.json"))
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		var defined map[string]recipe
+		if err := json.Unmarshal(raw, &defined); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		for name, r := range defined {
+			if r.Mission == "" {
+				return nil, fmt.Errorf("%s: recipe %q has no mission", path, name)
+			}
+			recipes[name] = r
+		}
+	}
+	return recipes, nil
+}
+
+// recipeNames lists recipes for completion and usage; a broken config just offers the built-ins.
+func recipeNames() []string {
+	recipes, err := loadRecipes()
+	if err != nil {
+		recipes = builtinRecipes
+	}
+	names := make([]string, 0, len(recipes))
+	for name := range recipes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// expandRecipe fills r's placeholders from args and returns the mission along with the arguments that aren't
+// recipe parameters, which are left for the regular flags.
+func expandRecipe(name string, r recipe, args []string) (string, []string, error) {
+	values := map[string]string{}
+	for k, v := range r.Params {
+		values[k] = v
+	}
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		key, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if _, ok := r.Params[key]; !ok || !strings.HasPrefix(args[i], "-") {
+			rest = append(rest, args[i])
+			continue
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				return "", nil, fmt.Errorf("recipe %s: --%s needs a value", name, key)
+			}
+			i++
+			value = args[i]
+		}
+		values[key] = value
+	}
+
+	var missing []string
+	mission := recipePlaceholder.ReplaceAllStringFunc(r.Mission, func(m string) string {
+		key := m[1 : len(m)-1]
+		v, ok := values[key]
+		if !ok {
+			return m // not a declared parameter, so probably literal braces
+		}
+		if v == "" {
+			missing = append(missing, "--"+key)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", nil, fmt.Errorf("recipe %s needs %s", name, strings.Join(missing, ", "))
+	}
+	return mission, rest, nil
+}
+
+// runRecipe handles `tinyagent <recipe> [--param value]... [flags]`, setting the mission and returning the
+// remaining arguments to parse as flags.
+func runRecipe(args []string) ([]string, error) {
+	recipes, err := loadRecipes()
+	if err != nil {
+		return nil, err
+	}
+	r, ok := recipes[args[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown recipe %q, available recipes are: %s", args[0], strings.Join(recipeNames(), ", "))
+	}
+	expanded, rest, err := expandRecipe(args[0], r, args[1:])
+	if err != nil {
+		return nil, err
+	}
+	*mission = expanded
+	return rest, nil
+}
+
+// listRecipes prints each recipe with its parameters for `tinyagent recipes`.
+func listRecipes() int {
+	recipes, err := loadRecipes()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	for _, name := range recipeNames() {
+		r := recipes[name]
+		var params []string
+		for k, v := range r.Params {
+			if v == "" {
+				params = append(params, fmt.Sprintf("--%s <value>", k))
+			} else {
+				params = append(params, fmt.Sprintf("[--%s %s]", k, v))
+			}
+		}
+		sort.Strings(params)
+		fmt.Printf("%-16s %s\n%-16s %s\n", name, r.Description, "", strings.Join(params, " "))
+	}
+	return 0
+}
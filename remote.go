@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --remote points the file tools, and the commands tools run, at a directory on another machine over ssh,
+// while the model is still called from here: for code that only exists on a server. It uses the ssh client
+// rather than a Go implementation, so ~/.ssh/config, agents, and jump hosts all work as they do in a shell,
+// and one multiplexed connection keeps the many small operations of a mission fast. The remote side needs only
+// a POSIX shell with cat, ls, and stat.
+var remote = flag.String("remote", "", "Work on user@host:dir over ssh instead of the working directory (dir defaults to the remote home)")
+
+// sshFS is a directory on a remote host.
+type sshFS struct {
+	host, dir string
+}
+
+// connectRemote switches the workspace to --remote, once the connection works. The first connection may ask
+// for a password or a host key on the terminal; later ones reuse it.
+func connectRemote() error {
+	if *remote == "" {
+		return nil
+	}
+	host, dir, _ := strings.Cut(*remote, ":")
+	if dir == "" {
+		dir = "."
+	}
+	remoteFS := &sshFS{host: host, dir: dir}
+	cmd := remoteFS.command(context.Background(), "true")
+	cmd.Stdin, cmd.Stderr = os.Stdin, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("--remote %s: cannot work in %s on %s: %v", *remote, dir, host, err)
+	}
+	workspace = remoteFS
+	event(slog.LevelInfo, fmt.Sprintf("\033[90m🔌 Working in %s on %s\033[0m\n", dir, host), "remote workspace", "host", host, "dir", dir)
+	return nil
+}
+
+// workCommand runs a tool's command in the workspace: here, or in the remote directory with --remote.
+func workCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	if r, ok := workspace.(*sshFS); ok {
+		quoted := []string{shellQuote(name)}
+		for _, arg := range args {
+			quoted = append(quoted, shellQuote(arg))
+		}
+		return r.command(ctx, strings.Join(quoted, " "))
+	}
+	return exec.CommandContext(ctx, name, args...)
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// command runs script with the shell on the remote host, in the remote directory. The control socket lets every
+// call after the first skip the handshake.
+func (r *sshFS) command(ctx context.Context, script string) *exec.Cmd {
+	socket := filepath.Join(os.TempDir(), "tinyagent-ssh-%C")
+	return exec.CommandContext(ctx, "ssh", "-o", "ControlMaster=auto", "-o", "ControlPath="+socket, "-o", "ControlPersist=60",
+		r.host, "cd "+shellQuote(r.dir)+" && "+script)
+}
+
+// mustExist starts the scripts that read, exiting with errRemoteMissing for a name that doesn't exist, which run
+// reports as fs.ErrNotExist so callers can tell a new file from a broken connection.
+const (
+	mustExist        = "[ -e NAME ] || exit 3; "
+	errRemoteMissing = 3
+)
+
+// run runs script, with NAME standing for the quoted name, and returns its output.
+func (r *sshFS) run(op, name, script string, stdin []byte) ([]byte, error) {
+	cmd := r.command(context.Background(), strings.ReplaceAll(script, "NAME", shellQuote(filepath.ToSlash(name))))
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	var exit *exec.ExitError
+	switch {
+	case errors.As(err, &exit) && exit.ExitCode() == errRemoteMissing:
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	case err != nil:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = errors.New(msg)
+		}
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return out, nil
+}
+
+func (r *sshFS) ReadFile(name string) ([]byte, error) {
+	return r.run("read", name, mustExist+"cat -- NAME", nil)
+}
+
+func (r *sshFS) Stat(name string) (fs.FileInfo, error) {
+	// GNU stat first, then the BSD flags macOS and the BSDs use.
+	out, err := r.run("stat", name, mustExist+"stat -L -c '%F|%s|%Y|%a' -- NAME 2>/dev/null || stat -L -f '%HT|%z|%m|%Lp' NAME", nil)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Split(strings.TrimSpace(string(out)), "|")
+	if len(fields) != 4 {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fmt.Errorf("unexpected stat output %q", out)}
+	}
+	size, _ := strconv.ParseInt(fields[1], 10, 64)
+	secs, _ := strconv.ParseInt(fields[2], 10, 64)
+	perm, _ := strconv.ParseUint(fields[3], 8, 32)
+	mode := fs.FileMode(perm) & fs.ModePerm
+	switch kind := strings.ToLower(fields[0]); {
+	case strings.Contains(kind, "directory"):
+		mode |= fs.ModeDir
+	case !strings.Contains(kind, "regular"):
+		mode |= fs.ModeIrregular
+	}
+	return remoteInfo{name: path.Base(filepath.ToSlash(name)), size: size, mode: mode, modTime: time.Unix(secs, 0)}, nil
+}
+
+func (r *sshFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	out, err := r.run("readdir", name, mustExist+"ls -1Ap -- NAME", nil)
+	if err != nil {
+		return nil, err
+	}
+	var entries []fs.DirEntry
+	for _, line := range strings.Split(strings.TrimSuffix(string(out), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		child, isDir := strings.CutSuffix(line, "/")
+		entries = append(entries, remoteEntry{r: r, path: path.Join(filepath.ToSlash(name), child), isDir: isDir})
+	}
+	return entries, nil
+}
+
+// Open reads the whole file, since the tools read files whole or page through them with ReadAt.
+func (r *sshFS) Open(name string) (fs.File, error) {
+	info, err := r.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	var content []byte
+	if !info.IsDir() {
+		if content, err = r.ReadFile(name); err != nil {
+			return nil, err
+		}
+	}
+	return &remoteFile{Reader: bytes.NewReader(content), info: info}, nil
+}
+
+func (r *sshFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	_, err := r.run("write", name, fmt.Sprintf("cat > NAME && chmod %o NAME", perm.Perm()), data)
+	return err
+}
+
+func (r *sshFS) MkdirAll(name string, perm fs.FileMode) error {
+	_, err := r.run("mkdir", name, fmt.Sprintf("mkdir -p -m %o -- NAME", perm.Perm()), nil)
+	return err
+}
+
+type remoteFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *remoteFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *remoteFile) Close() error               { return nil }
+
+type remoteInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i remoteInfo) Name() string       { return i.name }
+func (i remoteInfo) Size() int64        { return i.size }
+func (i remoteInfo) Mode() fs.FileMode  { return i.mode }
+func (i remoteInfo) ModTime() time.Time { return i.modTime }
+func (i remoteInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i remoteInfo) Sys() any           { return nil }
+
+// remoteEntry is a listed child, which costs another round trip only when its details are asked for.
+type remoteEntry struct {
+	r     *sshFS
+	path  string
+	isDir bool
+}
+
+func (e remoteEntry) Name() string               { return path.Base(e.path) }
+func (e remoteEntry) IsDir() bool                { return e.isDir }
+func (e remoteEntry) Info() (fs.FileInfo, error) { return e.r.Stat(e.path) }
+func (e remoteEntry) Type() fs.FileMode {
+	if e.isDir {
+		return fs.ModeDir
+	}
+	return 0
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Small local models that lose track of a mission tend to repeat the exact same tool call forever, burning time
+// and context on answers they already have. Consecutive identical calls first get a corrective nudge, and if
+// the model keeps going the mission is abandoned.
+const (
+	repeatNudgeAt = 3
+	repeatAbortAt = 5
+
+	repeatNudge = "You have called %s with the same arguments %d turns in a row and got the same result each time. " +
+		"Do not repeat it. Use the results you already have, try a different tool or path, or give your final answer."
+)
+
+type repeatTracker struct {
+	last  string
+	count int
+}
+
+// check records one turn's tool calls and returns how many turns in a row made exactly the same calls, along
+// with the tool names for the message. A turn with several calls only counts as a repeat if all of them repeat.
+func (r *repeatTracker) check(calls []ToolCall) (int, string) {
+	if len(calls) == 0 {
+		r.last, r.count = "", 0
+		return 0, ""
+	}
+	keys := make([]string, 0, len(calls))
+	names := make([]string, 0, len(calls))
+	for _, tc := range calls {
+		var args bytes.Buffer
+		if json.Compact(&args, []byte(tc.Function.Arguments)) != nil {
+			args.WriteString(tc.Function.Arguments)
+		}
+		keys = append(keys, tc.Function.Name+"\x00"+args.String())
+		names = append(names, tc.Function.Name)
+	}
+	sort.Strings(keys)
+	key := strings.Join(keys, "\n")
+	if key == r.last {
+		r.count++
+	} else {
+		r.last, r.count = key, 1
+	}
+	return r.count, strings.Join(names, ", ")
+}
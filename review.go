@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// `tinyagent review --base main` reviews the changes since a ref in one request, with no tools and no mission
+// loop, and prints structured findings: JSON for scripts and bots, Markdown for a PR comment. It shares the
+// finding type with --ci but not its cost, since the model only sees the diff. Without --base, `review` is
+// still the recipe that studies the code with tools.
+var (
+	reviewBase   = flag.String("base", "", "With the review subcommand, review the diff against this git ref in one request")
+	reviewFormat = flag.String("format", "markdown", "Output of the review subcommand with --base: markdown or json")
+)
+
+const reviewPrompt = `You review code changes given as a git diff. Report real problems only: bugs, unhandled errors, security issues, races, and code that will confuse the next reader; skip style nits and praise. Reply with a JSON array and nothing else, one object per problem: {"path": file as in the diff, "line": line number in the new version of the file, "severity": "error" for bugs, "warning" for risky code, or "note" for suggestions, "rule": short kebab-case name for the kind of problem, "message": what is wrong and how to fix it}. Reply with [] when the changes look right.`
+
+// maxReviewDiff bounds the diff sent to the model; the stat summary always covers every file.
+const maxReviewDiff = 128 << 10
+
+// isStructuredReview reports whether args ask for the structured review rather than the review recipe.
+func isStructuredReview(args []string) bool {
+	for _, arg := range args {
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if strings.HasPrefix(arg, "-") && name == "base" {
+			return true
+		}
+	}
+	return false
+}
+
+// runReview implements the subcommand and returns the process exit code: 1 when any finding is an error.
+func runReview(args []string) int {
+	flag.CommandLine.Parse(args)
+	*quiet = true // stdout is for the findings alone
+	if err := setupUI(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *reviewFormat != "markdown" && *reviewFormat != "json" {
+		fmt.Fprintf(os.Stderr, "tinyagent review: invalid --format %q (want markdown or json)\n", *reviewFormat)
+		return 2
+	}
+	changes := *reviewBase + "...HEAD"
+	stat, err := exec.Command("git", "diff", "--stat", changes).Output()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tinyagent review: git diff %s failed: %v\n", changes, err)
+		return 2
+	}
+	var list []finding
+	if strings.TrimSpace(string(stat)) != "" {
+		diff, _ := exec.Command("git", "diff", "--no-color", "--unified=5", changes).Output()
+		if len(diff) > maxReviewDiff {
+			diff = append([]byte(strings.ToValidUTF8(string(diff[:maxReviewDiff]), "")), fmt.Sprintf("\n[diff truncated, %d more bytes]\n", len(diff)-maxReviewDiff)...)
+		}
+		msg, _, err := sendChatRequest(withPurpose(context.Background(), "review"), *model, []ChatMessage{
+			{Role: "system", Content: reviewPrompt},
+			{Role: "user", Content: fmt.Sprintf("Files changed since %s:\n%s\nDiff:\n%s", *reviewBase, stat, diff)},
+		}, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "tinyagent review: %v\n", err)
+			return 1
+		}
+		if list, err = parseFindings(msg.Content); err != nil {
+			fmt.Fprintf(os.Stderr, "tinyagent review: %v\n", err)
+			return 1
+		}
+	}
+
+	if *reviewFormat == "json" {
+		raw, _ := json.MarshalIndent(append([]finding{}, list...), "", "  ")
+		fmt.Println(string(raw))
+	} else {
+		fmt.Print(renderFindings(*reviewBase, list))
+	}
+	for _, f := range list {
+		if f.Severity == "error" {
+			return 1
+		}
+	}
+	return 0
+}
+
+// parseFindings reads the model's JSON array, tolerating a code fence or prose around it. Findings that fail
+// report_finding's checks are dropped with a warning rather than failing the whole review.
+func parseFindings(reply string) ([]finding, error) {
+	reply = strings.TrimSpace(reply)
+	if m := messageFence.FindStringSubmatch(reply); m != nil {
+		reply = m[1]
+	}
+	start, end := strings.Index(reply, "["), strings.LastIndex(reply, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("the model did not reply with a JSON array of findings: %s", clip(reply))
+	}
+	var raw []finding
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &raw); err != nil {
+		return nil, fmt.Errorf("the model's findings are not valid JSON: %v", err)
+	}
+	for _, f := range raw {
+		if _, err := reportFinding(f); err != nil {
+			fmt.Fprintf(os.Stderr, "tinyagent review: dropped a finding at %s:%d: %v\n", f.Path, f.Line, err)
+		}
+	}
+	findings.Lock()
+	defer findings.Unlock()
+	return sortFindings(findings.list), nil
+}
+
+// renderFindings formats findings as Markdown grouped by file, ready to paste as a PR comment.
+func renderFindings(base string, list []finding) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Review of the changes since %s\n\n", base)
+	if len(list) == 0 {
+		b.WriteString("No problems found.\n")
+		return b.String()
+	}
+	counts := map[string]int{}
+	for _, f := range list {
+		counts[f.Severity]++
+	}
+	fmt.Fprintf(&b, "%d errors, %d warnings, %d notes.\n", counts["error"], counts["warning"], counts["note"])
+	icons := map[string]string{"error": "🔴", "warning": "🟡", "note": "🔵"}
+	for i, f := range list {
+		if i == 0 || list[i-1].Path != f.Path {
+			fmt.Fprintf(&b, "\n### `%s`\n\n", f.Path)
+		}
+		fmt.Fprintf(&b, "- %s **%s** line %d (%s): %s\n", icons[f.Severity], f.Severity, f.Line, f.Rule, f.Message)
+	}
+	return b.String()
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// --root gives the file tools several directories at once, such as a service and the shared library it uses, so
+// one mission can follow a call across repositories. Each root is a top-level directory of the workspace, named
+// after its base name or as name=dir: listing "." shows the roots, and a path like lib/http/client.go is
+// client.go in the lib root. Everything else, including the session's own files, stays in the working directory.
+// A root may also be an archive or a bucket, which the tools then read without it being on local disk.
+var rootDirs stringList
+
+func init() {
+	flag.Var(&rootDirs, "root", "A directory, archive (.zip, .tar, .tar.gz), or s3:// or gs:// bucket the file tools work on, as source or name=source; repeat for several, each addressed as name/path")
+}
+
+// rootsFS is a workspace of several trees, each under its root name.
+type rootsFS struct {
+	names []string
+	fsys  map[string]workspaceFS
+	dirs  map[string]string // the roots on local disk, where commands can run
+}
+
+// dirFS is a local directory.
+type dirFS string
+
+func (d dirFS) local(name string) string { return filepath.Join(string(d), filepath.FromSlash(name)) }
+
+func (d dirFS) Open(name string) (fs.File, error)          { return os.Open(d.local(name)) }
+func (d dirFS) Stat(name string) (fs.FileInfo, error)      { return os.Stat(d.local(name)) }
+func (d dirFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(d.local(name)) }
+func (d dirFS) ReadFile(name string) ([]byte, error)       { return os.ReadFile(d.local(name)) }
+func (d dirFS) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(d.local(name), perm)
+}
+func (d dirFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(d.local(name), data, perm)
+}
+
+// openRoot opens one --root source, returning its tree and the name it goes by unless one is given.
+func openRoot(source string) (workspaceFS, string, error) {
+	switch {
+	case isBucket(source):
+		b, err := openBucket(source)
+		if err != nil {
+			return nil, "", err
+		}
+		return b, b.bucket, nil
+	case isArchive(source):
+		a, err := openArchive(source)
+		if err != nil {
+			return nil, "", err
+		}
+		base := filepath.Base(source)
+		for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip", ".jar"} {
+			if len(base) > len(ext) && strings.EqualFold(base[len(base)-len(ext):], ext) {
+				base = base[:len(base)-len(ext)]
+				break
+			}
+		}
+		return a, base, nil
+	}
+	abs, err := filepath.Abs(source)
+	if err != nil {
+		return nil, "", err
+	}
+	if info, err := os.Stat(source); err != nil || !info.IsDir() {
+		return nil, "", fmt.Errorf("%s is not a directory", source)
+	}
+	return dirFS(source), filepath.Base(abs), nil
+}
+
+// useRoots switches the workspace to the --root directories, if any were given.
+func useRoots() error {
+	if len(rootDirs) == 0 {
+		return nil
+	}
+	if *remote != "" {
+		return fmt.Errorf("--root and --remote cannot be used together")
+	}
+	roots := &rootsFS{fsys: map[string]workspaceFS{}, dirs: map[string]string{}}
+	for _, value := range rootDirs {
+		name, source, named := strings.Cut(value, "=")
+		if !named || isBucket(value) {
+			name, source = "", value
+		}
+		tree, base, err := openRoot(source)
+		if err != nil {
+			return fmt.Errorf("--root %s: %v", value, err)
+		}
+		if name == "" {
+			name = base
+		}
+		if !filepath.IsLocal(name) || strings.ContainsAny(name, `/\`) {
+			return fmt.Errorf("--root %s: %q can't name a root; give one as name=source", value, name)
+		}
+		if _, taken := roots.fsys[name]; taken {
+			return fmt.Errorf("--root %s: there is already a root named %s; give one as name=source", value, name)
+		}
+		roots.names = append(roots.names, name)
+		roots.fsys[name] = tree
+		if dir, ok := tree.(dirFS); ok {
+			roots.dirs[name] = string(dir)
+		}
+	}
+	workspace = roots
+	event(slog.LevelInfo, fmt.Sprintf("\033[90m📚 Working in %s\033[0m\n", strings.Join(roots.names, ", ")), "workspace roots", "roots", roots.names)
+	return nil
+}
+
+// rootOf splits a workspace path into the directory a command should run in and the path from there. Outside
+// multi-root workspaces that is the working directory and the path unchanged.
+func rootOf(name string) (dir, rest string, err error) {
+	roots, ok := workspace.(*rootsFS)
+	if !ok {
+		return "", name, nil
+	}
+	root, rest, err := roots.resolve(name)
+	if err != nil {
+		return "", "", err
+	}
+	if dir, ok = roots.dirs[root]; !ok {
+		return "", "", fmt.Errorf("%s is not on local disk, so commands can't run on it", root)
+	}
+	return dir, rest, nil
+}
+
+// workDirs lists the directories of the workspace on this machine, or "." for the working directory alone.
+func workDirs() []string {
+	roots, ok := workspace.(*rootsFS)
+	if !ok {
+		return []string{"."}
+	}
+	var dirs []string
+	for _, name := range roots.names {
+		if dir, ok := roots.dirs[name]; ok {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// resolve finds the root a path is in and the path inside it, which is "." for the root itself.
+func (r *rootsFS) resolve(name string) (root, rest string, err error) {
+	root, rest, _ = strings.Cut(path.Clean(filepath.ToSlash(name)), "/")
+	if _, ok := r.fsys[root]; !ok {
+		return "", "", fmt.Errorf("%s is not in a root; paths start with one of %s", name, strings.Join(r.names, ", "))
+	}
+	if rest == "" {
+		rest = "."
+	}
+	return root, rest, nil
+}
+
+// tree finds the tree a path is in, failing for "." and anything else above the roots.
+func (r *rootsFS) tree(op, name string) (workspaceFS, string, error) {
+	root, rest, err := r.resolve(name)
+	if err != nil {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return r.fsys[root], rest, nil
+}
+
+func isTop(name string) bool {
+	return name == "." || name == "" || name == "/"
+}
+
+func (r *rootsFS) Open(name string) (fs.File, error) {
+	tree, rest, err := r.tree("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := tree.Open(rest)
+	return f, named(err, name)
+}
+
+func (r *rootsFS) Stat(name string) (fs.FileInfo, error) {
+	if isTop(name) {
+		return topInfo{}, nil
+	}
+	tree, rest, err := r.tree("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := tree.Stat(rest)
+	if err != nil {
+		return nil, named(err, name)
+	}
+	if rest == "." {
+		return namedInfo{info, path.Clean(filepath.ToSlash(name))}, nil
+	}
+	return info, nil
+}
+
+func (r *rootsFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !isTop(name) {
+		tree, rest, err := r.tree("readdir", name)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := tree.ReadDir(rest)
+		return entries, named(err, name)
+	}
+	var entries []fs.DirEntry
+	for _, root := range r.names {
+		info, err := r.fsys[root].Stat(".")
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(namedInfo{info, root}))
+	}
+	return entries, nil
+}
+
+func (r *rootsFS) ReadFile(name string) ([]byte, error) {
+	tree, rest, err := r.tree("read", name)
+	if err != nil {
+		return nil, err
+	}
+	content, err := tree.ReadFile(rest)
+	return content, named(err, name)
+}
+
+func (r *rootsFS) MkdirAll(name string, perm fs.FileMode) error {
+	if isTop(name) {
+		return nil
+	}
+	tree, rest, err := r.tree("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return named(tree.MkdirAll(rest, perm), name)
+}
+
+func (r *rootsFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	tree, rest, err := r.tree("write", name)
+	if err != nil {
+		return err
+	}
+	return named(tree.WriteFile(rest, data, perm), name)
+}
+
+// named reports a root's error under the workspace path the tool gave, rather than the root's own.
+func named(err error, name string) error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return &fs.PathError{Op: pe.Op, Path: name, Err: pe.Err}
+	}
+	return err
+}
+
+// namedInfo is a root's directory under its root name.
+type namedInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (i namedInfo) Name() string { return i.name }
+
+// topInfo is the directory above the roots, which exists only in the workspace.
+type topInfo struct{}
+
+func (topInfo) Name() string       { return "." }
+func (topInfo) Size() int64        { return 0 }
+func (topInfo) Mode() fs.FileMode  { return fs.ModeDir | 0o555 }
+func (topInfo) ModTime() time.Time { return time.Time{} }
+func (topInfo) IsDir() bool        { return true }
+func (topInfo) Sys() any           { return nil }
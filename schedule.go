@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// --every runs the given mission on a schedule, unattended, so a recipe like a weekly health check keeps
+// reporting without anyone at a terminal. Each run starts a fresh conversation; the answers go wherever this
+// session sends answers (--output, --github-comment, --email-to) as well as to the log.
+var every = flag.Duration("every", 0, "Run the mission again at this interval, such as 24h or 168h, until stopped")
+
+// runSchedule runs the mission now and then once per interval until ctx ends.
+func runSchedule(ctx context.Context, tools string) error {
+	if *mission == "" {
+		return fmt.Errorf("--every needs a mission, from --mission, --mission-file, or a recipe")
+	}
+	for {
+		next := time.Now().Add(*every)
+		event(slog.LevelInfo, fmt.Sprintf("\033[34m⏰ Scheduled run:\033[0m %s\n", *mission), "scheduled run", "mission", *mission)
+		var messages []ChatMessage
+		answer, err := runBotMission(ctx, tools, &messages, *mission, nil)
+		if err != nil {
+			event(slog.LevelWarn, fmt.Sprintf("\033[33mScheduled mission failed: %v\033[0m\n", err), "scheduled run failed", "err", err)
+		} else {
+			result(answer)
+			if *githubCommentOn > 0 {
+				shareAnswer(postGitHubComment, *githubCommentOn, *mission, answer)
+			}
+			if *gitlabCommentOn > 0 {
+				shareAnswer(postGitLabNote, *gitlabCommentOn, *mission, answer)
+			}
+		}
+		emailReport(*mission, answer, err, messages)
+
+		event(slog.LevelInfo, fmt.Sprintf("\033[90mNext run at %s\033[0m\n", next.Format("2006-01-02 15:04")), "next scheduled run", "at", next)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Tool arguments used to be unmarshalled into whatever fit, so a missing question became an empty string and a
+// numeric page silently became page 0. Checking each call against the schema declared in toolDef gives the model
+// a precise error to correct instead of a confusing answer.
+type paramSchema struct {
+	Type    string       `json:"type"`
+	Default any          `json:"default"`
+	Items   *paramSchema `json:"items"`
+}
+
+type toolSchema struct {
+	Properties map[string]paramSchema `json:"properties"`
+	Required   []string               `json:"required"`
+}
+
+// toolSchemas is built on first use, once every toolset has registered.
+var toolSchemas = sync.OnceValue(func() map[string]toolSchema {
+	var defs []struct {
+		Function struct {
+			Name       string     `json:"name"`
+			Parameters toolSchema `json:"parameters"`
+		} `json:"function"`
+	}
+	schemas := map[string]toolSchema{}
+	all := []string{toolDef, writeToolDef}
+	for _, ts := range toolsets {
+		all = append(all, ts.def)
+	}
+	for _, def := range all {
+		defs = nil
+		if err := json.Unmarshal([]byte(def), &defs); err != nil {
+			panic("tool definitions are not valid JSON: " + err.Error())
+		}
+		for _, d := range defs {
+			schemas[d.Function.Name] = d.Function.Parameters
+		}
+	}
+	return schemas
+})
+
+// validateArgs checks a tool call's JSON arguments against its schema, fills in declared defaults, and returns
+// the normalized arguments. Every problem is reported at once so the model can fix the call in one retry.
+func validateArgs(name, args string) (string, error) {
+	schema, ok := toolSchemas()[name]
+	if !ok {
+		return args, nil // runTool reports unknown tools itself
+	}
+	params := map[string]any{}
+	if strings.TrimSpace(args) != "" {
+		if err := json.Unmarshal([]byte(args), &params); err != nil {
+			return "", fmt.Errorf("Invalid arguments for %s: not a JSON object: %v", name, err)
+		}
+	}
+
+	var problems []string
+	for _, req := range schema.Required {
+		if _, ok := params[req]; !ok && schema.Properties[req].Default == nil {
+			problems = append(problems, fmt.Sprintf("missing required parameter %q", req))
+		}
+	}
+	names := make([]string, 0, len(schema.Properties))
+	for p := range schema.Properties {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+	for _, p := range names {
+		prop := schema.Properties[p]
+		value, ok := params[p]
+		if !ok || value == nil || (value == "" && prop.Type != "string") {
+			if prop.Default != nil {
+				params[p] = prop.Default
+			} else {
+				delete(params, p)
+			}
+			continue
+		}
+		coerced, err := coerceParam(prop, value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%q %v", p, err))
+			continue
+		}
+		params[p] = coerced
+	}
+	if len(problems) > 0 {
+		return "", fmt.Errorf("Invalid arguments for %s: %s", name, strings.Join(problems, "; "))
+	}
+
+	normalized, _ := json.Marshal(params)
+	return string(normalized), nil
+}
+
+// coerceParam accepts a value of the declared type, converting lossless scalar mix-ups such as 3 for "3".
+func coerceParam(prop paramSchema, value any) (any, error) {
+	switch prop.Type {
+	case "string":
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		}
+	case "integer":
+		if v, ok := value.(float64); ok && v == math.Trunc(v) {
+			return v, nil
+		}
+		// Models trained on older versions of these tools still send pages as strings like "2".
+		if v, ok := numericString(value); ok && v == math.Trunc(v) {
+			return v, nil
+		}
+	case "number":
+		if v, ok := value.(float64); ok {
+			return v, nil
+		}
+		if v, ok := numericString(value); ok {
+			return v, nil
+		}
+	case "boolean":
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			break
+		}
+		if prop.Items != nil {
+			for i, item := range items {
+				coerced, err := coerceParam(*prop.Items, item)
+				if err != nil {
+					return nil, fmt.Errorf("item %d %v", i, err)
+				}
+				items[i] = coerced
+			}
+		}
+		return items, nil
+	case "object":
+		if v, ok := value.(map[string]any); ok {
+			return v, nil
+		}
+	default:
+		return value, nil
+	}
+	return nil, fmt.Errorf("must be %s %s, got %s", article(prop.Type), prop.Type, jsonType(value))
+}
+
+func numericString(value any) (float64, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return v, err == nil
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "an object"
+	}
+	return "null"
+}
+
+func article(word string) string {
+	if strings.ContainsRune("aeiou", rune(word[0])) {
+		return "an"
+	}
+	return "a"
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// The screenshot tool shows a vision model the screen, or one window of it, for missions about how something
+// renders: a TUI drawn wrong, a dialog cut off. It is offered with --vision on macOS and Linux, and captures with
+// whichever screenshot command is installed.
+const screenshotToolDef = `[
+		{"type":"function","function":{"name":"screenshot","description":"Capture the screen, or one window, and look at it as an image.","parameters":{"type":"object","properties":{
+			"window":{"type":"string","description":"Optional window to capture instead of the whole screen: its title on Linux (X11), or its application name on macOS, such as Terminal"} }}}}
+		]`
+
+// screenshotCommands and windowCommands are tried in order; FILE is the PNG to write, WINDOW the window asked
+// for, and BOUNDS its x,y,width,height on screen.
+var (
+	screenshotCommands = map[string][][]string{
+		"darwin": {{"screencapture", "-x", "-t", "png", "FILE"}},
+		"linux":  {{"grim", "FILE"}, {"gnome-screenshot", "-f", "FILE"}, {"import", "-window", "root", "FILE"}, {"scrot", "-o", "FILE"}},
+	}
+	windowCommands = map[string][][]string{
+		"darwin": {{"screencapture", "-x", "-t", "png", "-R", "BOUNDS", "FILE"}},
+		"linux":  {{"import", "-window", "WINDOW", "FILE"}},
+	}
+)
+
+var screenshotTools = registerToolset(&toolset{
+	def:      screenshotToolDef,
+	enabled:  func() bool { return *vision && len(screenshotCommands[runtime.GOOS]) > 0 },
+	readOnly: true,
+	run: map[string]func(context.Context, string) (string, error){
+		"screenshot": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Window string `json:"window"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			return screenshot(ctx, params.Window)
+		},
+	},
+})
+
+func screenshot(ctx context.Context, window string) (string, error) {
+	dir, err := os.MkdirTemp("", "tinyagent-screenshot-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "screen.png")
+
+	commands, subject := screenshotCommands, "the whole screen"
+	replacer := strings.NewReplacer("FILE", path)
+	if window = strings.TrimSpace(window); window != "" {
+		commands, subject = windowCommands, "the window "+window
+		bounds := ""
+		if runtime.GOOS == "darwin" {
+			if bounds, err = windowBounds(ctx, window); err != nil {
+				return "", err
+			}
+		}
+		replacer = strings.NewReplacer("FILE", path, "WINDOW", window, "BOUNDS", bounds)
+	}
+	if err := capture(ctx, commands, replacer); err != nil {
+		return "", err
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("the screenshot was not written: %v", err)
+	}
+	url, width, height, err := imageDataURL(raw)
+	if err != nil {
+		return "", fmt.Errorf("could not read the screenshot: %v", err)
+	}
+	if !attachImage(ctx, url) {
+		return "", fmt.Errorf("Permanent Error: screenshots can only be attached during a mission")
+	}
+	return fmt.Sprintf("screenshot of %s captured, %dx%d, and attached in the next message", subject, width, height), nil
+}
+
+// capture runs the first installed command of the platform's list.
+func capture(ctx context.Context, commands map[string][][]string, replacer *strings.Replacer) error {
+	var names []string
+	for _, argv := range commands[runtime.GOOS] {
+		names = append(names, argv[0])
+		if _, err := exec.LookPath(argv[0]); err != nil {
+			continue
+		}
+		args := make([]string, len(argv)-1)
+		for i, arg := range argv[1:] {
+			args[i] = replacer.Replace(arg)
+		}
+		out, err := exec.CommandContext(ctx, argv[0], args...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s failed: %v %s", argv[0], err, clip(strings.TrimSpace(string(out))))
+		}
+		return nil
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("Permanent Error: screenshots of a single window are not supported on %s", runtime.GOOS)
+	}
+	return fmt.Errorf("Permanent Error: no screenshot command is installed, the user could install %s", strings.Join(names, " or "))
+}
+
+// windowBounds asks macOS where an application's front window is, since screencapture selects windows only by
+// an ID that nothing else reports. It needs the accessibility permission for the terminal.
+func windowBounds(ctx context.Context, app string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "osascript",
+		"-e", "on run argv",
+		"-e", `tell application "System Events" to tell (first process whose name is (item 1 of argv)) to get {position, size} of front window`,
+		"-e", "end run", app)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("could not find a window of %s: %s", app, clip(strings.TrimSpace(stderr.String())))
+	}
+	return strings.ReplaceAll(strings.TrimSpace(string(out)), " ", ""), nil
+}
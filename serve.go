@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// --serve takes missions over HTTP from other programs. More can arrive than the agent can run, which is one at
+// a time like every bot, so they wait in a queue: the highest priority goes first, then the nearest deadline,
+// then the earliest to arrive. A mission whose deadline passes while it waits is dropped unrun, and a running one
+// is stopped at its deadline. When the queue is full new missions are turned away with a Retry-After, and
+// GET /queue shows what is waiting, so callers can back off rather than pile up.
+//
+//	POST   /missions       {"mission": "...", "priority": 5, "deadline": "2026-01-02T15:04:05Z" or "timeout": "10m"}
+//	GET    /missions/{id}  status, queue position, answer or error, times, and cost
+//	DELETE /missions/{id}  cancel a waiting or running mission
+//	GET    /queue          the running mission, those waiting in order, and the expected wait
+//	GET    /metrics        the Prometheus metrics, as --metrics-addr serves them
+//
+// With SERVE_TOKEN set every request needs it as a bearer token. Nobody is there to approve changes, so every
+// proposed change is rejected.
+var (
+	serveAddr  = flag.String("serve", "", "Take missions over HTTP on this address, such as :8080, queued by priority and deadline")
+	serveQueue = flag.Int("serve-queue", 100, "With --serve, how many missions may wait before new ones are turned away")
+)
+
+// finishedKept is how long a finished mission's result stays available to fetch.
+const finishedKept = time.Hour
+
+type servedMission struct {
+	ID        string    `json:"id"`
+	Mission   string    `json:"mission"`
+	Priority  int       `json:"priority"`
+	Deadline  time.Time `json:"deadline,omitzero"`
+	Status    string    `json:"status"` // queued, running, done, failed, expired, or cancelled
+	Position  int       `json:"position,omitempty"`
+	Answer    string    `json:"answer,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Cost      float64   `json:"cost_dollars,omitempty"`
+	Submitted time.Time `json:"submitted"`
+	Started   time.Time `json:"started,omitzero"`
+	Finished  time.Time `json:"finished,omitzero"`
+
+	seq    int
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+type missionQueue struct {
+	mu       sync.Mutex
+	missions map[string]*servedMission
+	waiting  []*servedMission
+	running  *servedMission
+	seq      int
+	average  time.Duration // of recent runs, for Retry-After and the expected wait
+	wake     chan struct{}
+}
+
+// before orders the queue: priority, then deadline, where none is the latest, then arrival.
+func (a *servedMission) before(b *servedMission) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	if !a.Deadline.Equal(b.Deadline) {
+		return !a.Deadline.IsZero() && (b.Deadline.IsZero() || a.Deadline.Before(b.Deadline))
+	}
+	return a.seq < b.seq
+}
+
+// sweep expires waiting missions past their deadline, forgets old results, and renumbers the queue. The caller
+// holds q.mu.
+func (q *missionQueue) sweep(now time.Time) {
+	kept := q.waiting[:0]
+	for _, m := range q.waiting {
+		if !m.Deadline.IsZero() && now.After(m.Deadline) {
+			m.Status, m.Error, m.Position, m.Finished = "expired", "the deadline passed before the mission could start", 0, now
+			event(slog.LevelWarn, fmt.Sprintf("\033[33mServed mission %s expired in the queue\033[0m\n", m.ID), "served mission expired", "id", m.ID)
+			continue
+		}
+		kept = append(kept, m)
+	}
+	q.waiting = kept
+	for i, m := range q.waiting {
+		m.Position = i + 1
+	}
+	for id, m := range q.missions {
+		if !m.Finished.IsZero() && now.Sub(m.Finished) > finishedKept {
+			delete(q.missions, id)
+		}
+	}
+}
+
+// expectedWait estimates how long a mission joining the back of the queue waits to start.
+func (q *missionQueue) expectedWait() time.Duration {
+	waits := len(q.waiting)
+	if q.running != nil {
+		waits++
+	}
+	return time.Duration(waits) * q.average
+}
+
+func (q *missionQueue) add(m *servedMission) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.sweep(time.Now())
+	if len(q.waiting) >= *serveQueue {
+		return errQueueFull
+	}
+	q.seq++
+	m.seq, m.Status = q.seq, "queued"
+	m.ID = fmt.Sprintf("m%d-%s", q.seq, randomHex(4))
+	at := len(q.waiting)
+	for at > 0 && m.before(q.waiting[at-1]) {
+		at--
+	}
+	q.waiting = append(q.waiting[:at], append([]*servedMission{m}, q.waiting[at:]...)...)
+	q.missions[m.ID] = m
+	q.sweep(time.Now())
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+var errQueueFull = errors.New("the mission queue is full")
+
+// next waits for the first mission in the queue and marks it running, with the context it runs under, so a
+// cancel that comes before it starts still stops it.
+func (q *missionQueue) next(ctx context.Context) *servedMission {
+	for {
+		q.mu.Lock()
+		q.sweep(time.Now())
+		if len(q.waiting) > 0 {
+			m := q.waiting[0]
+			q.waiting = q.waiting[1:]
+			q.sweep(time.Now())
+			m.Status, m.Position, m.Started = "running", 0, time.Now()
+			if m.Deadline.IsZero() {
+				m.ctx, m.cancel = context.WithCancel(ctx)
+			} else {
+				m.ctx, m.cancel = context.WithDeadline(ctx, m.Deadline)
+			}
+			q.running = m
+			q.mu.Unlock()
+			return m
+		}
+		q.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-q.wake:
+		}
+	}
+}
+
+// runServe takes missions over HTTP and runs them from the queue until ctx ends.
+func runServe(ctx context.Context, tools string) error {
+	if *serveQueue < 1 {
+		return fmt.Errorf("--serve-queue must be at least 1")
+	}
+	q := &missionQueue{missions: map[string]*servedMission{}, average: time.Minute, wake: make(chan struct{}, 1)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /missions", q.handleSubmit)
+	mux.HandleFunc("GET /missions/{id}", q.handleStatus)
+	mux.HandleFunc("DELETE /missions/{id}", q.handleCancel)
+	mux.HandleFunc("GET /queue", q.handleQueue)
+	mux.HandleFunc("GET /metrics", writeMetrics)
+	server := &http.Server{Addr: *serveAddr, Handler: requireServeToken(mux)}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go func() {
+		for m := q.next(ctx); m != nil; m = q.next(ctx) {
+			q.run(tools, m)
+		}
+	}()
+	event(slog.LevelInfo, fmt.Sprintf("\033[34m🛰  Taking missions on %s\033[0m\n", *serveAddr), "serving missions", "addr", *serveAddr, "queue", *serveQueue)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (q *missionQueue) run(tools string, m *servedMission) {
+	q.mu.Lock()
+	missionCtx, cancel := m.ctx, m.cancel
+	q.mu.Unlock()
+	defer cancel()
+
+	event(slog.LevelInfo, fmt.Sprintf("\033[34m🛰  Served mission %s (priority %d):\033[0m %s\n", m.ID, m.Priority, m.Mission), "served mission", "id", m.ID, "priority", m.Priority, "mission", m.Mission)
+	spent := sessionTotal().Cost
+	var messages []ChatMessage
+	missionCtx = withApprover(missionCtx, func(string, string) string { return "" })
+	answer, err := runBotMission(missionCtx, tools, &messages, m.Mission, nil)
+	if err == nil {
+		result(answer)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	m.Finished, m.Cost, m.cancel = time.Now(), sessionTotal().Cost-spent, nil
+	q.running = nil
+	q.average = (3*q.average + m.Finished.Sub(m.Started)) / 4
+	switch {
+	case errors.Is(missionCtx.Err(), context.DeadlineExceeded):
+		m.Status, m.Error = "expired", "the deadline passed while the mission was running"
+	case m.Status == "cancelled":
+	case err != nil:
+		m.Status, m.Error = "failed", err.Error()
+	default:
+		m.Status, m.Answer = "done", answer
+	}
+	if m.Error != "" {
+		event(slog.LevelWarn, fmt.Sprintf("\033[33mServed mission %s %s: %s\033[0m\n", m.ID, m.Status, m.Error), "served mission ended", "id", m.ID, "status", m.Status, "err", m.Error)
+	}
+}
+
+func (q *missionQueue) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Mission  string    `json:"mission"`
+		Priority int       `json:"priority"`
+		Deadline time.Time `json:"deadline"`
+		Timeout  string    `json:"timeout"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeServeError(w, http.StatusBadRequest, fmt.Sprintf("the body must be a JSON mission: %v", err))
+		return
+	}
+	if req.Mission == "" {
+		writeServeError(w, http.StatusBadRequest, "mission is required")
+		return
+	}
+	m := &servedMission{Mission: req.Mission, Priority: req.Priority, Deadline: req.Deadline, Submitted: time.Now()}
+	if req.Timeout != "" {
+		timeout, err := time.ParseDuration(req.Timeout)
+		if err != nil || timeout <= 0 {
+			writeServeError(w, http.StatusBadRequest, fmt.Sprintf("timeout %q is not a duration such as 10m", req.Timeout))
+			return
+		}
+		if deadline := m.Submitted.Add(timeout); m.Deadline.IsZero() || deadline.Before(m.Deadline) {
+			m.Deadline = deadline
+		}
+	}
+	if !m.Deadline.IsZero() && !m.Deadline.After(m.Submitted) {
+		writeServeError(w, http.StatusBadRequest, "the deadline has already passed")
+		return
+	}
+	if err := q.add(m); err != nil {
+		// A place opens when the running mission ends, about one average run away.
+		q.mu.Lock()
+		w.Header().Set("Retry-After", strconv.Itoa(int(max(q.average, time.Second).Seconds())))
+		q.mu.Unlock()
+		writeServeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	writeServeJSON(w, http.StatusAccepted, m)
+}
+
+func (q *missionQueue) handleStatus(w http.ResponseWriter, r *http.Request) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.sweep(time.Now())
+	m, ok := q.missions[r.PathValue("id")]
+	if !ok {
+		writeServeError(w, http.StatusNotFound, "no such mission")
+		return
+	}
+	writeServeJSON(w, http.StatusOK, m)
+}
+
+func (q *missionQueue) handleCancel(w http.ResponseWriter, r *http.Request) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.sweep(time.Now())
+	m, ok := q.missions[r.PathValue("id")]
+	switch {
+	case !ok:
+		writeServeError(w, http.StatusNotFound, "no such mission")
+		return
+	case m.Status == "queued":
+		for i, waiting := range q.waiting {
+			if waiting == m {
+				q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+				break
+			}
+		}
+		m.Finished = time.Now()
+		q.sweep(m.Finished)
+	case m.Status == "running":
+		m.cancel()
+	default:
+		writeServeError(w, http.StatusConflict, "the mission has already ended: "+m.Status)
+		return
+	}
+	m.Status, m.Position = "cancelled", 0
+	event(slog.LevelInfo, fmt.Sprintf("\033[90mServed mission %s cancelled\033[0m\n", m.ID), "served mission cancelled", "id", m.ID)
+	writeServeJSON(w, http.StatusOK, m)
+}
+
+func (q *missionQueue) handleQueue(w http.ResponseWriter, _ *http.Request) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.sweep(time.Now())
+	writeServeJSON(w, http.StatusOK, map[string]any{
+		"running":                 q.running,
+		"waiting":                 q.waiting,
+		"capacity":                *serveQueue,
+		"expected_wait_seconds":   int(q.expectedWait().Seconds()),
+		"average_mission_seconds": int(q.average.Seconds()),
+	})
+}
+
+// requireServeToken turns away requests without SERVE_TOKEN, when it is set.
+func requireServeToken(next http.Handler) http.Handler {
+	token := os.Getenv("SERVE_TOKEN")
+	if token == "" {
+		return next
+	}
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			writeServeError(w, http.StatusUnauthorized, "a bearer token is required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeServeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeServeError(w http.ResponseWriter, status int, message string) {
+	writeServeJSON(w, status, map[string]string{"error": message})
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// --slack turns tinyagent into a team tool: it connects to Slack over Socket Mode, which needs no public URL, and
+// takes a mission from every mention of the bot and every direct message to it. Each tool call is posted in a
+// thread under the mission as it happens and the answer closes the thread. SLACK_APP_TOKEN (xapp-, with
+// connections:write) opens the socket; SLACK_BOT_TOKEN (xoxb-, with chat:write) posts. --slack-channels and
+// --slack-users limit who can start missions, and one of them is required with --allow-writes, since otherwise
+// anyone in the workspace could drive the write tools.
+var (
+	slackMode     = flag.Bool("slack", false, "Run as a Slack bot over Socket Mode, taking missions from mentions and direct messages (needs SLACK_APP_TOKEN and SLACK_BOT_TOKEN)")
+	slackAPI      = flag.String("slack-api", "https://slack.com/api", "Slack Web API base URL")
+	slackChannels = flag.String("slack-channels", "", "Comma-separated IDs of the Slack channels the bot takes missions in (default: all)")
+	slackUsers    = flag.String("slack-users", "", "Comma-separated IDs of the Slack users the bot takes missions from (default: all)")
+)
+
+// maxSlackText stays under the size at which Slack starts truncating a message.
+const maxSlackText = 39000
+
+var slackMention = regexp.MustCompile(`<@[A-Z0-9]+>`)
+
+type slackMission struct {
+	channel, thread, user, text string
+}
+
+// slackAllowlist holds --slack-channels and --slack-users; an empty set allows everyone.
+type slackAllowlist struct {
+	channels, users map[string]bool
+}
+
+func parseSlackAllowlist() slackAllowlist {
+	set := func(list string) map[string]bool {
+		ids := map[string]bool{}
+		for _, id := range strings.Split(list, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids[id] = true
+			}
+		}
+		return ids
+	}
+	return slackAllowlist{set(*slackChannels), set(*slackUsers)}
+}
+
+func (a slackAllowlist) allows(m slackMission) bool {
+	return (len(a.channels) == 0 || a.channels[m.channel]) && (len(a.users) == 0 || a.users[m.user])
+}
+
+// slackCall invokes a Web API method, which reports failure in the body with "ok": false rather than the status.
+func slackCall(ctx context.Context, token, method string, body, v any) error {
+	var raw json.RawMessage
+	if err := sendJSON(ctx, "POST", strings.TrimSuffix(*slackAPI, "/")+"/"+method, http.Header{"Authorization": {"Bearer " + token}}, body, &raw); err != nil {
+		return err
+	}
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return err
+	}
+	if !status.OK {
+		return fmt.Errorf("slack %s: %s", method, status.Error)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// slackPost adds text to a thread; failing to post is reported but never stops the bot.
+func slackPost(ctx context.Context, channel, thread, text string) {
+	if len(text) > maxSlackText {
+		text = strings.ToValidUTF8(text[:maxSlackText], "") + "\n…"
+	}
+	err := slackCall(ctx, os.Getenv("SLACK_BOT_TOKEN"), "chat.postMessage", map[string]string{"channel": channel, "thread_ts": thread, "text": text}, nil)
+	if err != nil {
+		event(slog.LevelWarn, fmt.Sprintf("\033[33mCould not post to Slack: %v\033[0m\n", err), "slack post failed", "channel", channel, "err", err)
+	}
+}
+
+// runSlack serves missions from Slack until ctx ends, reconnecting whenever the socket drops, as Slack expects
+// clients to do every few hours.
+func runSlack(ctx context.Context, tools string) error {
+	appToken, botToken := os.Getenv("SLACK_APP_TOKEN"), os.Getenv("SLACK_BOT_TOKEN")
+	if appToken == "" || botToken == "" {
+		return fmt.Errorf("--slack needs SLACK_APP_TOKEN and SLACK_BOT_TOKEN")
+	}
+	allowed := parseSlackAllowlist()
+	if *allowWrites && len(allowed.channels) == 0 && len(allowed.users) == 0 {
+		return fmt.Errorf("--slack with --allow-writes needs --slack-channels or --slack-users, so only they can make changes")
+	}
+	missions := make(chan slackMission, 64)
+	go func() {
+		for m := range missions {
+			runSlackMission(ctx, tools, m)
+		}
+	}()
+
+	for backoff := time.Second; ctx.Err() == nil; {
+		err := serveSlackSocket(ctx, appToken, allowed, missions)
+		if err == nil {
+			backoff = time.Second
+			continue
+		}
+		event(slog.LevelWarn, fmt.Sprintf("\033[33mSlack connection lost: %v, reconnecting in %s\033[0m\n", err, backoff), "slack disconnected", "err", err, "retry_in", backoff.String())
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+	return ctx.Err()
+}
+
+// serveSlackSocket opens one Socket Mode connection and queues the missions it delivers. It returns nil when Slack
+// asks for a reconnect. A full queue turns missions away rather than blocking, since the socket must keep reading
+// to acknowledge envelopes or Slack drops it.
+func serveSlackSocket(ctx context.Context, appToken string, allowed slackAllowlist, missions chan<- slackMission) error {
+	var open struct {
+		URL string `json:"url"`
+	}
+	if err := slackCall(ctx, appToken, "apps.connections.open", nil, &open); err != nil {
+		return err
+	}
+	ws, err := dialWebsocket(ctx, open.URL)
+	if err != nil {
+		return err
+	}
+	defer ws.close()
+	go func() {
+		<-ctx.Done()
+		ws.close()
+	}()
+
+	for {
+		raw, err := ws.read()
+		if err != nil {
+			return err
+		}
+		var envelope struct {
+			EnvelopeID string `json:"envelope_id"`
+			Type       string `json:"type"`
+			Payload    struct {
+				Event struct {
+					Type        string `json:"type"`
+					Subtype     string `json:"subtype"`
+					ChannelType string `json:"channel_type"`
+					BotID       string `json:"bot_id"`
+					User        string `json:"user"`
+					Text        string `json:"text"`
+					Channel     string `json:"channel"`
+					TS          string `json:"ts"`
+					ThreadTS    string `json:"thread_ts"`
+				} `json:"event"`
+			} `json:"payload"`
+		}
+		if err := json.Unmarshal(raw, &envelope); err != nil {
+			continue
+		}
+		// Slack redelivers anything not acknowledged within a few seconds, so the ack comes before the work.
+		if envelope.EnvelopeID != "" {
+			ack, _ := json.Marshal(map[string]string{"envelope_id": envelope.EnvelopeID})
+			if err := ws.send(ack); err != nil {
+				return err
+			}
+		}
+		switch envelope.Type {
+		case "hello":
+			event(slog.LevelInfo, "\033[32mConnected to Slack, waiting for missions\033[0m\n", "slack connected")
+			continue
+		case "disconnect":
+			return nil
+		case "events_api":
+		default:
+			continue
+		}
+
+		e := envelope.Payload.Event
+		direct := e.Type == "message" && e.ChannelType == "im" && e.Subtype == ""
+		if e.BotID != "" || (e.Type != "app_mention" && !direct) {
+			continue // the bot's own posts, edits, joins, and channel chatter
+		}
+		thread := e.ThreadTS
+		if thread == "" {
+			thread = e.TS
+		}
+		m := slackMission{channel: e.Channel, thread: thread, user: e.User, text: strings.TrimSpace(slackMention.ReplaceAllString(e.Text, ""))}
+		if !allowed.allows(m) {
+			event(slog.LevelWarn, fmt.Sprintf("\033[33mIgnored a Slack message from user %s in %s, not in --slack-users or --slack-channels\033[0m\n", m.user, m.channel), "slack user refused", "user", m.user, "channel", m.channel)
+			continue
+		}
+		if m.text == "" {
+			go slackPost(ctx, m.channel, m.thread, "Tell me what to investigate, like: where is the retry logic for uploads?")
+			continue
+		}
+		waiting := len(missions)
+		select {
+		case missions <- m:
+			if waiting > 0 {
+				go slackPost(ctx, m.channel, m.thread, fmt.Sprintf("Queued behind %d other missions.", waiting))
+			}
+		default:
+			go slackPost(ctx, m.channel, m.thread, "The queue is full, try again once some missions have finished.")
+		}
+	}
+}
+
+func runSlackMission(ctx context.Context, tools string, m slackMission) {
+	event(slog.LevelInfo, fmt.Sprintf("\033[34m💬 Slack mission from %s:\033[0m %s\n", m.user, m.text), "slack mission", "user", m.user, "channel", m.channel, "mission", m.text)
+	slackPost(ctx, m.channel, m.thread, "🧭 On it.")
+	var messages []ChatMessage
+	answer, err := runBotMission(ctx, tools, &messages, m.text, func(line string) {
+		slackPost(ctx, m.channel, m.thread, line)
+	})
+	if err != nil {
+		slackPost(ctx, m.channel, m.thread, fmt.Sprintf("⚠️ The mission failed: %v", err))
+		return
+	}
+	result(answer)
+	slackPost(ctx, m.channel, m.thread, answer)
+}
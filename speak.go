@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// With --speak each final answer is read aloud, for missions started before walking away from the screen. Speech
+// comes from an OpenAI-compatible /audio/speech endpoint when --speech-url is set, and otherwise from the OS
+// synthesizer. Markdown is flattened to prose first, code is skipped, and long answers are cut short, since
+// nobody wants a diff read to them.
+var (
+	speak       = flag.Bool("speak", false, "Read each final answer aloud, through --speech-url or the OS speech synthesizer")
+	speechURL   = flag.String("speech-url", "", "Speech endpoint for --speak, e.g. https://api.openai.com/v1/audio/speech (default: the OS synthesizer)")
+	speechVoice = flag.String("speech-voice", "alloy", "Voice for --speech-url")
+	speechModel = flag.String("speech-model", "tts-1", "Speech model for --speech-url")
+)
+
+// synthesizers and audioPlayers are tried in order, and the first one installed is used. TEXT is the text to
+// speak and FILE the WAV file to play; a synthesizer without TEXT reads the text from stdin.
+var (
+	synthesizers = map[string][][]string{
+		"darwin":  {{"say", "-f", "-"}},
+		"windows": {{"powershell.exe", "-NoProfile", "-Command", "Add-Type -AssemblyName System.Speech; (New-Object System.Speech.Synthesis.SpeechSynthesizer).Speak([Console]::In.ReadToEnd())"}},
+		"linux":   {{"espeak-ng", "--stdin"}, {"espeak", "--stdin"}, {"spd-say", "-w", "TEXT"}},
+	}
+	audioPlayers = map[string][][]string{
+		"darwin":  {{"afplay", "FILE"}},
+		"windows": {{"powershell.exe", "-NoProfile", "-Command", "(New-Object Media.SoundPlayer 'FILE').PlaySync()"}},
+		"linux":   {{"paplay", "FILE"}, {"aplay", "-q", "FILE"}, {"ffplay", "-nodisp", "-autoexit", "-loglevel", "error", "FILE"}},
+	}
+)
+
+// maxSpoken is about a minute of speech; the rest of the answer is on screen.
+const maxSpoken = 1000
+
+// speakAnswer reads an answer aloud, returning once it has been spoken.
+func speakAnswer(answer string) {
+	text := spokenText(answer)
+	if text == "" {
+		return
+	}
+	var err error
+	if *speechURL != "" {
+		err = speakRemote(context.Background(), text)
+	} else {
+		err = runSpeech(synthesizers, "TEXT", text)
+	}
+	if err != nil {
+		event(slog.LevelWarn, fmt.Sprintf("\033[33mCould not speak the answer: %v\033[0m\n", err), "speech failed", "err", err)
+	}
+}
+
+// spokenText flattens Markdown into what is worth hearing: prose and list items without their markers, and a
+// mention of each code block in place of its contents.
+func spokenText(answer string) string {
+	var lines []string
+	inFence := false
+	for _, line := range strings.Split(answer, "\n") {
+		if mdFence.MatchString(line) {
+			if !inFence {
+				lines = append(lines, "(code omitted)")
+			}
+			inFence = !inFence
+			continue
+		}
+		if inFence || mdRule.MatchString(line) {
+			continue
+		}
+		if m := mdHeading.FindStringSubmatch(line); m != nil {
+			line = m[2]
+		} else if m := mdBullet.FindStringSubmatch(line); m != nil {
+			line = m[2]
+		} else if m := mdNumber.FindStringSubmatch(line); m != nil {
+			line = m[3]
+		}
+		line = strings.TrimSpace(strings.TrimLeft(stripInline(line), "> "))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	text := strings.Join(lines, "\n")
+	if len(text) <= maxSpoken {
+		return text
+	}
+	// Stop at the last sentence that fits, or the last word.
+	cut := text[:maxSpoken]
+	if end := strings.LastIndexAny(cut, ".!?\n"); end > maxSpoken/2 {
+		cut = cut[:end+1]
+	} else if end := strings.LastIndexByte(cut, ' '); end > 0 {
+		cut = cut[:end]
+	}
+	return cut + "\nThe rest is on screen."
+}
+
+// runSpeech runs the first installed command of the platform's list, with placeholder replaced by value.
+func runSpeech(commands map[string][][]string, placeholder, value string) error {
+	for _, argv := range commands[runtime.GOOS] {
+		if _, err := exec.LookPath(argv[0]); err != nil {
+			continue
+		}
+		args := make([]string, len(argv)-1)
+		replaced := false
+		for i, arg := range argv[1:] {
+			args[i] = strings.ReplaceAll(arg, placeholder, value)
+			replaced = replaced || args[i] != arg
+		}
+		cmd := exec.Command(argv[0], args...)
+		if !replaced {
+			cmd.Stdin = strings.NewReader(value)
+		}
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s: %v %s", argv[0], err, clip(strings.TrimSpace(stderr.String())))
+		}
+		return nil
+	}
+	var names []string
+	for _, argv := range commands[runtime.GOOS] {
+		names = append(names, argv[0])
+	}
+	*speak = false
+	return fmt.Errorf("none of %s is installed, --speak is off", strings.Join(names, ", "))
+}
+
+// speakRemote has the speech endpoint synthesize the text and plays the WAV it returns.
+func speakRemote(ctx context.Context, text string) error {
+	body, _ := json.Marshal(map[string]string{"model": *speechModel, "input": text, "voice": *speechVoice, "response_format": "wav"})
+	req, err := http.NewRequestWithContext(ctx, "POST", *speechURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key := os.Getenv("OPENAI_API_KEY"); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return fmt.Errorf("%s: %s", resp.Status, clip(strings.TrimSpace(string(raw))))
+	}
+
+	dir, err := os.MkdirTemp("", "tinyagent-speech-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "answer.wav")
+	audio, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(audio, io.LimitReader(resp.Body, 64<<20))
+	if closeErr := audio.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return runSpeech(audioPlayers, "FILE", path)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// The sql tools let a mission about the data model consult the real schema: list the tables, describe one
+// with its columns and indexes, and run a SELECT with a row limit or an EXPLAIN. The database comes from
+// DATABASE_URL, postgres:// or mysql://, and is reached through psql or mysql, so no driver is built in.
+// Sessions are read-only on the server side as well as checked here.
+const sqlToolDef = `[
+		{"type":"function","function":{"name":"sql_tables","description":"List the tables and views in the database.","parameters":{"type":"object","properties":{}}}},
+		{"type":"function","function":{"name":"sql_describe","description":"Describe a table: its columns with types, nullability, and defaults, and its indexes.","parameters":{"type":"object","properties":{
+			"table":{"type":"string","description":"Table name, optionally schema.table"} },"required":["table"]}}},
+		{"type":"function","function":{"name":"sql_query","description":"Run one read-only SELECT, WITH, or EXPLAIN statement. SELECT results are cut to the row limit.","parameters":{"type":"object","properties":{
+			"query":{"type":"string","description":"A single SQL statement"},
+			"limit":{"type":"integer","default":50,"description":"Maximum rows returned, at most 500"} },"required":["query"]}}}
+		]`
+
+const (
+	maxSQLRows   = 500
+	maxSQLOutput = 32 << 10
+)
+
+// sqlUserTable leaves out the catalog schemas of both databases.
+const sqlUserTable = `table_schema NOT IN ('pg_catalog', 'information_schema', 'mysql', 'performance_schema', 'sys')`
+
+var (
+	sqlTable    = regexp.MustCompile(`^(?:([A-Za-z_][\w$]*)\.)?([A-Za-z_][\w$]*)$`)
+	sqlReadOnly = regexp.MustCompile(`(?i)^\s*(select|with|explain)\b`)
+)
+
+// sqlDatabase returns DATABASE_URL parsed, and which client it needs, or "" when it isn't usable.
+func sqlDatabase() (*url.URL, string) {
+	u, err := url.Parse(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return nil, ""
+	}
+	switch u.Scheme {
+	case "postgres", "postgresql":
+		return u, "psql"
+	case "mysql":
+		return u, "mysql"
+	}
+	return nil, ""
+}
+
+var sqlTools = registerToolset(&toolset{
+	def: sqlToolDef,
+	enabled: func() bool {
+		_, client := sqlDatabase()
+		if client == "" {
+			return false
+		}
+		_, err := exec.LookPath(client)
+		return err == nil
+	},
+	readOnly: true,
+	run: map[string]func(context.Context, string) (string, error){
+		"sql_tables": func(ctx context.Context, args string) (string, error) {
+			return sqlRun(ctx, "sql_tables", "SELECT table_schema, table_name, table_type FROM information_schema.tables WHERE "+sqlUserTable+" ORDER BY 1, 2")
+		},
+		"sql_describe": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Table string `json:"table"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			return sqlDescribe(ctx, params.Table)
+		},
+		"sql_query": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Query string `json:"query"`
+				Limit int    `json:"limit"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			return sqlQuery(ctx, params.Query, params.Limit)
+		},
+	},
+})
+
+func sqlDescribe(ctx context.Context, table string) (string, error) {
+	m := sqlTable.FindStringSubmatch(table)
+	if m == nil {
+		return "", fmt.Errorf("Permanent Error: %q is not a table name", table)
+	}
+	where := fmt.Sprintf("table_name = '%s'", m[2])
+	if m[1] != "" {
+		where += fmt.Sprintf(" AND table_schema = '%s'", m[1])
+	} else {
+		where += " AND " + sqlUserTable
+	}
+	indexes := fmt.Sprintf("SELECT index_name, non_unique, column_name, seq_in_index FROM information_schema.statistics WHERE %s ORDER BY 1, 4", where)
+	if _, client := sqlDatabase(); client == "psql" {
+		indexes = fmt.Sprintf("SELECT indexname, indexdef FROM pg_indexes WHERE %s ORDER BY 1", strings.NewReplacer("table_name", "tablename", "table_schema", "schemaname").Replace(where))
+	}
+	return sqlRun(ctx, "sql_describe "+table,
+		fmt.Sprintf("SELECT column_name, data_type, is_nullable, column_default FROM information_schema.columns WHERE %s ORDER BY ordinal_position", where),
+		indexes)
+}
+
+func sqlQuery(ctx context.Context, query string, limit int) (string, error) {
+	query = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(query), ";"))
+	if !sqlReadOnly.MatchString(query) {
+		return "", fmt.Errorf("Permanent Error: only SELECT, WITH, and EXPLAIN statements are allowed")
+	}
+	// One statement only, so nothing can follow the check; a semicolon inside a string literal is refused too.
+	if strings.Contains(query, ";") {
+		return "", fmt.Errorf("Permanent Error: run one statement per call, without semicolons")
+	}
+	// The clients read a backslash as one of their own commands, and some of those run shell commands.
+	if strings.Contains(query, `\`) {
+		return "", fmt.Errorf("Permanent Error: backslashes are not allowed in queries")
+	}
+	limit = min(max(limit, 1), maxSQLRows)
+	if !strings.EqualFold(strings.Fields(query)[0], "explain") {
+		// The newline ends a trailing -- comment, which would otherwise swallow the closing parenthesis.
+		query = fmt.Sprintf("SELECT * FROM (%s\n) AS tinyagent_query LIMIT %d", query, limit)
+	}
+	return sqlRun(ctx, "sql_query", query)
+}
+
+// mysqlSystemCommandSwitch reports whether the mysql client has --system-command, which only recent ones do.
+var mysqlSystemCommandSwitch = sync.OnceValue(func() bool {
+	help, _ := exec.Command("mysql", "--help").Output()
+	return bytes.Contains(help, []byte("--system-command"))
+})
+
+// sqlRun runs statements through the database's client in a read-only session and returns the tables printed.
+func sqlRun(ctx context.Context, label string, statements ...string) (string, error) {
+	u, client := sqlDatabase()
+	if u == nil {
+		return "", fmt.Errorf("Permanent Error: DATABASE_URL is not a postgres:// or mysql:// URL")
+	}
+	// The password goes in the environment rather than on the command line, where ps shows it.
+	env := os.Environ()
+	password, hasPassword := u.User.Password()
+	var cmd *exec.Cmd
+	if client == "psql" {
+		dsn := *u
+		if dsn.User != nil {
+			dsn.User = url.User(u.User.Username())
+		}
+		if hasPassword {
+			env = append(env, "PGPASSWORD="+password)
+		}
+		args := []string{"--no-psqlrc", "--quiet", "--pset", "pager=off", "--set", "ON_ERROR_STOP=1", "--dbname", dsn.String()}
+		for _, s := range statements {
+			args = append(args, "--command", s)
+		}
+		cmd = exec.CommandContext(ctx, "psql", args...)
+		env = append(env, "PGOPTIONS=-c default_transaction_read_only=on", "PGCONNECT_TIMEOUT=10")
+	} else {
+		args := []string{"--table", "--connect-timeout=10", "--host", u.Hostname(), "--user", u.User.Username()}
+		if port := u.Port(); port != "" {
+			args = append(args, "--port", port)
+		}
+		// The script goes on stdin in binary mode, and with shell commands off where the client has the switch,
+		// so nothing in it is taken for a client command.
+		args = append(args, "--binary-mode")
+		if mysqlSystemCommandSwitch() {
+			args = append(args, "--system-command=OFF")
+		}
+		script := "SET SESSION TRANSACTION READ ONLY;\n" + strings.Join(statements, ";\n") + ";\n"
+		cmd = exec.CommandContext(ctx, "mysql", append(args, strings.TrimPrefix(u.Path, "/"))...)
+		cmd.Stdin = strings.NewReader(script)
+		if hasPassword {
+			env = append(env, "MYSQL_PWD="+password)
+		}
+	}
+	cmd.Env = env
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("%s failed: %s", client, clip(msg))
+	}
+	return fmt.Sprintf("%s results\n%s", label, clipOutput(string(out), maxSQLOutput, false)), nil
+}
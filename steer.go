@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// A running mission can be steered without cancelling it: pressing Enter while it works pauses it once the
+// current tool calls finish, and the guidance typed then joins the conversation as a user message before the
+// next turn. A line typed before Enter is taken as the guidance directly.
+//
+// That means reading the terminal while nothing is asking, so one goroutine owns stdin and every reader goes
+// through it. It only reads while something wants input, so an editor started by /edit gets every keystroke.
+var (
+	stdinMu     sync.Mutex
+	stdinWanted = sync.NewCond(&stdinMu)
+	readers     int  // readers blocked waiting for input
+	watching    bool // a mission is running, so input is read ahead to steer it
+	stdinChunks = make(chan []byte, 64)
+	unread      []byte // the rest of the chunk the last reader took
+)
+
+const steerFormat = "Guidance from the user, follow it for the rest of the mission: %s"
+
+func init() {
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			stdinMu.Lock()
+			for readers == 0 && !watching {
+				stdinWanted.Wait()
+			}
+			stdinMu.Unlock()
+
+			n, err := os.Stdin.Read(buf)
+			stdinMu.Lock()
+			ahead := readers == 0 && watching
+			stdinMu.Unlock()
+			if n > 0 {
+				stdinChunks <- append([]byte(nil), buf[:n]...)
+				if ahead {
+					termMu.Lock()
+					lead := map[bool]string{true: "", false: "\n"}[lineStart]
+					termMu.Unlock()
+					event(slog.LevelInfo, lead+"\033[90m⏸  Pausing after the current tool calls\033[0m\n", "steering requested")
+				}
+			}
+			if err != nil {
+				close(stdinChunks)
+				return
+			}
+		}
+	}()
+}
+
+// stdinReader reads what the stdin goroutine delivers.
+type stdinReader struct{}
+
+func (stdinReader) Read(p []byte) (int, error) {
+	if len(unread) == 0 {
+		stdinMu.Lock()
+		readers++
+		stdinWanted.Signal()
+		stdinMu.Unlock()
+		chunk, ok := <-stdinChunks
+		stdinMu.Lock()
+		readers--
+		stdinMu.Unlock()
+		if !ok {
+			return 0, io.EOF
+		}
+		unread = chunk
+	}
+	n := copy(p, unread)
+	unread = unread[n:]
+	return n, nil
+}
+
+// watchInput turns reading ahead on while a mission runs and off at the prompt. Piped input is never read
+// ahead, since the lines after a mission are the next missions, not guidance. Guidance that arrived too late
+// for the mission it was meant for is dropped rather than taken as the next mission.
+func watchInput(on bool) {
+	stdinMu.Lock()
+	wasWatching := watching
+	watching = on && isTerminal(os.Stdin)
+	stdinWanted.Signal()
+	stdinMu.Unlock()
+	if wasWatching && !on {
+		for unread = nil; len(stdinChunks) > 0; {
+			<-stdinChunks
+		}
+	}
+}
+
+// steer runs between turns. If the user pressed Enter during the last one, it asks for guidance, adding it to
+// messages, and the mission resumes either way.
+func steer(messages *[]ChatMessage) {
+	if len(unread) == 0 && len(stdinChunks) == 0 {
+		return
+	}
+	line, ok := ask("")
+	if ok && strings.TrimSpace(line) == "" {
+		line, ok = askMultiline("\033[34m⏸  Paused.\033[90m Guidance for the agent (blank to resume) > \033[0m", nil)
+	}
+	line = strings.TrimSpace(line)
+	if !ok || line == "" {
+		event(slog.LevelInfo, "\033[90m▶  Resuming\033[0m\n", "steering skipped")
+		return
+	}
+	event(slog.LevelInfo, fmt.Sprintf("\033[34m🧭 Steering:\033[0m %s\n", line), "steering", "guidance", line)
+	*messages = append(*messages, ChatMessage{Role: "user", Content: fmt.Sprintf(steerFormat, line)})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// maxStudyFiles caps one study_files call, since every file costs its own summarization request.
+const maxStudyFiles = 24
+
+// studyFiles answers one question about many files by fanning the per-file studies out over the tool worker
+// pool and merging the answers in input order, replacing a dozen sequential single-file turns.
+func studyFiles(ctx context.Context, paths []string, glob, question string) (string, error) {
+	if glob != "" {
+		matches, err := globFiles(glob)
+		if err != nil {
+			return "", err
+		}
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		return "", fmt.Errorf("No files given or matched, pass paths or a glob")
+	}
+	var note string
+	if len(paths) > maxStudyFiles {
+		note = fmt.Sprintf("\n(only the first %d of %d files were studied, narrow the selection to see the rest)", maxStudyFiles, len(paths))
+		paths = paths[:maxStudyFiles]
+	}
+
+	answers := make([]string, len(paths))
+	sem := make(chan struct{}, max(*toolWorkers, 1))
+	var wg sync.WaitGroup
+	var finished atomic.Int32
+	for i, path := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			answer, err := studyFile(ctx, path, 0, 0, question)
+			if err != nil {
+				answer = fmt.Sprintf("study_file_contents %s failed: %v", toolPath(path), err)
+			}
+			answers[i] = answer
+			progress(ctx, fmt.Sprintf("%d/%d studied %s", finished.Add(1), len(paths), toolPath(path)))
+		}()
+	}
+	wg.Wait()
+
+	return fmt.Sprintf("study_files results for %d files\nQuestion: %s\n\n%s%s", len(paths), question, strings.Join(answers, "\n\n"), note), nil
+}
+
+// globFiles expands a slash-separated glob relative to the working directory. Unlike filepath.Glob it
+// understands ** for any number of directories, which is how people naturally describe "all Go files".
+func globFiles(pattern string) ([]string, error) {
+	pattern = filepath.ToSlash(pattern)
+	if !filepath.IsLocal(pattern) {
+		return nil, fmt.Errorf("Permanent Error: Glob %s is outside of current working directory", pattern)
+	}
+
+	var re strings.Builder
+	re.WriteString("^")
+	// Runes, not bytes, so a non-ASCII name is quoted whole; skip steps over the rest of a ** just handled.
+	skip := 0
+	for i, c := range pattern {
+		switch {
+		case skip > 0:
+			skip--
+		case strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(.*/)?")
+			skip = 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			skip = 1
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	matcher, err := regexp.Compile(re.String())
+	if err != nil {
+		return nil, fmt.Errorf("Invalid glob %s: %v", pattern, err)
+	}
+
+	var matches []string
+	err = fs.WalkDir(workspace, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && path != "." && strings.HasPrefix(d.Name(), ".") {
+			return fs.SkipDir
+		}
+		if !d.IsDir() && matcher.MatchString(filepath.ToSlash(path)) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	return matches, err
+}
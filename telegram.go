@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --telegram is for sending yourself missions from a phone: messages to the bot from the --telegram-users become
+// missions, one status message is kept up to date with the latest tool calls, and the answer arrives as a reply.
+// Everyone else is ignored. The session stays read-only from Telegram even with --allow-writes unless
+// --telegram-writes is given too, in which case each change is sent as a diff to answer yes, no, or what to do
+// instead. TELEGRAM_BOT_TOKEN comes from @BotFather.
+var (
+	telegramMode   = flag.Bool("telegram", false, "Run as a Telegram bot, taking missions from the --telegram-users (needs TELEGRAM_BOT_TOKEN)")
+	telegramUsers  = flag.String("telegram-users", "", "Comma-separated numeric IDs of the Telegram users the bot takes missions from")
+	telegramWrites = flag.Bool("telegram-writes", false, "With --allow-writes, offer the write tools to Telegram missions too, approving each change in the chat")
+	telegramAPI    = flag.String("telegram-api", "https://api.telegram.org", "Telegram Bot API base URL")
+)
+
+const (
+	maxTelegramText  = 4096 // Telegram rejects longer messages
+	telegramPoll     = 50   // seconds a getUpdates call waits for news
+	telegramProgress = 8    // tool calls kept in the status message
+
+	telegramApprovalWait = 30 * time.Minute
+)
+
+type telegramMessage struct {
+	MessageID int64 `json:"message_id"`
+	From      struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+	} `json:"from"`
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	Text string `json:"text"`
+}
+
+// telegramBot is the state shared between the update poller and the mission worker.
+type telegramBot struct {
+	token   string
+	allowed map[int64]bool
+
+	mu      sync.Mutex
+	pending map[int64]chan string // chats where a change waits for an answer
+}
+
+// call invokes a Bot API method, which reports failure in the body with "ok": false.
+func (b *telegramBot) call(ctx context.Context, method string, body, v any) error {
+	var reply struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	url := fmt.Sprintf("%s/bot%s/%s", strings.TrimSuffix(*telegramAPI, "/"), b.token, method)
+	if err := sendJSON(ctx, "POST", url, nil, body, &reply); err != nil {
+		// The token is part of the URL, so it must not reach the log with the error.
+		return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), b.token, "…"))
+	}
+	if !reply.OK {
+		return fmt.Errorf("telegram %s: %s", method, reply.Description)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(reply.Result, v)
+}
+
+// send posts text to a chat, split to fit, as a reply to a message when reply is set, and returns the last
+// message's ID. Failing to send is reported but never stops the bot.
+func (b *telegramBot) send(ctx context.Context, chat, reply int64, text string) int64 {
+	var sent telegramMessage
+	for _, part := range splitMessage(text, maxTelegramText) {
+		body := map[string]any{"chat_id": chat, "text": part}
+		if reply != 0 {
+			body["reply_to_message_id"] = reply
+		}
+		if err := b.call(ctx, "sendMessage", body, &sent); err != nil {
+			event(slog.LevelWarn, fmt.Sprintf("\033[33mCould not send to Telegram: %v\033[0m\n", err), "telegram send failed", "chat", chat, "err", err)
+			return 0
+		}
+	}
+	return sent.MessageID
+}
+
+// runTelegram serves missions from Telegram until ctx ends. Only the poller talks to getUpdates; missions run
+// one after another on their own goroutine, so answers to a pending change still get through.
+func runTelegram(ctx context.Context, tools string) error {
+	b := &telegramBot{token: os.Getenv("TELEGRAM_BOT_TOKEN"), allowed: map[int64]bool{}, pending: map[int64]chan string{}}
+	if b.token == "" {
+		return fmt.Errorf("--telegram needs TELEGRAM_BOT_TOKEN")
+	}
+	for _, field := range strings.Split(*telegramUsers, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return fmt.Errorf("--telegram-users: %q is not a numeric user ID", field)
+		}
+		b.allowed[id] = true
+	}
+	if len(b.allowed) == 0 {
+		return fmt.Errorf("--telegram needs --telegram-users, the IDs of the people it takes missions from")
+	}
+	if !*telegramWrites {
+		tools = offeredTools(false)
+	}
+	missions := make(chan telegramMessage, 64)
+	go func() {
+		for m := range missions {
+			b.runMission(ctx, tools, m)
+		}
+	}()
+	event(slog.LevelInfo, "\033[32mListening on Telegram, waiting for missions\033[0m\n", "telegram connected")
+
+	offset, backoff := int64(0), time.Second
+	for ctx.Err() == nil {
+		var updates []struct {
+			UpdateID int64            `json:"update_id"`
+			Message  *telegramMessage `json:"message"`
+		}
+		err := b.call(ctx, "getUpdates", map[string]any{"offset": offset, "timeout": telegramPoll, "allowed_updates": []string{"message"}}, &updates)
+		if err != nil {
+			event(slog.LevelWarn, fmt.Sprintf("\033[33mTelegram polling failed: %v, retrying in %s\033[0m\n", err, backoff), "telegram poll failed", "err", err, "retry_in", backoff.String())
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, time.Minute)
+			continue
+		}
+		backoff = time.Second
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			m := u.Message
+			if m == nil || strings.TrimSpace(m.Text) == "" {
+				continue
+			}
+			if !b.allowed[m.From.ID] {
+				event(slog.LevelWarn, fmt.Sprintf("\033[33mIgnored a Telegram message from user %d, who is not in --telegram-users\033[0m\n", m.From.ID), "telegram user refused", "user", m.From.ID)
+				continue
+			}
+			b.mu.Lock()
+			answer := b.pending[m.Chat.ID]
+			b.mu.Unlock()
+			switch {
+			case answer != nil:
+				select {
+				case answer <- m.Text:
+				default:
+				}
+			case m.Text == "/start" || m.Text == "/help":
+				go b.send(ctx, m.Chat.ID, 0, "Send me a mission, like: where is the retry logic for uploads?")
+			default:
+				// A full queue turns the mission away, since blocking here would stop the answers approvals wait on.
+				waiting := len(missions)
+				select {
+				case missions <- *m:
+					if waiting > 0 {
+						go b.send(ctx, m.Chat.ID, m.MessageID, fmt.Sprintf("Queued behind %d other missions.", waiting))
+					}
+				default:
+					go b.send(ctx, m.Chat.ID, m.MessageID, "The queue is full, try again once some missions have finished.")
+				}
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+func (b *telegramBot) runMission(ctx context.Context, tools string, m telegramMessage) {
+	event(slog.LevelInfo, fmt.Sprintf("\033[34m💬 Telegram mission from %s:\033[0m %s\n", m.From.Username, m.Text), "telegram mission", "user", m.From.ID, "mission", m.Text)
+	status := b.send(ctx, m.Chat.ID, m.MessageID, "🧭 On it.")
+	var mu sync.Mutex // read-only tools report in parallel
+	var recent []string
+	progress := func(line string) {
+		mu.Lock()
+		defer mu.Unlock()
+		recent = append(recent, line)
+		recent = recent[max(0, len(recent)-telegramProgress):]
+		if status != 0 {
+			body := map[string]any{"chat_id": m.Chat.ID, "message_id": status, "text": "🧭 On it.\n" + strings.Join(recent, "\n")}
+			if err := b.call(ctx, "editMessageText", body, nil); err != nil {
+				event(slog.LevelWarn, fmt.Sprintf("\033[33mCould not update the Telegram status: %v\033[0m\n", err), "telegram edit failed", "err", err)
+			}
+		}
+	}
+	missionCtx := withApprover(ctx, func(question, diff string) string { return b.approve(ctx, m.Chat.ID, question, diff) })
+	var messages []ChatMessage
+	answer, err := runBotMission(missionCtx, tools, &messages, m.Text, progress)
+	if err != nil {
+		b.send(ctx, m.Chat.ID, m.MessageID, fmt.Sprintf("⚠️ The mission failed: %v", err))
+		return
+	}
+	result(answer)
+	b.send(ctx, m.Chat.ID, m.MessageID, answer)
+}
+
+// approve sends a proposed change to the chat and waits for the next message, answered like the terminal prompt.
+// Write tools are only offered with --telegram-writes, so anything else reaching here is refused. Nobody
+// answering in time counts as a rejection.
+func (b *telegramBot) approve(ctx context.Context, chat int64, question, diff string) string {
+	if !*telegramWrites {
+		return "Changes cannot be made from Telegram"
+	}
+	answer := make(chan string, 1)
+	b.mu.Lock()
+	b.pending[chat] = answer
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.pending, chat)
+		b.mu.Unlock()
+	}()
+	b.send(ctx, chat, 0, fmt.Sprintf("%s\n\n%s\n\nReply yes, no, or what to do instead.", question, strings.TrimRight(diff, "\n")))
+	select {
+	case a := <-answer:
+		return a
+	case <-time.After(telegramApprovalWait):
+		b.send(ctx, chat, 0, "No answer, so the change was not applied.")
+		return "n"
+	case <-ctx.Done():
+		return "n"
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// The go_test tool runs a Go module's tests so the agent can check code it wrote rather than hope it compiles.
+// Tests run arbitrary code, so it is offered with --allow-writes and asks first, like a change.
+const goTestToolDef = `[
+		{"type":"function","function":{"name":"go_test","description":"Run go test on a package and show the output, including compile errors and failures. The user approves each run.","parameters":{"type":"object","properties":{
+			"package":{"type":"string","default":"./...","description":"Package pattern, such as ./internal/parse or ./..."},
+			"run":{"type":"string","description":"Optional regular expression selecting the tests to run, as for go test -run"} }}}}
+		]`
+
+const maxGoTestOutput = 32 << 10
+
+var goTestTools = registerToolset(&toolset{
+	def: goTestToolDef,
+	enabled: func() bool {
+		_, err := workspace.Stat("go.mod")
+		return err == nil && *allowWrites
+	},
+	writes: true,
+	run: map[string]func(context.Context, string) (string, error){
+		"go_test": func(ctx context.Context, args string) (string, error) {
+			var params struct {
+				Package string `json:"package"`
+				Run     string `json:"run"`
+			}
+			json.Unmarshal([]byte(args), &params)
+			return goTest(ctx, params.Package, params.Run)
+		},
+	},
+})
+
+func goTest(ctx context.Context, pkg, run string) (string, error) {
+	if pkg == "" {
+		pkg = "./..."
+	}
+	if strings.HasPrefix(pkg, "-") {
+		return "", fmt.Errorf("Permanent Error: %q is not a package pattern", pkg)
+	}
+	argv := []string{"test", "-count=1", "-timeout=5m"}
+	if run != "" {
+		argv = append(argv, "-run="+run)
+	}
+	argv = append(argv, pkg)
+	question := fmt.Sprintf("Run go %s?", strings.Join(argv, " "))
+	event(slog.LevelInfo, fmt.Sprintf("\n\033[90m🧪 %s\033[0m\n", question), "proposed go test", "package", pkg, "run", run)
+	switch answer := approve(ctx, question, ""); strings.ToLower(answer) {
+	case "y", "yes":
+	case "", "n", "no":
+		return "The user rejected running the tests.", nil
+	default:
+		return "The user rejected running the tests and said: " + answer, nil
+	}
+	out, err := workCommand(ctx, "go", argv...).CombinedOutput()
+	status := "passed"
+	if err != nil {
+		status = "failed: " + err.Error()
+	}
+	return fmt.Sprintf("go_test %s results (%s)\n%s", pkg, status, clipOutput(string(out), maxGoTestOutput, false)), nil
+}
+
+// `tinyagent gen-tests ./internal/parse [ParseDate]` writes table-driven tests for a package, or one function in
+// it, and runs them until they compile and pass. Only _test.go files may change and each run is approved
+// automatically, since iterating is the point; every diff is still shown. The mission stops at --max-turns,
+// 40 unless given, and the exit code says whether the package's tests pass at the end.
+const genTestsMissionFormat = `Write table-driven Go tests for %s.
+1. Study the code under test and the tests already there, to learn what it does and how this package tests things.
+2. Write the tests in _test.go files next to the code, in the same package. Use a table of cases run with t.Run, covering normal inputs, edge cases, and errors. Use the standard testing package unless the module already uses another library for tests.
+3. Run them with go_test on the package.
+4. If they don't compile or a case fails, decide whether the test or the code is wrong. Fix the test; if the code is wrong, leave it alone and remove or correct that case, and note the bug. Run go_test again, and repeat until everything passes.
+Finish with the test files written, what they cover, and any bugs found in the code.`
+
+const genTestsTurns = 40
+
+// genTestsApproved matches the approval questions of go_test runs and of changes to _test.go files.
+var genTestsApproved = regexp.MustCompile(`^(Run go test .*|Apply this change to .*_test\.go)\?$`)
+
+// runGenTests implements the gen-tests subcommand and returns the process exit code.
+func runGenTests(args []string) int {
+	// Flags may come before, between, or after the package and function.
+	var positional []string
+	for rest := args; ; rest = flag.Args()[1:] {
+		flag.CommandLine.Parse(rest)
+		if flag.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flag.Arg(0))
+	}
+	if len(positional) == 0 || len(positional) > 2 {
+		fmt.Fprintln(os.Stderr, "usage: tinyagent gen-tests [flags] ./package [function]")
+		return 2
+	}
+	pkg := positional[0]
+	if !strings.HasPrefix(pkg, ".") {
+		pkg = "./" + pkg
+	}
+	if err := setupUI(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if err := connectRemote(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if _, err := workspace.Stat("go.mod"); err != nil {
+		fmt.Fprintln(os.Stderr, "tinyagent gen-tests: run it at the root of a Go module, where go.mod is")
+		return 2
+	}
+	*allowWrites = true
+	if *maxTurns == 0 {
+		*maxTurns = genTestsTurns
+	}
+	writeGuard = func(path string) error {
+		if !strings.HasSuffix(path, "_test.go") {
+			return fmt.Errorf("Permanent Error: only _test.go files may change while generating tests, not %s", toolPath(path))
+		}
+		return nil
+	}
+
+	subject := "the package " + pkg
+	if len(positional) == 2 {
+		subject = fmt.Sprintf("the function %s in the package %s", positional[1], pkg)
+	}
+	// Only test files and test runs are approved; anything else that asks, such as a toolset's change, is refused.
+	ctx := withApprover(context.Background(), func(question, diff string) string {
+		if genTestsApproved.MatchString(question) {
+			return "y"
+		}
+		return "n"
+	})
+	var messages []ChatMessage
+	tools := joinToolDefs(toolDef, writeToolDef, goTestToolDef)
+	answer, err := runBotMission(ctx, tools, &messages, fmt.Sprintf(genTestsMissionFormat, subject), nil)
+	if err == nil {
+		result(answer)
+	}
+	exportReport(messages)
+
+	// The model's word that the tests pass is checked by running them once more.
+	out, testErr := workCommand(context.Background(), "go", "test", "-count=1", pkg).CombinedOutput()
+	if testErr != nil {
+		event(slog.LevelError, fmt.Sprintf("\033[31mThe tests of %s do not pass:\033[0m\n%s", pkg, out), "tests failing", "package", pkg, "output", string(out))
+		return 1
+	}
+	event(slog.LevelInfo, fmt.Sprintf("\033[32mThe tests of %s pass\033[0m\n", pkg), "tests passing", "package", pkg)
+	if err != nil {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Every tool call is shown the same way: the tool's name, then each argument on its own line, then a short preview
+// of what came back. One block per call, printed whole, keeps parallel calls readable and always shows which
+// question went to which file.
+const (
+	previewLines = 3   // result lines shown under a call
+	previewWidth = 100 // characters kept of one argument or result line
+)
+
+// showToolCall prints the header block of a call as it starts.
+func showToolCall(name, args string) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\033[90m🔧 \033[36m%s\033[0m\n", name)
+	for _, arg := range toolArgs(args) {
+		fmt.Fprintf(&b, "\033[90m  │ %s: \033[35m%s\033[0m\n", arg[0], arg[1])
+	}
+	event(slog.LevelInfo, b.String(), "tool call", "tool", name, "arguments", args)
+}
+
+// showToolResult prints the outcome of a call under its header: the error, or the first lines of the result.
+func showToolResult(name, res string, err error, elapsed time.Duration) {
+	if err != nil {
+		event(slog.LevelWarn, fmt.Sprintf("\033[90m  └ \033[31m✗ %s failed after %.1fs: %v\033[0m\n", name, elapsed.Seconds(), err),
+			"tool failed", "tool", name, "seconds", elapsed.Seconds(), "err", err)
+		return
+	}
+	lines := strings.Split(strings.TrimRight(res, "\n"), "\n")
+	var b strings.Builder
+	fmt.Fprintf(&b, "\033[90m  └ \033[32m✓\033[90m %s in %.1fs, %d lines\033[0m\n", name, elapsed.Seconds(), len(lines))
+	for _, line := range lines[:min(len(lines), previewLines)] {
+		fmt.Fprintf(&b, "\033[90m    %s\033[0m\n", clip(line))
+	}
+	if len(lines) > previewLines {
+		fmt.Fprintf(&b, "\033[90m    … %d more lines\033[0m\n", len(lines)-previewLines)
+	}
+	event(slog.LevelInfo, b.String(), "tool result", "tool", name, "ok", true, "seconds", elapsed.Seconds(), "bytes", len(res))
+}
+
+// toolArgs lists a call's arguments in the order the model wrote them, each value flattened to one short line.
+func toolArgs(args string) [][2]string {
+	dec := json.NewDecoder(strings.NewReader(args))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return [][2]string{{"arguments", clip(args)}}
+	}
+	var out [][2]string
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			break
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			break
+		}
+		name, _ := key.(string)
+		out = append(out, [2]string{name, argValue(raw)})
+	}
+	return out
+}
+
+func argValue(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		if n := strings.Count(s, "\n"); n > 0 {
+			return fmt.Sprintf("%s (%d lines)", clip(s[:strings.IndexByte(s, '\n')]), n+1)
+		}
+		return clip(s)
+	}
+	var list []any
+	if json.Unmarshal(raw, &list) == nil {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return clip(strings.Join(items, ", "))
+	}
+	var compact bytes.Buffer
+	if json.Compact(&compact, raw) == nil {
+		return clip(compact.String())
+	}
+	return clip(string(raw))
+}
+
+// clip shortens s to previewWidth characters, cutting on a rune boundary.
+func clip(s string) string {
+	s = strings.TrimRight(s, "\r")
+	if utf8.RuneCountInString(s) <= previewWidth {
+		return s
+	}
+	return string([]rune(s)[:previewWidth-1]) + "…"
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Integrations beyond the core file tools register a toolset: their definitions, a check for whether they can
+// work in this session (a token is set, the repo has the right remote), and a handler per tool. Only enabled
+// toolsets are offered, so the model never sees a tool that is bound to fail.
+type toolset struct {
+	def      string // JSON array of tool definitions, like toolDef
+	enabled  func() bool
+	readOnly bool // whether its tools may run concurrently with other read-only tools
+	writes   bool // whether its tools change things, so it is offered only where the write tools are
+	run      map[string]func(ctx context.Context, args string) (string, error)
+}
+
+var toolsets []*toolset
+
+// registerToolset adds ts; integrations call it from a package-level var so it happens before main runs.
+func registerToolset(ts *toolset) *toolset {
+	toolsets = append(toolsets, ts)
+	if ts.readOnly {
+		for name := range ts.run {
+			readOnlyTools[name] = true
+		}
+	}
+	return ts
+}
+
+// toolsetFor returns the toolset that handles the named tool, or nil.
+func toolsetFor(name string) *toolset {
+	for _, ts := range toolsets {
+		if _, ok := ts.run[name]; ok {
+			return ts
+		}
+	}
+	return nil
+}
+
+// joinToolDefs concatenates JSON arrays of tool definitions into one.
+func joinToolDefs(defs ...string) string {
+	var parts []string
+	for _, def := range defs {
+		def = strings.TrimSpace(def)
+		if inner := strings.TrimSpace(def[1 : len(def)-1]); inner != "" {
+			parts = append(parts, inner)
+		}
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+// getJSON fetches url with the given headers and decodes the JSON response into v. Errors carry the start of the
+// response body, which is where APIs explain a bad token or a missing resource.
+func getJSON(ctx context.Context, url string, header http.Header, v any) error {
+	return sendJSON(ctx, "GET", url, header, nil, v)
+}
+
+// sendJSON is getJSON for any method, with an optional JSON request body.
+func sendJSON(ctx context.Context, method, url string, header http.Header, body, v any) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = strings.NewReader(string(raw))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s: %s: %s", method, url, resp.Status, clip(strings.TrimSpace(string(raw))))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// clipOutput bounds a command's output to limit bytes, keeping its start, or its end when keepEnd is set, as for
+// logs, where the newest lines are last.
+func clipOutput(text string, limit int, keepEnd bool) string {
+	switch {
+	case strings.TrimSpace(text) == "":
+		return "(no output)\n"
+	case len(text) <= limit:
+		return text
+	case keepEnd:
+		return fmt.Sprintf("[%d earlier bytes cut]\n%s", len(text)-limit, strings.ToValidUTF8(text[len(text)-limit:], ""))
+	default:
+		return fmt.Sprintf("%s\n[%d more bytes cut]", strings.ToValidUTF8(text[:limit], ""), len(text)-limit)
+	}
+}
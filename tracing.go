@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracing speaks OTLP/HTTP JSON directly rather than pulling in the OpenTelemetry SDK, keeping the binary
+// dependency free. Any collector (Jaeger, Tempo, Honeycomb, otel-collector) accepts this format on :4318.
+var otlpEndpoint = flag.String("otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "OTLP/HTTP collector URL for traces, e.g. http://localhost:4318 (empty disables tracing)")
+
+type span struct {
+	traceID    [16]byte
+	id, parent [8]byte
+	name       string
+	start, end time.Time
+	attrs      map[string]any
+	err        error
+}
+
+type spanKey struct{}
+
+var (
+	spansMu sync.Mutex
+	spans   []*span
+)
+
+// startSpan begins a child of the span carried by ctx, or a new trace if there is none.
+// It returns a nil span when tracing is disabled; all span methods are nil-safe.
+func startSpan(ctx context.Context, name string, attrs ...any) (context.Context, *span) {
+	if *otlpEndpoint == "" {
+		return ctx, nil
+	}
+	s := &span{name: name, start: time.Now(), attrs: map[string]any{}}
+	if parent, ok := ctx.Value(spanKey{}).(*span); ok {
+		s.traceID, s.parent = parent.traceID, parent.id
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.id[:])
+	for i := 0; i+1 < len(attrs); i += 2 {
+		s.set(fmt.Sprint(attrs[i]), attrs[i+1])
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (s *span) set(key string, value any) {
+	if s != nil {
+		s.attrs[key] = value
+	}
+}
+
+// finish records the span's end time and queues it for the next flush.
+func (s *span) finish(err error) {
+	if s == nil {
+		return
+	}
+	s.end, s.err = time.Now(), err
+	spansMu.Lock()
+	spans = append(spans, s)
+	spansMu.Unlock()
+}
+
+// flushSpans exports all finished spans. It is called between turns so a crash loses at most one turn of data.
+func flushSpans() {
+	spansMu.Lock()
+	batch := spans
+	spans = nil
+	spansMu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	out := make([]map[string]any, 0, len(batch))
+	for _, s := range batch {
+		otlp := map[string]any{
+			"traceId":           hex.EncodeToString(s.traceID[:]),
+			"spanId":            hex.EncodeToString(s.id[:]),
+			"name":              s.name,
+			"kind":              1,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attrs),
+		}
+		if s.parent != [8]byte{} {
+			otlp["parentSpanId"] = hex.EncodeToString(s.parent[:])
+		}
+		if s.err != nil {
+			otlp["status"] = map[string]any{"code": 2, "message": s.err.Error()}
+		}
+		out = append(out, otlp)
+	}
+
+	body, _ := json.Marshal(map[string]any{"resourceSpans": []any{map[string]any{
+		"resource":   map[string]any{"attributes": otlpAttributes(map[string]any{"service.name": "tinyagent"})},
+		"scopeSpans": []any{map[string]any{"scope": map[string]any{"name": "tinyagent"}, "spans": out}},
+	}}})
+
+	url := strings.TrimSuffix(*otlpEndpoint, "/") + "/v1/traces"
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			err = fmt.Errorf("collector returned %s", resp.Status)
+		}
+	}
+	if err != nil {
+		event(slog.LevelWarn, fmt.Sprintf("\033[33mTrace export failed: %v\033[0m\n", err), "trace export failed", "err", err, "spans", len(batch))
+	}
+}
+
+// otlpAttributes converts a plain map into OTLP's typed key/value list.
+func otlpAttributes(attrs map[string]any) []map[string]any {
+	list := make([]map[string]any, 0, len(attrs))
+	for k, v := range attrs {
+		var value map[string]any
+		switch v := v.(type) {
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		list = append(list, map[string]any{"key": k, "value": value})
+	}
+	return list
+}
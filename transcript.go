@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Sessions are saved as JSON transcripts under .tinyagent/sessions, one file per run that is rewritten on each
+// save, so an interrupted mission can be read back or resumed instead of being lost with the process.
+var (
+	sessionsDir  = filepath.Join(".tinyagent", "sessions")
+	sessionStart = time.Now()
+)
+
+type transcript struct {
+	Model    string              `json:"model"`
+	URL      string              `json:"url"`
+	Started  time.Time           `json:"started"`
+	Saved    time.Time           `json:"saved"`
+	Messages []transcriptMessage `json:"messages"`
+}
+
+// transcriptMessage is a message as sent, plus with --show-thoughts the reasoning split off it, under a
+// "thoughts" key of its own since it was never part of the conversation the model saw.
+type transcriptMessage struct {
+	ChatMessage
+	Thoughts string
+}
+
+func (t transcriptMessage) MarshalJSON() ([]byte, error) {
+	raw, err := json.Marshal(t.ChatMessage)
+	if err != nil || t.Thoughts == "" {
+		return raw, err
+	}
+	thoughts, _ := json.Marshal(t.Thoughts)
+	return append(append(append(raw[:len(raw)-1], `,"thoughts":`...), thoughts...), '}'), nil
+}
+
+// saveTranscript writes the conversation so far and returns the file it went to: path, or this session's file
+// under sessionsDir when path is empty.
+func saveTranscript(messages []ChatMessage, path string) (string, error) {
+	if path == "" {
+		if err := os.MkdirAll(sessionsDir, 0o755); err != nil {
+			return "", err
+		}
+		ignore := filepath.Join(sessionsDir, ".gitignore")
+		if _, err := os.Stat(ignore); os.IsNotExist(err) {
+			os.WriteFile(ignore, []byte("*\n"), 0o644)
+		}
+		path = filepath.Join(sessionsDir, sessionStart.Format("20060102-150405")+branchSuffix()+".json")
+	}
+	saved := make([]transcriptMessage, len(messages))
+	for i, m := range messages {
+		saved[i].ChatMessage = m
+		if *showThoughts {
+			saved[i].Thoughts = m.Thoughts
+		}
+	}
+	raw, err := json.MarshalIndent(transcript{*model, *apiURL, sessionStart, time.Now(), saved}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, raw, 0o644)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Most tools bound their own output, but not to what a given session can afford, and one enormous listing or
+// log read can still fill the context window. --tool-limits caps each tool's result and says how to cut it:
+// head keeps the start, tail the end, as logs want, and summary has the model condense it. * sets the cap for
+// every tool not named, for example:
+//
+//	--tool-limits "*=64k,browse_directory=16k,log_grep=32k:tail,run_command=24k:summary"
+var toolLimitsFlag = flag.String("tool-limits", "*=64k", "Per-tool result caps as tool=size[:head|tail|summary], comma-separated; * covers the rest")
+
+type toolLimit struct {
+	size     int
+	strategy string
+}
+
+var truncateStrategies = []string{"head", "tail", "summary"}
+
+// toolLimits parses --tool-limits; main reports an invalid one before any mission starts.
+var toolLimits = sync.OnceValues(func() (map[string]toolLimit, error) {
+	limits := map[string]toolLimit{}
+	for _, entry := range strings.Split(*toolLimitsFlag, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("--tool-limits: %q is not tool=size[:strategy]", entry)
+		}
+		size, strategy, _ := strings.Cut(spec, ":")
+		if strategy == "" {
+			strategy = "head"
+		}
+		if !slices.Contains(truncateStrategies, strategy) {
+			return nil, fmt.Errorf("--tool-limits: unknown strategy %q for %s, want head, tail, or summary", strategy, name)
+		}
+		n, err := parseByteSize(size)
+		if err != nil {
+			return nil, fmt.Errorf("--tool-limits: %s: %v", name, err)
+		}
+		limits[strings.TrimSpace(name)] = toolLimit{n, strategy}
+	}
+	return limits, nil
+})
+
+// parseByteSize reads a size like 4096, 64k, or 1m.
+func parseByteSize(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	unit := 1
+	switch {
+	case strings.HasSuffix(s, "k"):
+		unit, s = 1<<10, strings.TrimSuffix(s, "k")
+	case strings.HasSuffix(s, "m"):
+		unit, s = 1<<20, strings.TrimSuffix(s, "m")
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("size %q is not a positive number of bytes, like 4096 or 64k", s)
+	}
+	return n * unit, nil
+}
+
+// truncateResult cuts one tool result down to its limit, by the tool's strategy.
+func truncateResult(ctx context.Context, name, res string) string {
+	limits, _ := toolLimits()
+	limit, ok := limits[name]
+	if !ok {
+		limit, ok = limits["*"]
+	}
+	if !ok || len(res) <= limit.size {
+		return res
+	}
+	event(slog.LevelInfo, fmt.Sprintf("\033[90m✂️  %s returned %d bytes, cutting to %d (%s)\033[0m\n", name, len(res), limit.size, limit.strategy),
+		"tool result truncated", "tool", name, "bytes", len(res), "limit", limit.size, "strategy", limit.strategy)
+	switch limit.strategy {
+	case "tail":
+		return clipOutput(res, limit.size, true)
+	case "summary":
+		// The summarizer has a context window too, so it sees at most what the session budget allows.
+		summary, err := summarizePayload(ctx, clipOutput(res, 3*max(*contextTokens, 8000), false))
+		if err == nil && len(summary) <= limit.size {
+			return summary
+		}
+		if err != nil {
+			event(slog.LevelWarn, fmt.Sprintf("\033[33mCould not summarize the %s result, cutting it instead: %v\033[0m\n", name, err), "summarize failed", "tool", name, "err", err)
+		}
+	}
+	return clipOutput(res, limit.size, false)
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The UI layer is the only place that decides how progress reaches the user. Interactive runs keep the
+// colored terminal output, while batch and server runs get parseable slog records on stderr instead.
+var (
+	logLevel  = flag.String("log-level", "info", "Minimum level to report: debug, info, warn, error")
+	logFormat = flag.String("log-format", "color", "Output style: color (interactive), text, or json")
+	quiet     = flag.Bool("quiet", false, "Print only the final answer on stdout; prompts and errors go to stderr")
+	noColor   = flag.Bool("no-color", false, "Disable ANSI styling (also disabled by NO_COLOR, TERM=dumb, or non-terminal output)")
+
+	logger   *slog.Logger
+	minLevel slog.Level
+	colorOn  bool
+
+	stdin = newStdinScanner()
+
+	termMu    sync.Mutex
+	askMu     sync.Mutex  // one question at a time, even when tools run concurrently
+	live      *statusLine // the running status line, if any
+	lineStart = true      // whether the cursor is at column 0
+	paused    bool        // set while waiting on user input
+
+	ansiEscape = regexp.MustCompile("\033\\[[0-9;]*m")
+)
+
+// setupUI validates the logging flags and builds the structured logger used outside of color mode.
+func setupUI() error {
+	if err := minLevel.UnmarshalText([]byte(*logLevel)); err != nil {
+		return fmt.Errorf("invalid --log-level %q", *logLevel)
+	}
+	if *quiet {
+		minLevel = slog.LevelError
+	}
+	opts := &slog.HandlerOptions{Level: minLevel}
+	switch *logFormat {
+	case "color", "text":
+		logger = slog.New(slog.NewTextHandler(os.Stderr, opts))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, opts))
+	default:
+		return fmt.Errorf("invalid --log-format %q (want color, text, or json)", *logFormat)
+	}
+	colorOn = !*noColor && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" && isTerminal(os.Stdout) && enableColor(os.Stdout)
+	if colorOn {
+		enableColor(os.Stderr) // --quiet sends the pretty text to stderr
+	}
+	return nil
+}
+
+// isTerminal reports whether f is attached to a console rather than a pipe or file.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// paint is the single styling gate: every string printed for humans passes through it, and all ANSI
+// sequences are stripped when the output can't render them, so piped logs stay readable.
+func paint(s string) string {
+	if colorOn {
+		return s
+	}
+	return ansiEscape.ReplaceAllString(s, "")
+}
+
+// interactive reports whether output is meant for a human watching a terminal.
+func interactive() bool {
+	return *logFormat == "color"
+}
+
+// say is the one place human-facing text reaches stdout. It clears the live status line first so the two never
+// interleave, and remembers whether the cursor sits at the start of a line so the spinner knows when it may redraw.
+func say(pretty string) {
+	if pretty == "" {
+		return
+	}
+	termMu.Lock()
+	defer termMu.Unlock()
+	if *quiet {
+		fmt.Fprint(os.Stderr, paint(pretty))
+		return
+	}
+	if live != nil && lineStart {
+		fmt.Print("\r\033[K")
+	}
+	fmt.Print(paint(pretty))
+	lineStart = strings.HasSuffix(pretty, "\n") || strings.HasSuffix(pretty, "\n\033[0m")
+}
+
+// event reports progress: the pretty text is printed as-is in color mode, otherwise msg and attrs become a log record.
+func event(level slog.Level, pretty, msg string, attrs ...any) {
+	if !interactive() {
+		logger.Log(context.Background(), level, msg, attrs...)
+	} else if level >= minLevel {
+		say(pretty)
+	}
+}
+
+// ask prompts the user and reads one line. Prompts always go to stdout since they must be answered regardless of
+// log format, and the live status line is held back while the user types.
+func ask(pretty string) (string, bool) {
+	askMu.Lock()
+	defer askMu.Unlock()
+	termMu.Lock()
+	paused = true
+	termMu.Unlock()
+	say(pretty)
+	ok := stdin.Scan()
+
+	termMu.Lock()
+	paused, lineStart = false, true
+	termMu.Unlock()
+	return stdin.Text(), ok
+}
+
+// maxInputLine is far above bufio.Scanner's 64KB default, which a pasted stack trace or log easily exceeds.
+const maxInputLine = 16 << 20
+
+func newStdinScanner() *bufio.Scanner {
+	s := bufio.NewScanner(stdinReader{})
+	s.Buffer(make([]byte, 0, 64<<10), maxInputLine)
+	return s
+}
+
+// askMultiline is ask for input that may span lines, with history recall on the first: a line ending in a backslash continues on the next, and a
+// line of just """ opens a block that runs until the next """, so bug reports and stack traces paste intact.
+func askMultiline(pretty string, history []string) (string, bool) {
+	first, ok := askLine(pretty, history)
+	if !ok {
+		return "", false
+	}
+	var lines []string
+	switch {
+	case strings.TrimSpace(first) == `"""`:
+		for {
+			line, ok := ask("\033[90m... \033[0m")
+			if !ok || strings.TrimSpace(line) == `"""` {
+				return strings.Join(lines, "\n"), true
+			}
+			lines = append(lines, line)
+		}
+	case strings.HasSuffix(first, "\\"):
+		line := first
+		for strings.HasSuffix(line, "\\") {
+			lines = append(lines, strings.TrimSuffix(line, "\\"))
+			if line, ok = ask("\033[90m... \033[0m"); !ok {
+				return strings.Join(lines, "\n"), true
+			}
+		}
+		return strings.Join(append(lines, line), "\n"), true
+	}
+	return first, true
+}
+
+// result prints a mission's final answer, framed in color mode and plain on stdout for scripts otherwise.
+func result(content string) {
+	if interactive() && !*quiet {
+		if colorOn {
+			content = renderMarkdown(content)
+		}
+		say(fmt.Sprintf("\033[90m=== \033[34mResult\033[90m ===\n\033[0m%s\033[90m\n==============\033[0m\n", content))
+		return
+	}
+	logger.Info("result", "length", len(content))
+	fmt.Println(content)
+}
+
+// report prints a titled block such as the /cost table. Outside color mode it goes to stderr so stdout stays results-only.
+func report(title, body string) {
+	if interactive() {
+		say(fmt.Sprintf("\033[90m=== \033[34m%s\033[90m ===\n%s\n==============\033[0m\n", title, body))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "=== %s ===\n%s\n", title, body)
+}
+
+// statusLine is a single self-redrawing line showing what the agent is doing, for how long, and at what cost.
+type statusLine struct {
+	label string
+	start time.Time
+	done  chan struct{}
+	once  sync.Once
+}
+
+var spinnerFrames = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+// startStatus shows label on a live status line. Without a color terminal to redraw on, it prints the label once
+// the way it always has, and the returned status line is inert.
+func startStatus(label string) *statusLine {
+	s := &statusLine{label: label, start: time.Now()}
+	if !interactive() || !colorOn || *quiet {
+		if interactive() && minLevel <= slog.LevelInfo {
+			say("\033[34m" + label + "... \033[0m")
+		}
+		return s
+	}
+
+	s.done = make(chan struct{})
+	termMu.Lock()
+	live = s
+	termMu.Unlock()
+	done := s.done
+	go func() {
+		tick := time.NewTicker(100 * time.Millisecond)
+		defer tick.Stop()
+		for frame := 0; ; frame++ {
+			select {
+			case <-done:
+				return
+			case <-tick.C:
+			}
+			cost := sessionTotal().Cost
+			termMu.Lock()
+			if live == s && lineStart && !paused {
+				fmt.Printf("\r\033[K\033[36m%c \033[34m%s\033[90m · %.1fs · \033[35m%.2fc\033[0m", spinnerFrames[frame%len(spinnerFrames)], s.label, time.Since(s.start).Seconds(), cost*100)
+			}
+			termMu.Unlock()
+		}
+	}()
+	return s
+}
+
+// set changes the operation shown, e.g. from planning to running a tool.
+func (s *statusLine) set(label string) {
+	termMu.Lock()
+	s.label = label
+	termMu.Unlock()
+}
+
+// stop clears the status line so results print on a clean terminal.
+func (s *statusLine) stop() {
+	if s.done == nil {
+		return
+	}
+	s.once.Do(func() { close(s.done) })
+	termMu.Lock()
+	if live == s {
+		live = nil
+		if lineStart {
+			fmt.Print("\r\033[K")
+		}
+	}
+	termMu.Unlock()
+}
+
+// Long-running tools report intermediate output through the context instead of blocking silently until their
+// result is ready. Each line prints above the status line as it happens, or becomes a record in batch runs.
+type progressKey struct{}
+
+func withProgress(ctx context.Context, tool string) context.Context {
+	return context.WithValue(ctx, progressKey{}, tool)
+}
+
+// progress reports one line of a tool's intermediate output; it is a no-op outside of a tool call.
+func progress(ctx context.Context, line string) {
+	tool, ok := ctx.Value(progressKey{}).(string)
+	if !ok {
+		return
+	}
+	termMu.Lock()
+	lead := map[bool]string{true: "", false: "\n"}[lineStart]
+	termMu.Unlock()
+	event(slog.LevelInfo, fmt.Sprintf("%s\033[90m  │ %s\033[0m\n", lead, line), "tool progress", "tool", tool, "line", line)
+}
+
+// progressWriter streams a tool's byte output, such as a command's stdout, as progress lines. Call Flush once the
+// stream ends to report a final line that had no trailing newline.
+type progressWriter struct {
+	ctx     context.Context
+	mu      sync.Mutex
+	partial []byte
+}
+
+func newProgressWriter(ctx context.Context) *progressWriter {
+	return &progressWriter{ctx: ctx}
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		progress(w.ctx, strings.TrimRight(string(w.partial[:i]), "\r"))
+		w.partial = w.partial[i+1:]
+	}
+}
+
+func (w *progressWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.partial) > 0 {
+		progress(w.ctx, string(w.partial))
+		w.partial = nil
+	}
+}
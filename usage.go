@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Session usage is tracked per model and purpose, so the hidden summarization calls made by
+// study_file_contents show up next to the planning calls the user actually sees.
+type usage struct {
+	Requests, PromptTokens, CompletionTokens int
+	Cost                                     float64
+}
+
+func (u *usage) add(o usage) {
+	u.Requests += o.Requests
+	u.PromptTokens += o.PromptTokens
+	u.CompletionTokens += o.CompletionTokens
+	u.Cost += o.Cost
+}
+
+type purposeKey struct{}
+
+var (
+	usageMu      sync.Mutex
+	sessionUsage = map[[2]string]*usage{} // {model, purpose} -> totals
+)
+
+// withPurpose labels the LLM requests made under ctx for the /cost breakdown.
+func withPurpose(ctx context.Context, purpose string) context.Context {
+	return context.WithValue(ctx, purposeKey{}, purpose)
+}
+
+func purposeOf(ctx context.Context) string {
+	if p, ok := ctx.Value(purposeKey{}).(string); ok {
+		return p
+	}
+	return "planning"
+}
+
+func recordUsage(ctx context.Context, model string, u usage) {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	key := [2]string{model, purposeOf(ctx)}
+	if sessionUsage[key] == nil {
+		sessionUsage[key] = &usage{}
+	}
+	sessionUsage[key].add(u)
+}
+
+// sessionTotal sums usage across every model and purpose.
+func sessionTotal() usage {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	var total usage
+	for _, u := range sessionUsage {
+		total.add(*u)
+	}
+	return total
+}
+
+// reportSessionTotal prints the running total after a turn.
+func reportSessionTotal() {
+	t := sessionTotal()
+	event(slog.LevelInfo, fmt.Sprintf("\033[90mSession so far: \033[35m%.2fc\033[90m over %d requests (%d/%d tokens)\033[0m\n", t.Cost*100, t.Requests, t.PromptTokens, t.CompletionTokens),
+		"session usage", "cost_cents", t.Cost*100, "requests", t.Requests, "prompt_tokens", t.PromptTokens, "completion_tokens", t.CompletionTokens)
+}
+
+// costTable renders the per-model, per-purpose breakdown shown by /cost and at exit.
+func costTable() string {
+	usageMu.Lock()
+	keys := make([][2]string, 0, len(sessionUsage))
+	for k := range sessionUsage {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i][0]+keys[i][1] < keys[j][0]+keys[j][1] })
+
+	var b strings.Builder
+	var total usage
+	fmt.Fprintf(&b, "%-36s %-14s %8s %10s %10s %10s\n", "MODEL", "PURPOSE", "REQUESTS", "PROMPT", "COMPLETION", "COST")
+	for _, k := range keys {
+		u := sessionUsage[k]
+		total.add(*u)
+		fmt.Fprintf(&b, "%-36s %-14s %8d %10d %10d %9.2fc\n", k[0], k[1], u.Requests, u.PromptTokens, u.CompletionTokens, u.Cost*100)
+	}
+	usageMu.Unlock()
+	fmt.Fprintf(&b, "%-36s %-14s %8d %10d %10d %9.2fc", "TOTAL", "", total.Requests, total.PromptTokens, total.CompletionTokens, total.Cost*100)
+	return b.String()
+}
+
+// Reliability stats are kept per provider host and model, so a session answers "is local or cloud faster and
+// steadier for me?" with measured numbers rather than impressions.
+type reqStats struct {
+	Requests, Errors, Retries int
+	Latencies                 []float64
+}
+
+var providerStats = map[[2]string]*reqStats{} // {provider, model} -> stats, guarded by usageMu
+
+// recordStats logs one logical request (including its retries) against the provider at apiURL.
+func recordStats(apiURL, model string, seconds float64, retries int, err error) {
+	provider := apiURL
+	if u, perr := url.Parse(apiURL); perr == nil && u.Host != "" {
+		provider = u.Host
+	}
+
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	key := [2]string{provider, model}
+	st := providerStats[key]
+	if st == nil {
+		st = &reqStats{}
+		providerStats[key] = st
+	}
+	st.Requests++
+	st.Retries += retries
+	st.Latencies = append(st.Latencies, seconds)
+	if err != nil {
+		st.Errors++
+	}
+}
+
+// statsTable renders latency percentiles, retries, and error rate per provider and model for /stats.
+func statsTable() string {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	keys := make([][2]string, 0, len(providerStats))
+	for k := range providerStats {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i][0]+keys[i][1] < keys[j][0]+keys[j][1] })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-24s %-30s %8s %7s %7s %7s %7s", "PROVIDER", "MODEL", "REQUESTS", "P50", "P95", "RETRIES", "ERRORS")
+	for _, k := range keys {
+		st := providerStats[k]
+		lat := slices.Clone(st.Latencies)
+		slices.Sort(lat)
+		fmt.Fprintf(&b, "\n%-24s %-30s %8d %6.1fs %6.1fs %7d %6.0f%%", k[0], k[1], st.Requests,
+			lat[len(lat)/2], lat[(len(lat)*95)/100], st.Retries, 100*float64(st.Errors)/float64(st.Requests))
+	}
+	return b.String()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// version can be stamped at build time with -ldflags "-X main.version=v1.2.3"; otherwise the module version
+// from `go install ...@v1.2.3` is used. Commit and date come from the VCS info the go command embeds.
+var (
+	version     = ""
+	showVersion = flag.Bool("version", false, "Print version and build information, then exit")
+)
+
+func versionString() string {
+	v, commit, date, dirty := version, "unknown", "unknown", ""
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v == "" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			v = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				commit = s.Value[:min(len(s.Value), 12)]
+			case "vcs.time":
+				date = s.Value
+			case "vcs.modified":
+				if s.Value == "true" {
+					dirty = " (modified)"
+				}
+			}
+		}
+	}
+	if v == "" {
+		v = "dev"
+	}
+	return fmt.Sprintf("tinyagent %s\ncommit: %s%s\nbuilt:  %s\ngo:     %s %s/%s", v, commit, dirty, date, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
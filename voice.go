@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// With --voice a mission can be dictated: the prompt starts recording from the microphone at once, Enter stops
+// it, and the recording is transcribed by an OpenAI-compatible /audio/transcriptions endpoint, or a local
+// whisper.cpp server's /inference, which takes the same form. Typing a line before Enter uses it instead, and a
+// recording that transcribes to nothing falls back to the usual prompt. Recording goes through whichever
+// recorder is installed, since Go has no portable microphone access.
+var (
+	voice           = flag.Bool("voice", false, "Dictate missions: record from the microphone at the prompt and transcribe the recording")
+	transcribeURL   = flag.String("transcribe-url", "", "Transcription endpoint for --voice, e.g. http://127.0.0.1:8080/inference for whisper.cpp (default: --url with /audio/transcriptions)")
+	transcribeModel = flag.String("transcribe-model", "whisper-1", "Transcription model for --voice")
+)
+
+// recorders are tried in order, and the first one installed records; FILE is the WAV file to write. Each keeps
+// recording until it is interrupted.
+var recorders = map[string][][]string{
+	"darwin":  {{"rec", "-q", "-c", "1", "-r", "16000", "FILE"}, {"ffmpeg", "-loglevel", "error", "-f", "avfoundation", "-i", ":0", "-ac", "1", "-ar", "16000", "-y", "FILE"}},
+	"windows": {{"sox", "-q", "-t", "waveaudio", "default", "-c", "1", "-r", "16000", "FILE"}},
+	"linux":   {{"arecord", "-q", "-f", "S16_LE", "-r", "16000", "-c", "1", "FILE"}, {"rec", "-q", "-c", "1", "-r", "16000", "FILE"}, {"ffmpeg", "-loglevel", "error", "-f", "pulse", "-i", "default", "-ac", "1", "-ar", "16000", "-y", "FILE"}},
+}
+
+// maxRecording stops a recording nobody ended, long after any mission would be finished being dictated.
+const maxRecording = 5 * time.Minute
+
+// dictate records and transcribes one mission, returning "" when the prompt should be typed instead. A line
+// typed while recording wins over the recording.
+func dictate() string {
+	dir, err := os.MkdirTemp("", "tinyagent-voice-")
+	if err != nil {
+		event(slog.LevelWarn, fmt.Sprintf("\033[33mCould not record: %v\033[0m\n", err), "voice failed", "err", err)
+		return ""
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mission.wav")
+	rec, err := startRecording(path)
+	if err != nil {
+		event(slog.LevelWarn, fmt.Sprintf("\033[33mCould not record, --voice is off: %v\033[0m\n", err), "voice failed", "err", err)
+		*voice = false
+		return ""
+	}
+	timer := time.AfterFunc(maxRecording, rec.stop)
+	typed, ok := ask("\033[34m🎙  Listening...\033[90m press Enter when done, or type the mission instead > \033[0m")
+	timer.Stop()
+	rec.stop()
+	if typed = strings.TrimSpace(typed); typed != "" || !ok {
+		return typed
+	}
+	// A WAV header alone means the recorder found no microphone to read.
+	if info, err := os.Stat(path); err != nil || info.Size() <= 44 {
+		event(slog.LevelWarn, fmt.Sprintf("\033[33mNothing was recorded, check the microphone: %s\033[0m\n", clip(strings.TrimSpace(rec.stderr.String()))), "voice failed", "recorder", rec.cmd.Path)
+		return ""
+	}
+
+	status := startStatus("📝 Transcribing")
+	text, err := transcribe(context.Background(), path)
+	status.stop()
+	if err != nil {
+		event(slog.LevelWarn, fmt.Sprintf("\033[33mCould not transcribe the recording: %v\033[0m\n", err), "transcription failed", "err", err)
+		return ""
+	}
+	if text == "" {
+		event(slog.LevelInfo, "\033[90m🎙  Heard nothing, type the mission instead\033[0m\n", "transcribed nothing")
+		return ""
+	}
+	event(slog.LevelInfo, fmt.Sprintf("\033[90m🎙  %s\033[0m\n", text), "transcribed mission", "mission", text)
+	return text
+}
+
+type recording struct {
+	cmd    *exec.Cmd
+	stderr bytes.Buffer
+	once   sync.Once
+}
+
+func startRecording(path string) (*recording, error) {
+	for _, argv := range recorders[runtime.GOOS] {
+		if _, err := exec.LookPath(argv[0]); err != nil {
+			continue
+		}
+		args := make([]string, len(argv)-1)
+		for i, arg := range argv[1:] {
+			args[i] = strings.ReplaceAll(arg, "FILE", path)
+		}
+		rec := &recording{cmd: exec.Command(argv[0], args...)}
+		rec.cmd.Stderr = &rec.stderr
+		if err := rec.cmd.Start(); err != nil {
+			return nil, err
+		}
+		return rec, nil
+	}
+	var names []string
+	for _, argv := range recorders[runtime.GOOS] {
+		names = append(names, argv[0])
+	}
+	return nil, fmt.Errorf("no audio recorder found (install %s)", strings.Join(names, " or "))
+}
+
+// stop interrupts the recorder so it finishes the WAV header, killing it if it doesn't exit. Windows has no
+// interrupt to send, and sox's file there is still readable, if with a wrong length in its header.
+func (r *recording) stop() {
+	r.once.Do(func() {
+		if runtime.GOOS == "windows" || r.cmd.Process.Signal(os.Interrupt) != nil {
+			r.cmd.Process.Kill()
+		}
+		done := make(chan struct{})
+		go func() {
+			r.cmd.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(3 * time.Second):
+			r.cmd.Process.Kill()
+			<-done
+		}
+	})
+}
+
+// transcribe uploads the recording and returns its text.
+func transcribe(ctx context.Context, path string) (string, error) {
+	endpoint := *transcribeURL
+	if endpoint == "" {
+		if !strings.HasSuffix(*apiURL, "/chat/completions") {
+			return "", fmt.Errorf("set --transcribe-url, it can't be derived from --url %s", *apiURL)
+		}
+		endpoint = strings.TrimSuffix(*apiURL, "/chat/completions") + "/audio/transcriptions"
+	}
+	audio, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer audio.Close()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "mission.wav")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(part, audio); err != nil {
+		return "", err
+	}
+	form.WriteField("model", *transcribeModel)
+	form.WriteField("response_format", "json")
+	form.Close()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if key := os.Getenv("OPENAI_API_KEY"); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", resp.Status, clip(strings.TrimSpace(string(raw))))
+	}
+	var transcript struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &transcript); err != nil {
+		return "", fmt.Errorf("unexpected response: %s", clip(string(raw)))
+	}
+	return strings.TrimSpace(transcript.Text), nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// workspace is the directory tree the file tools work on. They go through it rather than through os, so the
+// same browse, study, and write tools can work on a tree that isn't on local disk. Names are the tools'
+// relative paths, already checked with filepath.IsLocal; fs.WalkDir's slash-joined names work as well.
+var workspace workspaceFS = localFS{}
+
+type workspaceFS interface {
+	fs.StatFS
+	fs.ReadDirFS
+	fs.ReadFileFS
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+}
+
+// localFS is the working directory.
+type localFS struct{}
+
+func (localFS) Open(name string) (fs.File, error) {
+	return os.Open(filepath.FromSlash(name))
+}
+
+func (localFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(filepath.FromSlash(name))
+}
+
+func (localFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(filepath.FromSlash(name))
+}
+
+func (localFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.FromSlash(name))
+}
+
+func (localFS) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(filepath.FromSlash(name), perm)
+}
+
+func (localFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(filepath.FromSlash(name), data, perm)
+}
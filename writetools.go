@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The agent is read-only unless --allow-writes is given. Even then nothing touches disk unseen: every proposed
+// change is shown as a colored unified diff, which is the artifact the user approves, never the raw arguments.
+var allowWrites = flag.Bool("allow-writes", false, "Offer the model tools that create and edit files; every change is shown as a diff and needs approval")
+
+const writeToolDef = `[
+		{"type":"function","function":{"name":"write_file","description":"Create a file, or replace a file's entire content. The user reviews a diff before anything is written.","parameters":{"type":"object","properties":{
+			"path":{"type":"string","description":"Target file relative to current working directory"},
+			"content":{"type":"string","description":"The complete new content of the file"} },"required":["path","content"]}}},
+		{"type":"function","function":{"name":"edit_file","description":"Replace one exact occurrence of old_text in a file with new_text. Prefer this over write_file for small changes to existing files.","parameters":{"type":"object","properties":{
+			"path":{"type":"string","description":"Target file relative to current working directory"},
+			"old_text":{"type":"string","description":"Text to replace, copied exactly and with enough surrounding lines to be unique"},
+			"new_text":{"type":"string","description":"Replacement text"} },"required":["path","old_text","new_text"]}}}
+		]`
+
+// writeTools are the tools that change files; they only run with --allow-writes.
+var writeTools = map[string]bool{"write_file": true, "edit_file": true}
+
+// activeTools returns the tool definitions offered to the model this session.
+func activeTools() string {
+	return offeredTools(*allowWrites)
+}
+
+// offeredTools returns the core tools, the write tools if writes is set, and every enabled toolset, leaving out
+// the ones that change things unless writes is set.
+func offeredTools(writes bool) string {
+	defs := []string{toolDef}
+	if writes {
+		defs = append(defs, writeToolDef)
+	}
+	for _, ts := range toolsets {
+		if ts.enabled() && (writes || !ts.writes) {
+			defs = append(defs, ts.def)
+		}
+	}
+	return joinToolDefs(defs...)
+}
+
+// writeFile proposes replacing path's content with content.
+func writeFile(ctx context.Context, path, content string) (string, error) {
+	if !filepath.IsLocal(path) {
+		return "", fmt.Errorf("Permanent Error: Path %s is outside of current working directory", path)
+	}
+	before, err := workspace.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("Error reading file: %v", err)
+	}
+	return proposeEdit(ctx, path, string(before), content)
+}
+
+// editFile proposes replacing the single occurrence of oldText in path with newText.
+func editFile(ctx context.Context, path, oldText, newText string) (string, error) {
+	if !filepath.IsLocal(path) {
+		return "", fmt.Errorf("Permanent Error: Path %s is outside of current working directory", path)
+	}
+	raw, err := workspace.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Error reading file: %v", err)
+	}
+	before := string(raw)
+	switch n := strings.Count(before, oldText); {
+	case oldText == "":
+		return "", fmt.Errorf("old_text is empty, use write_file to create a file")
+	case n == 0:
+		return "", fmt.Errorf("old_text was not found in %s; study the file again and copy the text exactly", toolPath(path))
+	case n > 1:
+		return "", fmt.Errorf("old_text appears %d times in %s; include more surrounding lines so it is unique", n, toolPath(path))
+	}
+	return proposeEdit(ctx, path, before, strings.Replace(before, oldText, newText, 1))
+}
+
+// writeGuard, when a mode sets it, refuses changes to the paths it returns an error for, before they are
+// proposed; gen-tests uses it to keep to test files.
+var writeGuard func(path string) error
+
+// proposeEdit shows the diff from before to after and writes after only once the user approves. Any answer
+// other than yes or no is passed back to the model as the reason for rejecting the change.
+func proposeEdit(ctx context.Context, path, before, after string) (string, error) {
+	if writeGuard != nil {
+		if err := writeGuard(path); err != nil {
+			return "", err
+		}
+	}
+	if before == after {
+		return fmt.Sprintf("%s already has this content, nothing to change", toolPath(path)), nil
+	}
+	diff := unifiedDiff(path, before, after)
+	lines := strings.Split(strings.TrimSuffix(diff, "\n"), "\n")
+	event(slog.LevelInfo, fmt.Sprintf("\n\033[90m📝 Proposed change to \033[35m%s\033[0m\n%s\n", toolPath(path), strings.Join(highlightDiff(lines), "\n")),
+		"proposed edit", "path", path, "diff", diff)
+	added, removed := 0, 0
+	for _, line := range lines[2:] {
+		switch line[0] {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	if *dryRun {
+		return skipCall(fmt.Sprintf("change %s (+%d -%d lines)", toolPath(path), added, removed)), nil
+	}
+
+	answer := approve(ctx, fmt.Sprintf("Apply this change to %s?", toolPath(path)), diff)
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+	case "", "n", "no":
+		return fmt.Sprintf("The user rejected this change to %s; it was not written.", toolPath(path)), nil
+	default:
+		return fmt.Sprintf("The user rejected this change to %s and said: %s", toolPath(path), answer), nil
+	}
+
+	mode := os.FileMode(0o644)
+	if info, err := workspace.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := workspace.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("Error creating directory: %v", err)
+	}
+	if err := workspace.WriteFile(path, []byte(after), mode); err != nil {
+		return "", fmt.Errorf("Error writing file: %v", err)
+	}
+	return fmt.Sprintf("Wrote %s (+%d -%d lines)", toolPath(path), added, removed), nil
+}
+
+type approverKey struct{}
+
+// withApprover has changes proposed under ctx approved by decide instead of at the terminal, for missions that
+// came from somewhere else. decide gets the question and the diff, and answers like the prompt would.
+func withApprover(ctx context.Context, decide func(question, diff string) string) context.Context {
+	return context.WithValue(ctx, approverKey{}, decide)
+}
+
+// approve asks whoever started the mission about a proposed change: yes, no, or what to do instead.
+func approve(ctx context.Context, question, diff string) string {
+	if decide, ok := ctx.Value(approverKey{}).(func(string, string) string); ok {
+		return strings.TrimSpace(decide(question, diff))
+	}
+	answer, _ := ask(fmt.Sprintf("\033[34m%s [y]es, [n]o, or say what to do instead\033[90m > \033[0m", question))
+	return strings.TrimSpace(answer)
+}
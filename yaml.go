@@ -0,0 +1,471 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseYAML decodes the YAML that configuration files and API specs are written in into maps, slices, and
+// scalars, like encoding/json's any: block mappings and sequences, plain and quoted scalars, | and > blocks,
+// and flow [..] and {..} collections. Anchors, aliases, tags, and multiple documents are not supported; JSON,
+// being YAML, is decoded with encoding/json.
+func parseYAML(src []byte) (any, error) {
+	if text := strings.TrimSpace(string(src)); strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[") {
+		var v any
+		if err := json.Unmarshal(src, &v); err == nil {
+			return v, nil
+		}
+	}
+	p := &yamlParser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(string(src), "\r\n", "\n"), "\n") {
+		text := strings.TrimRight(raw, " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "---" || strings.HasPrefix(trimmed, "%") {
+			continue
+		}
+		p.lines = append(p.lines, yamlLine{indent: len(text) - len(trimmed), text: trimmed, raw: text, num: i + 1})
+	}
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	v, err := p.node(p.lines[p.pos].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.skipBlank(); p.pos < len(p.lines) {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return v, nil
+}
+
+type yamlLine struct {
+	indent    int
+	text, raw string // text without indentation or, once parsed, comments; raw as written, for blocks
+	num       int
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	num := 0
+	if p.pos < len(p.lines) {
+		num = p.lines[p.pos].num
+	}
+	return fmt.Errorf("yaml line %d: %s", num, fmt.Sprintf(format, args...))
+}
+
+// skipBlank moves past empty and comment-only lines.
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && (p.lines[p.pos].text == "" || strings.HasPrefix(p.lines[p.pos].text, "#")) {
+		p.pos++
+	}
+}
+
+// node parses the mapping, sequence, or scalar whose first line is at indent.
+func (p *yamlParser) node(indent int) (any, error) {
+	line := p.lines[p.pos]
+	switch {
+	case line.text == "-" || strings.HasPrefix(line.text, "- "):
+		return p.sequence(indent)
+	case yamlKey(stripComment(line.text)) >= 0:
+		return p.mapping(indent)
+	}
+	p.pos++
+	return p.value(stripComment(line.text), indent-1)
+}
+
+func (p *yamlParser) sequence(indent int) ([]any, error) {
+	list := []any{}
+	for p.skipBlank(); p.pos < len(p.lines); p.skipBlank() {
+		line := p.lines[p.pos]
+		if line.indent != indent || !(line.text == "-" || strings.HasPrefix(line.text, "- ")) {
+			break
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		if stripComment(rest) == "" {
+			p.pos++
+			item, err := p.child(indent)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+			continue
+		}
+		// "- key: value" starts a mapping, or a nested sequence, in the column after the dash.
+		column := indent + len(line.text) - len(rest)
+		p.lines[p.pos] = yamlLine{indent: column, text: rest, raw: line.raw, num: line.num}
+		item, err := p.node(column)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+func (p *yamlParser) mapping(indent int) (map[string]any, error) {
+	m := map[string]any{}
+	for p.skipBlank(); p.pos < len(p.lines); p.skipBlank() {
+		line := p.lines[p.pos]
+		if line.indent != indent || strings.HasPrefix(line.text, "- ") || line.text == "-" {
+			if line.indent > indent {
+				return nil, p.errorf("unexpected indentation")
+			}
+			break
+		}
+		text := stripComment(line.text)
+		colon := yamlKey(text)
+		if colon < 0 {
+			return nil, p.errorf("expected key: value, found %q", clip(text))
+		}
+		key, err := p.scalarString(strings.TrimSpace(text[:colon]))
+		if err != nil {
+			return nil, err
+		}
+		rest := strings.TrimSpace(text[colon+1:])
+		p.pos++
+		if rest == "" {
+			if m[key], err = p.child(indent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if m[key], err = p.value(rest, indent); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// child parses the node under a key or dash with nothing after it: more deeply indented lines, or a sequence at
+// the same indent, which YAML allows under a key. Nothing at all is null.
+func (p *yamlParser) child(indent int) (any, error) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	next := p.lines[p.pos]
+	if next.indent > indent || (next.indent == indent && (strings.HasPrefix(next.text, "- ") || next.text == "-")) {
+		return p.node(next.indent)
+	}
+	return nil, nil
+}
+
+// value decodes what follows a key or dash on its line, reading on for blocks, flow collections, and plain
+// scalars that continue on more deeply indented lines.
+func (p *yamlParser) value(text string, indent int) (any, error) {
+	switch {
+	case text[0] == '|' || text[0] == '>':
+		return p.block(text, indent), nil
+	case text[0] == '[' || text[0] == '{':
+		for depth := flowDepth(text); depth > 0 && p.pos < len(p.lines); depth = flowDepth(text) {
+			text += " " + stripComment(p.lines[p.pos].text)
+			p.pos++
+		}
+		f := &yamlFlow{s: text}
+		v, err := f.parse()
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		return v, nil
+	case text[0] == '"' || text[0] == '\'':
+		for !closedQuote(text) && p.pos < len(p.lines) {
+			text += " " + p.lines[p.pos].text
+			p.pos++
+		}
+		return p.scalarString(text)
+	}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent > indent && p.lines[p.pos].text != "" && !strings.HasPrefix(p.lines[p.pos].text, "#") {
+		text += " " + stripComment(p.lines[p.pos].text)
+		p.pos++
+	}
+	return plainScalar(text), nil
+}
+
+// block reads a literal (|) or folded (>) block scalar: the following lines indented past indent.
+func (p *yamlParser) block(header string, indent int) string {
+	var lines []string
+	blockIndent := -1
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := p.lines[p.pos]
+		if line.text != "" && line.indent <= indent {
+			break
+		}
+		if line.text != "" && blockIndent < 0 {
+			blockIndent = line.indent
+		}
+		if line.text == "" || len(line.raw) < blockIndent {
+			lines = append(lines, "")
+		} else {
+			lines = append(lines, line.raw[blockIndent:])
+		}
+	}
+	text := strings.Join(lines, "\n")
+	if header[0] == '>' {
+		var b strings.Builder
+		for i, line := range lines {
+			switch {
+			case i == 0 || (lines[i-1] == "" && line != ""):
+			case line == "" || strings.HasPrefix(line, " ") || strings.HasPrefix(lines[i-1], " "):
+				b.WriteString("\n")
+			default:
+				b.WriteString(" ")
+			}
+			b.WriteString(line)
+		}
+		text = b.String()
+	}
+	switch {
+	case strings.Contains(header, "-"):
+		return strings.TrimRight(text, "\n")
+	case strings.Contains(header, "+"):
+		return text + "\n"
+	}
+	return strings.TrimRight(text, "\n") + "\n"
+}
+
+func (p *yamlParser) scalarString(text string) (string, error) {
+	s, err := quotedScalar(text)
+	if err != nil {
+		return "", p.errorf("%v", err)
+	}
+	return s, nil
+}
+
+// quotedScalar unquotes a single- or double-quoted scalar, and returns anything else as it is.
+func quotedScalar(text string) (string, error) {
+	switch {
+	case len(text) >= 2 && text[0] == '\'' && text[len(text)-1] == '\'':
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	case len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"':
+		s, err := strconv.Unquote(strings.ReplaceAll(text, `\/`, "/"))
+		if err != nil {
+			return "", fmt.Errorf("bad double-quoted string %s", clip(text))
+		}
+		return s, nil
+	}
+	return text, nil
+}
+
+// plainScalar types an unquoted scalar the way YAML 1.2's core schema does.
+func plainScalar(text string) any {
+	switch text {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if n, err := strconv.ParseInt(text, 0, 64); err == nil {
+		return float64(n) // numbers decode as float64, as in encoding/json
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil && !strings.ContainsAny(text, "xXpP_") {
+		return f
+	}
+	return text
+}
+
+// yamlKey returns the index of the colon ending a mapping key in text, or -1 when text isn't a key: value.
+func yamlKey(text string) int {
+	if text == "" || text[0] == '[' || text[0] == '{' {
+		return -1
+	}
+	start := 0
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end < 0 {
+			return -1
+		}
+		start = end + 1
+	}
+	for i := start; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\t') {
+			return i
+		}
+	}
+	return -1
+}
+
+// closingQuote returns the index of the quote closing the one text starts with, or -1.
+func closingQuote(text string) int {
+	q := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case q == '"' && text[i] == '\\':
+			i++
+		case text[i] == q && q == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+func closedQuote(text string) bool {
+	end := closingQuote(text)
+	return end >= 0 && strings.TrimSpace(text[end+1:]) == ""
+}
+
+// stripComment removes a trailing # comment, which needs a space before it and can't be inside quotes.
+func stripComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.ContainsRune(" [{,:", rune(text[i-1])) {
+				quote = c
+			}
+		case c == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return strings.TrimRight(text[:i], " \t")
+		}
+	}
+	return text
+}
+
+// flowDepth counts the brackets a flow collection still has open, ignoring those inside quotes.
+func flowDepth(text string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth
+}
+
+// yamlFlow parses a flow collection, [a, b] or {k: v}, which may nest.
+type yamlFlow struct {
+	s   string
+	pos int
+}
+
+func (f *yamlFlow) parse() (any, error) {
+	v, err := f.value()
+	if err != nil {
+		return nil, err
+	}
+	if f.space(); f.pos < len(f.s) {
+		return nil, fmt.Errorf("unexpected %q after a flow collection", clip(f.s[f.pos:]))
+	}
+	return v, nil
+}
+
+func (f *yamlFlow) space() {
+	for f.pos < len(f.s) && (f.s[f.pos] == ' ' || f.s[f.pos] == '\t') {
+		f.pos++
+	}
+}
+
+func (f *yamlFlow) value() (any, error) {
+	f.space()
+	if f.pos >= len(f.s) {
+		return nil, fmt.Errorf("unterminated flow collection")
+	}
+	switch f.s[f.pos] {
+	case '[':
+		f.pos++
+		list := []any{}
+		for {
+			if f.space(); f.pos < len(f.s) && f.s[f.pos] == ']' {
+				f.pos++
+				return list, nil
+			}
+			v, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			// [key: value] is a list holding a single-pair mapping.
+			if f.space(); f.pos < len(f.s) && f.s[f.pos] == ':' {
+				f.pos++
+				pair, err := f.value()
+				if err != nil {
+					return nil, err
+				}
+				v = map[string]any{fmt.Sprint(v): pair}
+			}
+			list = append(list, v)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.pos++
+		m := map[string]any{}
+		for {
+			if f.space(); f.pos < len(f.s) && f.s[f.pos] == '}' {
+				f.pos++
+				return m, nil
+			}
+			k, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			key := fmt.Sprint(k)
+			if f.space(); f.pos < len(f.s) && f.s[f.pos] == ':' {
+				f.pos++
+				if m[key], err = f.value(); err != nil {
+					return nil, err
+				}
+			} else {
+				m[key] = nil
+			}
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	case '"', '\'':
+		end := closingQuote(f.s[f.pos:])
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated string")
+		}
+		s, err := quotedScalar(f.s[f.pos : f.pos+end+1])
+		f.pos += end + 1
+		return s, err
+	}
+	start := f.pos
+	for f.pos < len(f.s) && !strings.ContainsRune(",]}", rune(f.s[f.pos])) && !(f.s[f.pos] == ':' && (f.pos+1 == len(f.s) || f.s[f.pos+1] == ' ')) {
+		f.pos++
+	}
+	return plainScalar(strings.TrimSpace(f.s[start:f.pos])), nil
+}
+
+// separator consumes the comma after an item, or leaves the closing bracket for the caller.
+func (f *yamlFlow) separator(closing byte) error {
+	f.space()
+	switch {
+	case f.pos < len(f.s) && f.s[f.pos] == ',':
+		f.pos++
+		return nil
+	case f.pos < len(f.s) && f.s[f.pos] == closing:
+		return nil
+	}
+	return fmt.Errorf("expected , or %c in a flow collection", closing)
+}